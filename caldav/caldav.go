@@ -0,0 +1,283 @@
+// Package caldav reads and writes the XML bodies of WebDAV (RFC 4918) and CalDAV (RFC 4791).
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"flow-todos/todo"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Namespaces
+const (
+	NsDAV            = "DAV:"
+	NsCalDAV         = "urn:ietf:params:xml:ns:caldav"
+	NsCalendarServer = "http://calendarserver.org/ns/"
+)
+
+// Reports
+const (
+	ReportCalendarQuery    = "calendar-query"
+	ReportCalendarMultiget = "calendar-multiget"
+)
+
+var prefixes = map[string]string{NsDAV: "D", NsCalDAV: "C", NsCalendarServer: "CS"}
+
+// Element of a request body
+type node struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []node     `xml:",any"`
+	Text     string     `xml:",chardata"`
+}
+
+func (n node) is(space string, local string) bool {
+	return n.XMLName.Space == space && n.XMLName.Local == local
+}
+
+func (n node) child(space string, local string) *node {
+	for i := range n.Children {
+		if n.Children[i].is(space, local) {
+			return &n.Children[i]
+		}
+	}
+	return nil
+}
+
+func (n node) attr(local string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Names of the properties of `DAV:prop`
+func (n node) propNames() (names []xml.Name) {
+	for _, c := range n.Children {
+		names = append(names, c.XMLName)
+	}
+	return
+}
+
+// Propfind is the body of PROPFIND, all properties if empty
+type Propfind struct {
+	AllProp bool
+	Props   []xml.Name
+}
+
+func ParsePropfind(body []byte) (p Propfind, err error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return Propfind{AllProp: true}, nil
+	}
+	var root node
+	if err = xml.Unmarshal(body, &root); err != nil {
+		return
+	}
+	if !root.is(NsDAV, "propfind") {
+		return p, errors.New("DAV:propfind required")
+	}
+	if prop := root.child(NsDAV, "prop"); prop != nil {
+		p.Props = prop.propNames()
+		return
+	}
+	// `allprop` and `propname`, names are answered with values
+	p.AllProp = true
+	return
+}
+
+// Report is the body of REPORT
+type Report struct {
+	// `calendar-query` or `calendar-multiget`
+	Name    string
+	AllProp bool
+	Props   []xml.Name
+	// Resources of `calendar-multiget`
+	Hrefs  []string
+	Filter todo.CalDAVFilter
+}
+
+func ParseReport(body []byte) (r Report, err error) {
+	var root node
+	if err = xml.Unmarshal(body, &root); err != nil {
+		return
+	}
+	if root.XMLName.Space != NsCalDAV || root.XMLName.Local != ReportCalendarQuery && root.XMLName.Local != ReportCalendarMultiget {
+		return r, fmt.Errorf("unsupported report `%s`", root.XMLName.Local)
+	}
+	r.Name = root.XMLName.Local
+	if prop := root.child(NsDAV, "prop"); prop != nil {
+		r.Props = prop.propNames()
+	} else {
+		r.AllProp = true
+	}
+	for _, c := range root.Children {
+		if c.is(NsDAV, "href") {
+			r.Hrefs = append(r.Hrefs, strings.TrimSpace(c.Text))
+		}
+	}
+	if filter := root.child(NsCalDAV, "filter"); filter != nil {
+		r.Filter, err = parseFilter(*filter)
+	}
+	return
+}
+
+// Filter of the VTODO comp-filter, unsupported conditions match all todos
+func parseFilter(filter node) (f todo.CalDAVFilter, err error) {
+	calendar := filter.child(NsCalDAV, "comp-filter")
+	if calendar == nil || calendar.attr("name") != "VCALENDAR" {
+		return
+	}
+	component := calendar.child(NsCalDAV, "comp-filter")
+	if component == nil {
+		return
+	}
+	if component.attr("name") != "VTODO" {
+		if component.child(NsCalDAV, "is-not-defined") != nil {
+			return
+		}
+		// Only VTODO in the collection
+		return f, errNoMatch
+	}
+	if r := component.child(NsCalDAV, "time-range"); r != nil {
+		if start := r.attr("start"); start != "" {
+			t, err := time.Parse("20060102T150405Z", start)
+			if err != nil {
+				return f, fmt.Errorf("invalid time-range start `%s`", start)
+			}
+			f.Start = &t
+		}
+		if end := r.attr("end"); end != "" {
+			t, err := time.Parse("20060102T150405Z", end)
+			if err != nil {
+				return f, fmt.Errorf("invalid time-range end `%s`", end)
+			}
+			f.End = &t
+		}
+	}
+	for _, p := range component.Children {
+		if !p.is(NsCalDAV, "prop-filter") {
+			continue
+		}
+		switch strings.ToUpper(p.attr("name")) {
+		case "COMPLETED":
+			// Completed todos have `COMPLETED`
+			completed := p.child(NsCalDAV, "is-not-defined") == nil
+			f.Completed = &completed
+		case "STATUS":
+			m := p.child(NsCalDAV, "text-match")
+			if m == nil || strings.ToUpper(strings.TrimSpace(m.Text)) != "COMPLETED" {
+				continue
+			}
+			completed := m.attr("negate-condition") != "yes"
+			f.Completed = &completed
+		}
+	}
+	return
+}
+
+// No object matches the filter, like a filter of VEVENT
+var errNoMatch = errors.New("no match")
+
+// NoMatch is true if the filter of the report matches no todo
+func NoMatch(err error) bool {
+	return err == errNoMatch
+}
+
+// Prop is a property with its value as XML
+type Prop struct {
+	Name  xml.Name
+	Value string
+}
+
+// Response of a resource in a multistatus
+type Response struct {
+	Href     string
+	Props    []Prop
+	NotFound []xml.Name
+	// Status of the resource without properties, like 404
+	Status int
+}
+
+// Multistatus renders the 207 body of the responses
+func Multistatus(responses []Response) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	b.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav" xmlns:CS="http://calendarserver.org/ns/">` + "\n")
+	for _, r := range responses {
+		b.WriteString("<D:response>")
+		b.WriteString(Href(r.Href))
+		if r.Status != 0 {
+			b.WriteString(status(r.Status))
+		}
+		if len(r.Props) != 0 {
+			b.WriteString("<D:propstat><D:prop>")
+			for _, p := range r.Props {
+				b.WriteString(element(p.Name, p.Value))
+			}
+			b.WriteString("</D:prop>" + status(http.StatusOK) + "</D:propstat>")
+		}
+		if len(r.NotFound) != 0 {
+			b.WriteString("<D:propstat><D:prop>")
+			for _, name := range r.NotFound {
+				b.WriteString(element(name, ""))
+			}
+			b.WriteString("</D:prop>" + status(http.StatusNotFound) + "</D:propstat>")
+		}
+		b.WriteString("</D:response>\n")
+	}
+	b.WriteString("</D:multistatus>\n")
+	return b.Bytes()
+}
+
+// Error renders the body of a violated precondition
+func Error(space string, precondition string) []byte {
+	return []byte(`<?xml version="1.0" encoding="utf-8"?>` + "\n" +
+		`<D:error xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">` + element(xml.Name{Space: space, Local: precondition}, "") + "</D:error>\n")
+}
+
+func status(code int) string {
+	return fmt.Sprintf("<D:status>HTTP/1.1 %d %s</D:status>", code, http.StatusText(code))
+}
+
+// Element with the prefix of its namespace
+func element(name xml.Name, value string) string {
+	tag := name.Local
+	ns := ""
+	if prefix, ok := prefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+	} else if name.Space != "" {
+		tag = "X:" + name.Local
+		ns = ` xmlns:X="` + Text(name.Space) + `"`
+	}
+	if value == "" {
+		return "<" + tag + ns + "/>"
+	}
+	return "<" + tag + ns + ">" + value + "</" + tag + ">"
+}
+
+// Text escapes the text for XML
+func Text(str string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(str))
+	return b.String()
+}
+
+func Href(href string) string {
+	return "<D:href>" + Text(href) + "</D:href>"
+}
+
+// Element renders an element without value, like `<D:collection/>`
+func Element(space string, local string) string {
+	return element(xml.Name{Space: space, Local: local}, "")
+}
+
+// Name of a property
+func Name(space string, local string) xml.Name {
+	return xml.Name{Space: space, Local: local}
+}
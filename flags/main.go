@@ -2,6 +2,8 @@ package flags
 
 import (
 	"flag"
+	"strings"
+	"time"
 )
 
 type AllowOrigins []string
@@ -25,20 +27,53 @@ func (i *AllowOrigins) Set(v string) error {
 	return nil
 }
 
+type WebhookUrls []string
+
+// Implements from flag.Value
+func (i *WebhookUrls) String() string {
+	return "[" + strings.Join(*i, ", ") + "]"
+}
+
+// Implements from flag.Value
+func (i *WebhookUrls) Set(v string) error {
+	*i = append(*i, v)
+	return nil
+}
+
 type Flags struct {
 	Port               *uint
+	AdminPort          *uint
 	LogLevel           *uint
 	GzipLevel          *uint
 	AllowOrigins       AllowOrigins
+	Store              *string
 	MysqlHost          *string
 	MysqlPort          *uint
 	MysqlDB            *string
 	MysqlUser          *string
 	MysqlPasswd        *string
+	MysqlMaxOpenConns  *uint
+	MysqlMaxIdleConns  *uint
+	MysqlConnLifetime  *time.Duration
+	MysqlConnIdleTime  *time.Duration
 	JwtIssuer          *string
 	JwtSecret          *string
 	ServiceUrlProjects *string
 	ServiceUrlSprints  *string
+	MigrateOnStart     *bool
+	WebhookUrls        WebhookUrls
+	WebhookSecret      *string
+	WebhookMaxAttempts *uint
+	WebhookBackoff     *time.Duration
+	WebhookTimeout     *time.Duration
+	OutboxSinks        *string
+	OutboxNatsUrl      *string
+	OutboxNatsSubject  *string
+	OutboxFile         *string
+	OutboxPollInterval *time.Duration
+	OutboxBackoff      *time.Duration
+	OutboxMaxAttempts  *uint
+	OutboxRetention    *time.Duration
 }
 
 var flags Flags
@@ -54,21 +89,51 @@ func Get() Flags {
 func parse() Flags {
 	flags = Flags{
 		flag.Uint("port", getUintEnv("PORT", 1323), "Server port"),
+		flag.Uint("admin-port", getUintEnv("ADMIN_PORT", 1324), "Server port of diagnostics and metrics, not to be published"),
 		flag.Uint("log-level", getUintEnv("LOG_LEVEL", 2), "Log level (1: 'DEBUG', 2: 'INFO', 3: 'WARN', 4: 'ERROR', 5: 'OFF', 6: 'PANIC', 7: 'FATAL'"),
 		flag.Uint("gzip-level", getUintEnv("GZIP_LEVEL", 6), "Gzip compression level"),
 		AllowOrigins{},
+		flag.String("store", getEnv("STORE", "mysql"), "Todo store (\"mysql\" or \"memory\")"),
 		flag.String("mysql-host", getEnv("MYSQL_HOST", "db"), "MySQL host"),
 		flag.Uint("mysql-port", getUintEnv("MYSQL_PORT", 3306), "MySQL port"),
 		flag.String("mysql-database", getEnv("MYSQL_DATABASE", "flow-sprints"), "MySQL database"),
 		flag.String("mysql-user", getEnv("MYSQL_USER", "flow-sprints"), "MySQL user"),
 		flag.String("mysql-password", getEnv("MYSQL_PASSWORD", ""), "MySQL password"),
+		flag.Uint("mysql-max-open-conns", getUintEnv("MYSQL_MAX_OPEN_CONNS", 25), "MySQL max open connections (0: unlimited)"),
+		flag.Uint("mysql-max-idle-conns", getUintEnv("MYSQL_MAX_IDLE_CONNS", 25), "MySQL max idle connections"),
+		flag.Duration("mysql-conn-max-lifetime", getDurationEnv("MYSQL_CONN_MAX_LIFETIME", 5*time.Minute), "MySQL connection max lifetime (0: unlimited)"),
+		flag.Duration("mysql-conn-max-idle-time", getDurationEnv("MYSQL_CONN_MAX_IDLE_TIME", time.Minute), "MySQL connection max idle time (0: unlimited)"),
 		flag.String("jwt-issuer", getEnv("JWT_ISSUER", "flow-users"), "JWT issuer"),
 		flag.String("jwt-secret", getEnv("JWT_SECRET", ""), "JWT secret"),
 		flag.String("service-url-projects", getEnv("SERVICE_URL_PROJECTS", ""), "Service url: flow-projects"),
 		flag.String("service-url-sprints", getEnv("SERVICE_URL_SPRINTS", ""), "Service url: flow-sprints"),
+		flag.Bool("migrate-on-start", getBoolEnv("MIGRATE_ON_START", false), "Apply pending DB migrations on start"),
+		WebhookUrls{},
+		flag.String("webhook-secret", getEnv("WEBHOOK_SECRET", ""), "Secret of the signatures of system webhooks"),
+		flag.Uint("webhook-max-attempts", getUintEnv("WEBHOOK_MAX_ATTEMPTS", 8), "Attempts of a webhook delivery before it fails"),
+		flag.Duration("webhook-backoff", getDurationEnv("WEBHOOK_BACKOFF", 30*time.Second), "Delay before the first retry of a webhook delivery, doubled on each retry"),
+		flag.Duration("webhook-timeout", getDurationEnv("WEBHOOK_TIMEOUT", 10*time.Second), "Timeout of a webhook delivery"),
+		flag.String("outbox-sinks", getEnv("OUTBOX_SINKS", "webhook"), "Comma separated sinks of the outbox events (\"webhook\", \"nats\" or \"file\")"),
+		flag.String("outbox-nats-url", getEnv("OUTBOX_NATS_URL", "nats://nats:4222"), "NATS server of the `nats` sink"),
+		flag.String("outbox-nats-subject", getEnv("OUTBOX_NATS_SUBJECT", "flow-todos"), "Subject prefix of the `nats` sink, followed by the event type"),
+		flag.String("outbox-file", getEnv("OUTBOX_FILE", "outbox.jsonl"), "JSON lines file of the `file` sink"),
+		flag.Duration("outbox-poll-interval", getDurationEnv("OUTBOX_POLL_INTERVAL", time.Second), "Interval to look for pending outbox events"),
+		flag.Duration("outbox-backoff", getDurationEnv("OUTBOX_BACKOFF", time.Second), "Delay before the first retry of an outbox event, doubled on each retry up to 5m"),
+		flag.Uint("outbox-max-attempts", getUintEnv("OUTBOX_MAX_ATTEMPTS", 20), "Attempts of an outbox event before it is dead and skipped (0: retried forever)"),
+		flag.Duration("outbox-retention", getDurationEnv("OUTBOX_RETENTION", 7*24*time.Hour), "Time published outbox events are kept (0: forever)"),
 	}
 	flag.Var(&flags.AllowOrigins, "allow-origin", "CORS allow origins")
+	flag.Var(&flags.WebhookUrls, "webhook-url", "System webhook urls subscribed to all events of all users")
 
 	flag.Parse()
+	if len(flags.WebhookUrls) == 0 {
+		flags.WebhookUrls = getListEnv("WEBHOOK_URLS")
+	}
 	return flags
 }
+
+// Non-flag arguments (subcommand)
+func Args() []string {
+	Get()
+	return flag.Args()
+}
@@ -3,6 +3,8 @@ package flags
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Get uint env variable
@@ -19,6 +21,20 @@ func getUintEnv(key string, fallback uint) uint {
 	return fallback
 }
 
+// Get bool env variable
+func getBoolEnv(key string, fallback bool) bool {
+	// Get env
+	if value, ok := os.LookupEnv(key); ok {
+		// parse to bool
+		var boolValue, err = strconv.ParseBool(value)
+		if err == nil {
+			return boolValue
+		}
+	}
+	// Use fallbacn when env using `key` does not exist or failed to parse
+	return fallback
+}
+
 // Get string env variable
 func getEnv(key, fallback string) string {
 	// Get env
@@ -28,3 +44,30 @@ func getEnv(key, fallback string) string {
 	// Use fallbacn when env using `key` does not exist
 	return fallback
 }
+
+// Get duration env variable
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	// Get env
+	if value, ok := os.LookupEnv(key); ok {
+		// parse to time.Duration
+		var durationValue, err = time.ParseDuration(value)
+		if err == nil {
+			return durationValue
+		}
+	}
+	// Use fallbacn when env using `key` does not exist or failed to parse
+	return fallback
+}
+
+// Get comma separated env variable
+func getListEnv(key string) (values []string) {
+	// Get env
+	if value, ok := os.LookupEnv(key); ok {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return
+}
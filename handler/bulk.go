@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"flow-todos/flags"
+	"flow-todos/jwt"
+	"flow-todos/todo"
+	"flow-todos/utils"
+	"fmt"
+	"net/http"
+	"strings"
+
+	jwtGo "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+)
+
+func (h *Handler) Bulk(c echo.Context) error {
+	// Check `Content-Type`
+	if !strings.Contains(c.Request().Header.Get("Content-Type"), "application/json") {
+		// 415: Invalid `Content-Type`
+		return c.JSONPretty(http.StatusUnsupportedMediaType, map[string]string{"message": "unsupported media type"}, "	")
+	}
+
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Bind request body
+	body := new(todo.BulkBody)
+	if err = c.Bind(body); err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Validate request body
+	if err = c.Validate(body); err != nil {
+		// 422: Unprocessable entity
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnprocessableEntity, map[string]string{"message": err.Error()}, "	")
+	}
+	for i, o := range body.Operations {
+		if o.Op == todo.BulkPatch && o.Patch == nil {
+			// 422: Unprocessable entity
+			c.Logger().Debugf("`operations[%d].patch` required", i)
+			return c.JSONPretty(http.StatusUnprocessableEntity, map[string]string{"message": fmt.Sprintf("`operations[%d].patch` required with `op: \"patch\"`", i)}, "	")
+		}
+	}
+	if body.Mode == "" {
+		body.Mode = todo.BulkModeAtomic
+	}
+
+	// Check each project id and sprint id once
+	projects, err := existingIds(*flags.Get().ServiceUrlProjects, body.ProjectIds(), &u.Raw)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	sprints, err := existingIds(*flags.Get().ServiceUrlSprints, body.SprintIds(), &u.Raw)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+
+	results, failed, err := todo.Bulk(h.store, *body, func(s todo.TodoStore, o todo.BulkOperation) (r todo.BulkResult, err error) {
+		switch o.Op {
+		case todo.BulkPatch:
+			return bulkPatch(s, userId, o, projects, sprints)
+		case todo.BulkComplete:
+			return bulkComplete(s, userId, o)
+		case todo.BulkSkip:
+			return bulkSkip(s, userId, o)
+		default:
+			return bulkDelete(s, userId, o)
+		}
+	})
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if failed != nil {
+		// Status of the failed operation, nothing is applied
+		r := results[*failed]
+		c.Logger().Debugf("operations[%d]: %s", *failed, r.Message)
+		return c.JSONPretty(r.Status, map[string]interface{}{"message": fmt.Sprintf("operations[%d]: %s", *failed, r.Message), "index": *failed, "result": r}, "	")
+	}
+
+	// 200: Success
+	return c.JSONPretty(http.StatusOK, map[string]interface{}{"results": results}, "	")
+}
+
+// Ids which exist in the external service
+func existingIds(serviceUrl string, ids []uint64, bearer *string) (exists map[uint64]bool, err error) {
+	exists = map[uint64]bool{}
+	for _, id := range ids {
+		var status int
+		status, err = utils.HttpGet(fmt.Sprintf("%s/%d", serviceUrl, id), bearer)
+		if err != nil {
+			return
+		}
+		exists[id] = status == http.StatusOK
+	}
+	return
+}
+
+func bulkFailure(status int, message string) (todo.BulkResult, error) {
+	return todo.BulkResult{Status: status, Message: message}, nil
+}
+
+func bulkPatch(s todo.TodoStore, userId uint64, o todo.BulkOperation, projects map[uint64]bool, sprints map[uint64]bool) (r todo.BulkResult, err error) {
+	patch := *o.Patch
+	scope := o.Scope
+	if scope == "" {
+		scope = todo.PatchScopeAll
+	}
+
+	// Check project id
+	if patch.ProjectId.UInt64 != nil && *patch.ProjectId.UInt64 != nil && !projects[**patch.ProjectId.UInt64] {
+		return bulkFailure(http.StatusBadRequest, fmt.Sprintf("project id: %d does not exist", **patch.ProjectId.UInt64))
+	}
+
+	// Check sprint id
+	if patch.SprintId.UInt64 != nil && *patch.SprintId.UInt64 != nil && !sprints[**patch.SprintId.UInt64] {
+		return bulkFailure(http.StatusBadRequest, fmt.Sprintf("sprint id: %d does not exist", **patch.SprintId.UInt64))
+	}
+
+	// Check labels
+	if patch.Labels != nil && len(*patch.Labels) != 0 {
+		labelNotFound, err := todo.CheckLabels(s, userId, *patch.Labels)
+		if err != nil {
+			return r, err
+		}
+		if labelNotFound != nil {
+			return bulkFailure(http.StatusBadRequest, fmt.Sprintf("label id: %d does not exist", *labelNotFound))
+		}
+	}
+
+	// Check blockers
+	if patch.BlockedBy != nil && len(*patch.BlockedBy) != 0 {
+		blockerNotFound, cycle, err := todo.CheckDependencies(s, userId, o.Id, *patch.BlockedBy)
+		if err != nil {
+			return r, err
+		}
+		if blockerNotFound != nil {
+			return bulkFailure(http.StatusBadRequest, fmt.Sprintf("todo id: %d does not exist", *blockerNotFound))
+		}
+		if cycle {
+			return bulkFailure(http.StatusBadRequest, "`blocked_by` makes a dependency cycle")
+		}
+	}
+
+	t, notFound, dateNotFound, dateOverUtil, noDaysWithWeekly, repeatWithScopeThis, err := todo.Patch(s, userId, o.Id, patch, scope)
+	if err != nil {
+		return
+	}
+	if notFound {
+		return bulkFailure(http.StatusNotFound, "not found")
+	}
+	if dateNotFound {
+		return bulkFailure(http.StatusBadRequest, "`date` required to set `repeat`")
+	}
+	if dateOverUtil {
+		return bulkFailure(http.StatusBadRequest, "`date` must until `repeat.until`")
+	}
+	if repeatWithScopeThis {
+		return bulkFailure(http.StatusBadRequest, "`repeat` cannot be updated with `scope=this`")
+	}
+	if noDaysWithWeekly {
+		return bulkFailure(http.StatusBadRequest, "`repeat.days` required with `repeat.unit: \"week\"`")
+	}
+	return todo.BulkResult{Status: http.StatusOK, Todos: []todo.Todo{t}}, nil
+}
+
+func bulkComplete(s todo.TodoStore, userId uint64, o todo.BulkOperation) (r todo.BulkResult, err error) {
+	t, newTodo, notFound, dateNotFound, invalidUnit, blockedBy, err := todo.Complete(s, userId, o.Id, o.Force)
+	if err != nil {
+		return
+	}
+	if notFound {
+		return bulkFailure(http.StatusNotFound, "not found")
+	}
+	if len(blockedBy) != 0 {
+		return todo.BulkResult{Status: http.StatusConflict, Message: "todo is blocked by incomplete todos", BlockedBy: blockedBy}, nil
+	}
+	if invalidUnit {
+		return bulkFailure(http.StatusBadRequest, "invalid todo repeat unit")
+	}
+	if dateNotFound {
+		return bulkFailure(http.StatusBadRequest, "todo.date does not exists")
+	}
+
+	r = todo.BulkResult{Status: http.StatusOK, Todos: []todo.Todo{t}}
+	if newTodo.Id != 0 {
+		r.Todos = append(r.Todos, newTodo)
+	}
+	return
+}
+
+func bulkSkip(s todo.TodoStore, userId uint64, o todo.BulkOperation) (r todo.BulkResult, err error) {
+	t, overUntil, notFound, repeatNotFound, dateNotFound, invalidUnit, err := todo.Skip(s, userId, o.Id)
+	if err != nil {
+		return
+	}
+	if notFound {
+		return bulkFailure(http.StatusNotFound, "not found")
+	}
+	if repeatNotFound {
+		return bulkFailure(http.StatusBadRequest, "repeat not found")
+	}
+	if invalidUnit {
+		return bulkFailure(http.StatusBadRequest, "invalid todo repeat unit")
+	}
+	if dateNotFound {
+		return bulkFailure(http.StatusBadRequest, "todo.date does not exists")
+	}
+	if overUntil {
+		return bulkFailure(http.StatusBadRequest, "cannot skip last todo in due date")
+	}
+	return todo.BulkResult{Status: http.StatusOK, Todos: []todo.Todo{t}}, nil
+}
+
+func bulkDelete(s todo.TodoStore, userId uint64, o todo.BulkOperation) (r todo.BulkResult, err error) {
+	notFound, err := todo.Delete(s, userId, o.Id)
+	if err != nil {
+		return
+	}
+	if notFound {
+		return bulkFailure(http.StatusNotFound, "not found")
+	}
+	return todo.BulkResult{Status: http.StatusNoContent}, nil
+}
@@ -0,0 +1,378 @@
+package handler
+
+import (
+	"encoding/xml"
+	"flow-todos/caldav"
+	"flow-todos/flags"
+	"flow-todos/jwt"
+	"flow-todos/todo"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	// Principal and calendar home of the user
+	caldavRoot = "/caldav/"
+	// Calendar collection of the todos
+	caldavCollection = "/caldav/todos/"
+	mimeXML          = "application/xml; charset=utf-8"
+)
+
+// CalDAV serves the todos of the user as a VTODO calendar collection.
+// Clients authenticate with the JWT as bearer token or as password of Basic authentication.
+func (h *Handler) CalDAV(c echo.Context) error {
+	path := c.Request().URL.Path
+	if path == "/.well-known/caldav" {
+		// 301: Moved permanently
+		return c.Redirect(http.StatusMovedPermanently, caldavRoot)
+	}
+
+	c.Response().Header().Set("DAV", "1, 3, calendar-access")
+	if c.Request().Method == http.MethodOptions {
+		// 200: Success
+		c.Response().Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+		return c.NoContent(http.StatusOK)
+	}
+
+	// Check token
+	userId, err := caldavUser(c)
+	if err != nil {
+		// 401: Unauthorized
+		c.Logger().Debug(err)
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="flow-todos"`)
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	switch {
+	case path == caldavRoot || path+"/" == caldavRoot:
+		if c.Request().Method == echo.PROPFIND {
+			return h.caldavPropfind(c, userId, caldavRoot)
+		}
+	case path == caldavCollection || path+"/" == caldavCollection:
+		switch c.Request().Method {
+		case echo.PROPFIND:
+			return h.caldavPropfind(c, userId, caldavCollection)
+		case "REPORT":
+			return h.caldavReport(c, userId)
+		}
+	case strings.HasPrefix(path, caldavCollection) && !strings.Contains(strings.TrimPrefix(path, caldavCollection), "/"):
+		name := strings.TrimPrefix(path, caldavCollection)
+		switch c.Request().Method {
+		case echo.PROPFIND:
+			return h.caldavPropfind(c, userId, path)
+		case http.MethodGet, http.MethodHead:
+			return h.caldavGet(c, userId, name)
+		case http.MethodPut:
+			return h.caldavPut(c, userId, name)
+		case http.MethodDelete:
+			return h.caldavDelete(c, userId, name)
+		}
+	default:
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+	// 405: Method not allowed
+	return echo.ErrMethodNotAllowed
+}
+
+// User of the bearer token or the password of Basic authentication
+func caldavUser(c echo.Context) (userId uint64, err error) {
+	raw := ""
+	auth := c.Request().Header.Get(echo.HeaderAuthorization)
+	if strings.HasPrefix(auth, "Bearer ") {
+		raw = strings.TrimPrefix(auth, "Bearer ")
+	} else if _, password, ok := c.Request().BasicAuth(); ok {
+		raw = password
+	}
+	if raw == "" {
+		return 0, echo.ErrUnauthorized
+	}
+	token, err := jwt.ParseToken(*flags.Get().JwtSecret, raw)
+	if err != nil {
+		return
+	}
+	return jwt.CheckToken(*flags.Get().JwtIssuer, token)
+}
+
+func (h *Handler) caldavPropfind(c echo.Context, userId uint64, path string) error {
+	body, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	propfind, err := caldav.ParsePropfind(body)
+	if err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	depth := c.Request().Header.Get("Depth")
+
+	var responses []caldav.Response
+	switch path {
+	case caldavRoot:
+		responses = append(responses, caldavResponse(caldavRoot, caldavRootProps(), propfind.AllProp, propfind.Props))
+		if depth != "0" {
+			objects, err := todo.CalDAVObjects(h.store, userId)
+			if err != nil {
+				// 500: Internal server error
+				c.Logger().Error(err)
+				return c.String(http.StatusInternalServerError, err.Error())
+			}
+			responses = append(responses, caldavResponse(caldavCollection, caldavCollectionProps(objects), propfind.AllProp, propfind.Props))
+		}
+	case caldavCollection:
+		objects, err := todo.CalDAVObjects(h.store, userId)
+		if err != nil {
+			// 500: Internal server error
+			c.Logger().Error(err)
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		responses = append(responses, caldavResponse(caldavCollection, caldavCollectionProps(objects), propfind.AllProp, propfind.Props))
+		if depth != "0" {
+			for _, o := range objects {
+				responses = append(responses, caldavResponse(caldavHref(o.Name), caldavObjectProps(o), propfind.AllProp, propfind.Props))
+			}
+		}
+	default:
+		o, notFound, err := todo.GetCalDAVObject(h.store, userId, strings.TrimPrefix(path, caldavCollection))
+		if err != nil {
+			// 500: Internal server error
+			c.Logger().Error(err)
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		if notFound {
+			// 404: Not found
+			return echo.ErrNotFound
+		}
+		responses = append(responses, caldavResponse(caldavHref(o.Name), caldavObjectProps(o), propfind.AllProp, propfind.Props))
+	}
+
+	// 207: Multi-status
+	return c.Blob(http.StatusMultiStatus, mimeXML, caldav.Multistatus(responses))
+}
+
+func (h *Handler) caldavReport(c echo.Context, userId uint64) error {
+	body, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	report, err := caldav.ParseReport(body)
+	noMatch := caldav.NoMatch(err)
+	if err != nil && !noMatch {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	objects, err := todo.CalDAVObjects(h.store, userId)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	responses := []caldav.Response{}
+	if report.Name == caldav.ReportCalendarMultiget {
+		byName := map[string]todo.CalDAVObject{}
+		for _, o := range objects {
+			byName[o.Name] = o
+		}
+		for _, href := range report.Hrefs {
+			u, err := url.Parse(href)
+			o, ok := todo.CalDAVObject{}, false
+			if err == nil && strings.HasPrefix(u.Path, caldavCollection) {
+				o, ok = byName[strings.TrimPrefix(u.Path, caldavCollection)]
+			}
+			if !ok {
+				responses = append(responses, caldav.Response{Href: href, Status: http.StatusNotFound})
+				continue
+			}
+			responses = append(responses, caldavResponse(caldavHref(o.Name), caldavObjectProps(o), report.AllProp, report.Props))
+		}
+	} else if !noMatch {
+		for _, o := range objects {
+			if report.Filter.Match(o.Todo) {
+				responses = append(responses, caldavResponse(caldavHref(o.Name), caldavObjectProps(o), report.AllProp, report.Props))
+			}
+		}
+	}
+
+	// 207: Multi-status
+	return c.Blob(http.StatusMultiStatus, mimeXML, caldav.Multistatus(responses))
+}
+
+func (h *Handler) caldavGet(c echo.Context, userId uint64, name string) error {
+	o, notFound, err := todo.GetCalDAVObject(h.store, userId, name)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	if notFound {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	// 200: Success
+	c.Response().Header().Set(echo.HeaderContentType, mimeTextCalendar)
+	c.Response().Header().Set("ETag", o.ETag)
+	if c.Request().Method == http.MethodHead {
+		return c.NoContent(http.StatusOK)
+	}
+	return c.Blob(http.StatusOK, mimeTextCalendar, []byte(o.Data))
+}
+
+func (h *Handler) caldavPut(c echo.Context, userId uint64, name string) error {
+	// Check `Content-Type`
+	if !strings.Contains(c.Request().Header.Get("Content-Type"), "text/calendar") {
+		// 415: Invalid `Content-Type`
+		return c.String(http.StatusUnsupportedMediaType, "unsupported media type")
+	}
+	if name == "" || strings.HasPrefix(name, ".") {
+		// 400: Bad request
+		return c.String(http.StatusBadRequest, "invalid resource name")
+	}
+
+	body, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	_, created, preconditionFailed, precondition, failure, err := todo.PutCalDAVObject(h.store, userId, name, string(body),
+		c.Request().Header.Get("If-Match"), c.Request().Header.Get("If-None-Match"),
+		func(post todo.PostBody) error {
+			return c.Validate(&post)
+		})
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	if preconditionFailed {
+		// 412: Precondition failed
+		return c.NoContent(http.StatusPreconditionFailed)
+	}
+	if precondition != "" {
+		// 403: Forbidden
+		c.Logger().Debug(failure)
+		return c.Blob(http.StatusForbidden, mimeXML, caldav.Error(caldav.NsCalDAV, precondition))
+	}
+	if failure != "" {
+		// 409: Conflict
+		c.Logger().Debug(failure)
+		return c.String(http.StatusConflict, failure)
+	}
+
+	// No `ETag`, the stored object differs from the request
+	if created {
+		// 201: Created
+		return c.NoContent(http.StatusCreated)
+	}
+	// 204: No content
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) caldavDelete(c echo.Context, userId uint64, name string) error {
+	notFound, preconditionFailed, err := todo.DeleteCalDAVObject(h.store, userId, name, c.Request().Header.Get("If-Match"))
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	if notFound {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+	if preconditionFailed {
+		// 412: Precondition failed
+		return c.NoContent(http.StatusPreconditionFailed)
+	}
+
+	// 204: No content
+	return c.NoContent(http.StatusNoContent)
+}
+
+func caldavHref(name string) string {
+	return caldavCollection + url.PathEscape(name)
+}
+
+// Response with the requested properties, found or not
+func caldavResponse(href string, props []caldav.Prop, all bool, requested []xml.Name) (r caldav.Response) {
+	r.Href = href
+	if all {
+		for _, p := range props {
+			// Not part of `allprop`
+			if p.Name != caldav.Name(caldav.NsCalDAV, "calendar-data") {
+				r.Props = append(r.Props, p)
+			}
+		}
+		return
+	}
+	for _, name := range requested {
+		found := false
+		for _, p := range props {
+			if p.Name == name {
+				r.Props = append(r.Props, p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			r.NotFound = append(r.NotFound, name)
+		}
+	}
+	return
+}
+
+func caldavRootProps() []caldav.Prop {
+	return []caldav.Prop{
+		{Name: caldav.Name(caldav.NsDAV, "resourcetype"), Value: caldav.Element(caldav.NsDAV, "collection") + caldav.Element(caldav.NsDAV, "principal")},
+		{Name: caldav.Name(caldav.NsDAV, "displayname"), Value: "flow"},
+		{Name: caldav.Name(caldav.NsDAV, "current-user-principal"), Value: caldav.Href(caldavRoot)},
+		{Name: caldav.Name(caldav.NsDAV, "principal-URL"), Value: caldav.Href(caldavRoot)},
+		{Name: caldav.Name(caldav.NsCalDAV, "calendar-home-set"), Value: caldav.Href(caldavRoot)},
+	}
+}
+
+func caldavCollectionProps(objects []todo.CalDAVObject) []caldav.Prop {
+	privileges := ""
+	for _, privilege := range []string{"read", "write", "write-content", "bind", "unbind"} {
+		privileges += "<D:privilege>" + caldav.Element(caldav.NsDAV, privilege) + "</D:privilege>"
+	}
+	reports := ""
+	for _, report := range []string{caldav.ReportCalendarQuery, caldav.ReportCalendarMultiget} {
+		reports += "<D:supported-report><D:report>" + caldav.Element(caldav.NsCalDAV, report) + "</D:report></D:supported-report>"
+	}
+	ctag := todo.CalDAVCTag(objects)
+	return []caldav.Prop{
+		{Name: caldav.Name(caldav.NsDAV, "resourcetype"), Value: caldav.Element(caldav.NsDAV, "collection") + caldav.Element(caldav.NsCalDAV, "calendar")},
+		{Name: caldav.Name(caldav.NsDAV, "displayname"), Value: "flow todos"},
+		{Name: caldav.Name(caldav.NsDAV, "current-user-principal"), Value: caldav.Href(caldavRoot)},
+		{Name: caldav.Name(caldav.NsDAV, "owner"), Value: caldav.Href(caldavRoot)},
+		{Name: caldav.Name(caldav.NsDAV, "current-user-privilege-set"), Value: privileges},
+		{Name: caldav.Name(caldav.NsDAV, "supported-report-set"), Value: reports},
+		{Name: caldav.Name(caldav.NsDAV, "getetag"), Value: caldav.Text(ctag)},
+		{Name: caldav.Name(caldav.NsCalendarServer, "getctag"), Value: caldav.Text(ctag)},
+		{Name: caldav.Name(caldav.NsCalDAV, "supported-calendar-component-set"), Value: `<C:comp name="VTODO"/>`},
+		{Name: caldav.Name(caldav.NsCalDAV, "calendar-description"), Value: "Todos of flow"},
+	}
+}
+
+func caldavObjectProps(o todo.CalDAVObject) []caldav.Prop {
+	return []caldav.Prop{
+		{Name: caldav.Name(caldav.NsDAV, "resourcetype"), Value: ""},
+		{Name: caldav.Name(caldav.NsDAV, "getetag"), Value: caldav.Text(o.ETag)},
+		{Name: caldav.Name(caldav.NsDAV, "getcontenttype"), Value: "text/calendar; charset=utf-8; component=VTODO"},
+		{Name: caldav.Name(caldav.NsCalDAV, "calendar-data"), Value: caldav.Text(o.Data)},
+	}
+}
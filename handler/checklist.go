@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"flow-todos/flags"
+	"flow-todos/jwt"
+	"flow-todos/todo"
+	"net/http"
+	"strconv"
+	"strings"
+
+	jwtGo "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+)
+
+func (h *Handler) PostChecklistItem(c echo.Context) error {
+	// Check `Content-Type`
+	if !strings.Contains(c.Request().Header.Get("Content-Type"), "application/json") {
+		// 415: Invalid `Content-Type`
+		return c.JSONPretty(http.StatusUnsupportedMediaType, map[string]string{"message": "unsupported media type"}, "	")
+	}
+
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// string -> uint64
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	// Bind request body
+	post := new(todo.ChecklistItemPostBody)
+	if err = c.Bind(post); err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Validate request body
+	if err = c.Validate(post); err != nil {
+		// 422: Unprocessable entity
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnprocessableEntity, map[string]string{"message": err.Error()}, "	")
+	}
+
+	item, notFound, err := todo.PostChecklistItem(h.store, userId, id, *post)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound {
+		// 404: Not found
+		c.Logger().Debug("todo not found")
+		return echo.ErrNotFound
+	}
+
+	// 201: Created
+	return c.JSONPretty(http.StatusCreated, item, "	")
+}
+
+func (h *Handler) PatchChecklistItem(c echo.Context) error {
+	// Check `Content-Type`
+	if !strings.Contains(c.Request().Header.Get("Content-Type"), "application/json") {
+		// 415: Invalid `Content-Type`
+		return c.JSONPretty(http.StatusUnsupportedMediaType, map[string]string{"message": "unsupported media type"}, "	")
+	}
+
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// string -> uint64
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+	itemId, err := strconv.ParseUint(c.Param("item_id"), 10, 64)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	// Bind request body
+	patch := new(todo.ChecklistItemPatchBody)
+	if err = c.Bind(patch); err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Validate request body
+	if err = c.Validate(patch); err != nil {
+		// 422: Unprocessable entity
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnprocessableEntity, map[string]string{"message": err.Error()}, "	")
+	}
+
+	item, notFound, itemNotFound, err := todo.PatchChecklistItem(h.store, userId, id, itemId, *patch)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound || itemNotFound {
+		// 404: Not found
+		c.Logger().Debug("checklist item not found")
+		return echo.ErrNotFound
+	}
+
+	// 200: Success
+	return c.JSONPretty(http.StatusOK, item, "	")
+}
+
+func (h *Handler) DeleteChecklistItem(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// string -> uint64
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+	itemId, err := strconv.ParseUint(c.Param("item_id"), 10, 64)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	notFound, itemNotFound, err := todo.DeleteChecklistItem(h.store, userId, id, itemId)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound || itemNotFound {
+		// 404: Not found
+		c.Logger().Debug("checklist item not found")
+		return echo.ErrNotFound
+	}
+
+	// 204: No content
+	return c.JSONPretty(http.StatusNoContent, map[string]string{"message": "Deleted"}, "	")
+}
+
+func (h *Handler) ReorderChecklist(c echo.Context) error {
+	// Check `Content-Type`
+	if !strings.Contains(c.Request().Header.Get("Content-Type"), "application/json") {
+		// 415: Invalid `Content-Type`
+		return c.JSONPretty(http.StatusUnsupportedMediaType, map[string]string{"message": "unsupported media type"}, "	")
+	}
+
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// string -> uint64
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	// Bind request body
+	order := new(todo.ChecklistOrderBody)
+	if err = c.Bind(order); err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Validate request body
+	if err = c.Validate(order); err != nil {
+		// 422: Unprocessable entity
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnprocessableEntity, map[string]string{"message": err.Error()}, "	")
+	}
+
+	checklist, notFound, invalidOrder, err := todo.ReorderChecklist(h.store, userId, id, order.Ids)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound {
+		// 404: Not found
+		c.Logger().Debug("todo not found")
+		return echo.ErrNotFound
+	}
+	if invalidOrder {
+		// 400: Bad request
+		c.Logger().Debug("`ids` must have every item of the checklist once")
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "`ids` must have every item of the checklist once"}, "	")
+	}
+
+	// 200: Success
+	if checklist == nil {
+		return c.JSONPretty(http.StatusOK, []interface{}{}, "	")
+	}
+	return c.JSONPretty(http.StatusOK, checklist, "	")
+}
@@ -11,7 +11,7 @@ import (
 	"github.com/labstack/echo"
 )
 
-func Complete(c echo.Context) error {
+func (h *Handler) Complete(c echo.Context) error {
 	// Check token
 	u := c.Get("user").(*jwtGo.Token)
 	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
@@ -30,7 +30,18 @@ func Complete(c echo.Context) error {
 		return echo.ErrNotFound
 	}
 
-	t, newTodo, notFound, dateNotFound, invalidUnit, err := todo.Complete(userId, id)
+	// Complete blocked todo
+	force := false
+	if forceStr := c.QueryParam("force"); forceStr != "" {
+		force, err = strconv.ParseBool(forceStr)
+		if err != nil {
+			// 400: Bad request
+			c.Logger().Debug(err)
+			return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "invalid force"}, "	")
+		}
+	}
+
+	t, newTodo, notFound, dateNotFound, invalidUnit, blockedBy, err := todo.Complete(h.store, userId, id, force)
 	if err != nil {
 		// 500: Internal Server Error
 		c.Logger().Error(err)
@@ -40,6 +51,11 @@ func Complete(c echo.Context) error {
 		// 404: Not found
 		return echo.ErrNotFound
 	}
+	if len(blockedBy) != 0 {
+		// 409: Conflict
+		c.Logger().Debug("todo is blocked by incomplete todos")
+		return c.JSONPretty(http.StatusConflict, map[string]interface{}{"message": "todo is blocked by incomplete todos", "blocked_by": blockedBy}, "	")
+	}
 	if invalidUnit {
 		// 400: Bad request
 		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "invalid todo repeat unit"}, "	")
@@ -11,7 +11,7 @@ import (
 	"github.com/labstack/echo"
 )
 
-func Delete(c echo.Context) error {
+func (h *Handler) Delete(c echo.Context) error {
 	// Check token
 	u := c.Get("user").(*jwtGo.Token)
 	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
@@ -30,7 +30,7 @@ func Delete(c echo.Context) error {
 		return echo.ErrNotFound
 	}
 
-	notFound, err := todo.Delete(userId, id)
+	notFound, err := todo.Delete(h.store, userId, id)
 	if err != nil {
 		// 500: Internal server error
 		c.Logger().Error(err)
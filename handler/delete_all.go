@@ -10,7 +10,7 @@ import (
 	"github.com/labstack/echo"
 )
 
-func DeleteAll(c echo.Context) error {
+func (h *Handler) DeleteAll(c echo.Context) error {
 	// Check token
 	u := c.Get("user").(*jwtGo.Token)
 	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
@@ -19,7 +19,7 @@ func DeleteAll(c echo.Context) error {
 		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
 	}
 
-	err = todo.DeleteAll(userId)
+	err = todo.DeleteAll(h.store, userId)
 	if err != nil {
 		// 500: Internal server error
 		c.Logger().Error(err)
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"flow-todos/flags"
+	"flow-todos/jwt"
+	"flow-todos/todo"
+	"net/http"
+	"strings"
+	"time"
+
+	jwtGo "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+)
+
+const mimeTextCalendar = "text/calendar; charset=utf-8"
+
+func (h *Handler) ExportICalendar(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Bind query
+	query := new(ListFilterQuery)
+	if err = c.Bind(query); err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Validate query
+	if err = c.Validate(query); err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+	if query.Completed == nil && !query.WithCompleted {
+		// Completed todos are exported with their status by default
+		include := "include"
+		query.Completed = &include
+	}
+	queryParsed, err := query.parse()
+	if err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+
+	ics, err := todo.Export(h.store, userId, queryParsed, time.Now())
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// 200: Success
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="todos.ics"`)
+	return c.Blob(http.StatusOK, mimeTextCalendar, []byte(ics))
+}
+
+// Export dumps all todos of the user to be restored by `POST /import`
+func (h *Handler) Export(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		// 400: Bad request
+		c.Logger().Debug("invalid format")
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "invalid format, json or csv"}, "	")
+	}
+
+	d, err := todo.NewDump(h.store, userId, time.Now())
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+
+	if format == "csv" {
+		b, err := d.CSV()
+		if err != nil {
+			// 500: Internal server error
+			c.Logger().Error(err)
+			return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+		}
+		// 200: Success
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="todos.csv"`)
+		return c.Blob(http.StatusOK, "text/csv; charset=utf-8", b)
+	}
+
+	// 200: Success
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="todos.json"`)
+	return c.JSONPretty(http.StatusOK, d, "	")
+}
+
+// Feed authenticated by the token in the URL instead of JWT, for calendar apps
+func (h *Handler) Feed(c echo.Context) error {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+	userId, notFound, err := todo.FeedUser(h.store, token)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound {
+		// 404: Not found
+		c.Logger().Debug("feed token not found")
+		return echo.ErrNotFound
+	}
+
+	ics, err := todo.Export(h.store, userId, todo.GetListQuery{WithCompleted: true}, time.Now())
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// 200: Success
+	return c.Blob(http.StatusOK, mimeTextCalendar, []byte(ics))
+}
+
+func (h *Handler) PostFeedToken(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	token, err := todo.NewFeedToken(h.store, userId)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Feed URL next to this endpoint
+	base := strings.TrimSuffix(c.Request().URL.Path, "feed-token")
+	url := c.Scheme() + "://" + c.Request().Host + base + "feed/" + token + ".ics"
+
+	// 201: Created
+	return c.JSONPretty(http.StatusCreated, map[string]string{"token": token, "url": url}, "	")
+}
+
+func (h *Handler) DeleteFeedToken(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	notFound, err := todo.DeleteFeedToken(h.store, userId)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound {
+		// 404: Not found
+		c.Logger().Debug("feed token not found")
+		return echo.ErrNotFound
+	}
+
+	// 204: No content
+	return c.JSONPretty(http.StatusNoContent, map[string]string{"message": "Deleted"}, "	")
+}
@@ -13,12 +13,74 @@ import (
 	"github.com/labstack/echo"
 )
 
+// Filters of the list and the search
+type ListFilterQuery struct {
+	Start            *string  `query:"start" validate:"omitempty,datetime"`
+	End              *string  `query:"end" validate:"omitempty,datetime"`
+	ProjectId        *uint64  `query:"project_id" validate:"omitempty,gte=1"`
+	ProjectIds       []uint64 `query:"project_id[]" validate:"omitempty,dive,gte=1"`
+	SprintId         *uint64  `query:"sprint_id" validate:"omitempty,gte=1"`
+	WithCompleted    bool     `query:"with_completed" validate:"omitempty"`
+	Completed        *string  `query:"completed" validate:"omitempty,oneof=exclude include only"`
+	HasDate          *bool    `query:"has_date" validate:"omitempty"`
+	IsRepeating      *bool    `query:"is_repeating" validate:"omitempty"`
+	MinExecutionTime *uint    `query:"min_execution_time" validate:"omitempty"`
+	MaxExecutionTime *uint    `query:"max_execution_time" validate:"omitempty"`
+	Priorities       []uint   `query:"priority" validate:"omitempty,dive,lte=4"`
+	Blocked          *bool    `query:"blocked" validate:"omitempty"`
+	Labels           []uint64 `query:"label" validate:"omitempty,dive,gte=1"`
+}
+
 type GetListQuery struct {
-	Start               *string `query:"start" validate:"omitempty,datetime"`
-	End                 *string `query:"end" validate:"omitempty,datetime"`
-	ProjectId           *uint64 `query:"project_id" validate:"omitempty,gte=1"`
-	WithCompleted       bool    `query:"with_completed" validate:"omitempty"`
+	ListFilterQuery
 	WithRepeatSchedules bool    `query:"with_repeat_schedules" validate:"omitempty"`
+	Q                   *string `query:"q" validate:"omitempty,min=1,max=255"`
+	Sort                *string `query:"sort" validate:"omitempty,oneof=date time name execution_time created_at updated_at priority"`
+	Order               *string `query:"order" validate:"omitempty,oneof=asc desc"`
+	Limit               *uint   `query:"limit" validate:"omitempty,gte=1,lte=1000"`
+	Cursor              *string `query:"cursor" validate:"omitempty"`
+}
+
+// Filters as `todo.GetListQuery`
+func (f *ListFilterQuery) parse() (q todo.GetListQuery, err error) {
+	if f.Start != nil {
+		var start time.Time
+		start, err = datetimeStrConv(*f.Start)
+		if err != nil {
+			return
+		}
+		q.Start = &start
+	}
+	if f.End != nil {
+		var end time.Time
+		end, err = datetimeStrConv(*f.End)
+		if err != nil {
+			return
+		}
+		q.End = &end
+	}
+	if f.ProjectId != nil || f.ProjectIds != nil {
+		// Todos in any of the projects
+		q.ProjectIds = f.ProjectIds
+		if f.ProjectId != nil {
+			q.ProjectIds = append(q.ProjectIds, *f.ProjectId)
+		}
+	}
+	q.SprintId = f.SprintId
+	q.WithCompleted = f.WithCompleted
+	if f.Completed != nil {
+		// Overrides `with_completed`
+		q.WithCompleted = *f.Completed == "include"
+		q.OnlyCompleted = *f.Completed == "only"
+	}
+	q.HasDate = f.HasDate
+	q.IsRepeating = f.IsRepeating
+	q.MinExecutionTime = f.MinExecutionTime
+	q.MaxExecutionTime = f.MaxExecutionTime
+	q.Priorities = f.Priorities
+	q.Blocked = f.Blocked
+	q.Labels = f.Labels
+	return
 }
 
 func datetimeStrConv(str string) (t time.Time, err error) {
@@ -40,7 +102,7 @@ func datetimeStrConv(str string) (t time.Time, err error) {
 	return
 }
 
-func GetList(c echo.Context) error {
+func (h *Handler) GetList(c echo.Context) error {
 	// Check token
 	u := c.Get("user").(*jwtGo.Token)
 	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
@@ -63,39 +125,51 @@ func GetList(c echo.Context) error {
 		c.Logger().Debug(err)
 		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
 	}
-	var start, end *time.Time
-	if query.Start != nil {
-		startTmp, err := datetimeStrConv(*query.Start)
-		if err != nil {
-			// 400: Bad request
-			c.Logger().Debug(err)
-			return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
-		}
-		start = &startTmp
-	}
-	if query.End != nil {
-		endTmp, err := datetimeStrConv(*query.End)
-		if err != nil {
-			// 400: Bad request
-			c.Logger().Debug(err)
-			return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
-		}
-		end = &endTmp
+	queryParsed, err := query.parse()
+	if err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
 	}
 	if query.WithRepeatSchedules && query.End == nil {
 		// 400: Bad request
 		c.Logger().Debug("\"end\" required to get repeat schedules")
 		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "\"end\" required to get repeat schedules"}, "	")
 	}
-	queryParsed := todo.GetListQuery{Start: start, End: end, ProjectId: query.ProjectId, WithCompleted: query.WithCompleted, WithRepeatSchedules: query.WithRepeatSchedules}
+	queryParsed.WithRepeatSchedules = query.WithRepeatSchedules
+	queryParsed.Text = query.Q
+	queryParsed.Cursor = query.Cursor
+	if query.Sort != nil {
+		queryParsed.Sort = *query.Sort
+	}
+	if query.Order != nil {
+		queryParsed.Order = *query.Order
+	}
+	if query.Limit != nil {
+		queryParsed.Limit = *query.Limit
+	}
 
 	// Get todos
-	todos, err := todo.GetList(userId, queryParsed)
+	todos, nextCursor, invalidCursor, err := todo.GetList(h.store, userId, queryParsed)
 	if err != nil {
 		// 500: Internal server error
 		c.Logger().Error(err)
 		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
 	}
+	if invalidCursor {
+		// 400: Bad request
+		c.Logger().Debug("invalid cursor")
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "invalid cursor"}, "	")
+	}
+
+	if nextCursor != nil {
+		// Link to the next page
+		next := *c.Request().URL
+		params := next.Query()
+		params.Set("cursor", *nextCursor)
+		next.RawQuery = params.Encode()
+		c.Response().Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
 
 	if todos == nil {
 		return c.JSONPretty(http.StatusOK, []interface{}{}, "	")
@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"flow-todos/todo"
+	"flow-todos/webhook"
+)
+
+type Handler struct {
+	store        todo.TodoStore
+	webhookStore webhook.Store
+	webhooks     *webhook.Dispatcher
+}
+
+func New(s todo.TodoStore, ws webhook.Store, d *webhook.Dispatcher) *Handler {
+	return &Handler{store: s, webhookStore: ws, webhooks: d}
+}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"encoding/json"
+	"flow-todos/flags"
+	"flow-todos/importer"
+	"flow-todos/jwt"
+	"flow-todos/todo"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	jwtGo "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+)
+
+// Import dispatches on `Content-Type`: iCalendar is imported as new todos, JSON and CSV dumps are restored
+func (h *Handler) Import(c echo.Context) error {
+	contentType := c.Request().Header.Get("Content-Type")
+	switch {
+	case strings.Contains(contentType, "text/calendar"):
+		return h.ImportICalendar(c)
+	case strings.Contains(contentType, echo.MIMEApplicationJSON), strings.Contains(contentType, "text/csv"):
+		return h.Restore(c)
+	}
+	// 415: Invalid `Content-Type`
+	return c.JSONPretty(http.StatusUnsupportedMediaType, map[string]string{"message": "unsupported media type"}, "	")
+}
+
+func (h *Handler) ImportICalendar(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Validate without creating todos
+	dryRun := false
+	if dryRunStr := c.QueryParam("dry_run"); dryRunStr != "" {
+		dryRun, err = strconv.ParseBool(dryRunStr)
+		if err != nil {
+			// 400: Bad request
+			c.Logger().Debug(err)
+			return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "invalid dry_run"}, "	")
+		}
+	}
+
+	// Read request body
+	body, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+	components, invalid := todo.ParseICalendar(string(body))
+	if invalid {
+		// 400: Bad request
+		c.Logger().Debug("invalid iCalendar")
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "invalid iCalendar, VCALENDAR required"}, "	")
+	}
+
+	results, err := todo.Import(h.store, userId, components, dryRun, func(post todo.PostBody) error {
+		return c.Validate(&post)
+	})
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+
+	succeeded, failed := 0, 0
+	for _, r := range results {
+		if r.Status == todo.ImportFailed {
+			failed++
+		} else {
+			succeeded++
+		}
+	}
+
+	// 200: Success
+	return c.JSONPretty(http.StatusOK, map[string]interface{}{"dry_run": dryRun, "succeeded": succeeded, "failed": failed, "results": results}, "	")
+}
+
+func (h *Handler) Restore(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Strategy for todos which are already restored
+	conflict := c.QueryParam("conflict")
+	switch conflict {
+	case "":
+		conflict = todo.ConflictSkip
+	case todo.ConflictSkip, todo.ConflictOverwrite, todo.ConflictDuplicate:
+	default:
+		// 400: Bad request
+		c.Logger().Debug("invalid conflict")
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "invalid conflict, skip, overwrite or duplicate"}, "	")
+	}
+
+	// Validate without restoring todos
+	dryRun := false
+	if dryRunStr := c.QueryParam("dry_run"); dryRunStr != "" {
+		dryRun, err = strconv.ParseBool(dryRunStr)
+		if err != nil {
+			// 400: Bad request
+			c.Logger().Debug(err)
+			return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "invalid dry_run"}, "	")
+		}
+	}
+
+	// Parse dump
+	var d todo.Dump
+	if strings.Contains(c.Request().Header.Get("Content-Type"), "text/csv") {
+		d, err = todo.ParseCSVDump(c.Request().Body)
+	} else {
+		err = json.NewDecoder(c.Request().Body).Decode(&d)
+	}
+	if err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "invalid dump: " + err.Error()}, "	")
+	}
+	if d.Version > todo.DumpVersion {
+		// 400: Bad request
+		c.Logger().Debug("unsupported dump version")
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("unsupported dump version %d", d.Version)}, "	")
+	}
+
+	results, err := todo.Restore(h.store, userId, d, conflict, dryRun)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+
+	counts := map[string]int{todo.RestoreCreated: 0, todo.RestoreOverwritten: 0, todo.RestoreSkipped: 0, todo.RestoreFailed: 0}
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	if results == nil {
+		results = []todo.RestoreResult{}
+	}
+
+	// 200: Success
+	return c.JSONPretty(http.StatusOK, map[string]interface{}{"dry_run": dryRun, "conflict": conflict, "counts": counts, "results": results}, "	")
+}
+
+// ImportProvider imports the JSON export of another todo app
+func (h *Handler) ImportProvider(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	provider := c.Param("provider")
+	if provider != importer.ProviderTodoist && provider != importer.ProviderTrello {
+		// 404: Not found
+		c.Logger().Debugf("unknown provider `%s`", provider)
+		return echo.ErrNotFound
+	}
+
+	// Validate without creating todos
+	dryRun := false
+	if dryRunStr := c.QueryParam("dry_run"); dryRunStr != "" {
+		dryRun, err = strconv.ParseBool(dryRunStr)
+		if err != nil {
+			// 400: Bad request
+			c.Logger().Debug(err)
+			return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "invalid dry_run"}, "	")
+		}
+	}
+
+	// Projects of the provider to project ids
+	projects, err := importer.ParseProjectMap(c.QueryParams()["project_map"])
+	if err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+	exists, err := existingIds(*flags.Get().ServiceUrlProjects, projects.ProjectIds(), &u.Raw)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	for _, id := range projects.ProjectIds() {
+		if !exists[id] {
+			// 400: Bad request
+			c.Logger().Debugf("project id: %d does not exist", id)
+			return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("project id: %d does not exist", id)}, "	")
+		}
+	}
+
+	// Read request body
+	body, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+	items, err := importer.Convert(provider, body, projects)
+	if err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("invalid %s export: %s", provider, err)}, "	")
+	}
+
+	results, err := importer.Import(h.store, userId, items, dryRun, func(post todo.PostBody) error {
+		return c.Validate(&post)
+	})
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+
+	succeeded, failed, skipped := 0, 0, 0
+	for _, r := range results {
+		switch r.Status {
+		case importer.StatusFailed:
+			failed++
+		case importer.StatusSkipped:
+			skipped++
+		default:
+			succeeded++
+		}
+	}
+	if results == nil {
+		results = []importer.Result{}
+	}
+
+	// 200: Success
+	return c.JSONPretty(http.StatusOK, map[string]interface{}{"provider": provider, "dry_run": dryRun, "succeeded": succeeded, "failed": failed, "skipped": skipped, "results": results}, "	")
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"flow-todos/flags"
+	"flow-todos/jwt"
+	"flow-todos/todo"
+	"net/http"
+	"strconv"
+	"strings"
+
+	jwtGo "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+)
+
+func (h *Handler) GetLabels(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	labels, err := todo.GetLabels(h.store, userId)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+
+	if labels == nil {
+		return c.JSONPretty(http.StatusOK, []interface{}{}, "	")
+	}
+	return c.JSONPretty(http.StatusOK, labels, "	")
+}
+
+func (h *Handler) GetLabel(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// string -> uint64
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	l, notFound, err := todo.GetLabel(h.store, userId, id)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound {
+		// 404: Not found
+		c.Logger().Debug("label not found")
+		return echo.ErrNotFound
+	}
+
+	// 200: Success
+	return c.JSONPretty(http.StatusOK, l, "	")
+}
+
+func (h *Handler) PostLabel(c echo.Context) error {
+	// Check `Content-Type`
+	if !strings.Contains(c.Request().Header.Get("Content-Type"), "application/json") {
+		// 415: Invalid `Content-Type`
+		return c.JSONPretty(http.StatusUnsupportedMediaType, map[string]string{"message": "unsupported media type"}, "	")
+	}
+
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Bind request body
+	post := new(todo.LabelPostBody)
+	if err = c.Bind(post); err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Validate request body
+	if err = c.Validate(post); err != nil {
+		// 422: Unprocessable entity
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnprocessableEntity, map[string]string{"message": err.Error()}, "	")
+	}
+
+	l, nameConflict, err := todo.PostLabel(h.store, userId, *post)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if nameConflict {
+		// 409: Conflict
+		c.Logger().Debug("label name already exists")
+		return c.JSONPretty(http.StatusConflict, map[string]string{"message": "label name already exists"}, "	")
+	}
+
+	// 201: Created
+	return c.JSONPretty(http.StatusCreated, l, "	")
+}
+
+func (h *Handler) PatchLabel(c echo.Context) error {
+	// Check `Content-Type`
+	if !strings.Contains(c.Request().Header.Get("Content-Type"), "application/json") {
+		// 415: Invalid `Content-Type`
+		return c.JSONPretty(http.StatusUnsupportedMediaType, map[string]string{"message": "unsupported media type"}, "	")
+	}
+
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// string -> uint64
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	// Bind request body
+	patch := new(todo.LabelPatchBody)
+	if err = c.Bind(patch); err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Validate request body
+	if err = c.Validate(patch); err != nil {
+		// 422: Unprocessable entity
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnprocessableEntity, map[string]string{"message": err.Error()}, "	")
+	}
+
+	l, notFound, nameConflict, err := todo.PatchLabel(h.store, userId, id, *patch)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound {
+		// 404: Not found
+		c.Logger().Debug("label not found")
+		return echo.ErrNotFound
+	}
+	if nameConflict {
+		// 409: Conflict
+		c.Logger().Debug("label name already exists")
+		return c.JSONPretty(http.StatusConflict, map[string]string{"message": "label name already exists"}, "	")
+	}
+
+	// 200: Success
+	return c.JSONPretty(http.StatusOK, l, "	")
+}
+
+func (h *Handler) DeleteLabel(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// string -> uint64
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	notFound, err := todo.DeleteLabel(h.store, userId, id)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound {
+		// 404: Not found
+		c.Logger().Debug("label not found")
+		return echo.ErrNotFound
+	}
+
+	// 204: No content
+	return c.JSONPretty(http.StatusNoContent, map[string]string{"message": "Deleted"}, "	")
+}
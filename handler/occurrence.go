@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"flow-todos/flags"
+	"flow-todos/jwt"
+	"flow-todos/todo"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	jwtGo "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+)
+
+func (h *Handler) PatchOccurrence(c echo.Context) error {
+	// Check `Content-Type`
+	if !strings.Contains(c.Request().Header.Get("Content-Type"), "application/json") {
+		// 415: Invalid `Content-Type`
+		return c.JSONPretty(http.StatusUnsupportedMediaType, map[string]string{"message": "unsupported media type"}, "	")
+	}
+
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// id
+	idStr := c.Param("id")
+
+	// string -> uint64
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	// date
+	date, err := time.Parse("2006-1-2", c.Param("date"))
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	// Bind request body
+	patch := new(todo.OccurrencePatchBody)
+	if err = c.Bind(patch); err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Validate request body
+	if err = c.Validate(patch); err != nil {
+		// 422: Unprocessable entity
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnprocessableEntity, map[string]string{"message": err.Error()}, "	")
+	}
+
+	e, notFound, repeatNotFound, notOccurrence, err := todo.PatchOccurrence(h.store, userId, id, date, *patch)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound {
+		// 404: Not found
+		c.Logger().Debug("todo not found")
+		return echo.ErrNotFound
+	}
+	if repeatNotFound {
+		// 400: Bad request
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "repeat not found"}, "	")
+	}
+	if notOccurrence {
+		// 400: Bad request
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "date is not a future occurrence of the repeat"}, "	")
+	}
+
+	// 200: Success
+	return c.JSONPretty(http.StatusOK, e, "	")
+}
+
+func (h *Handler) DeleteOccurrence(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// id
+	idStr := c.Param("id")
+
+	// string -> uint64
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	// date
+	date, err := time.Parse("2006-1-2", c.Param("date"))
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	notFound, repeatNotFound, exceptionNotFound, err := todo.DeleteOccurrence(h.store, userId, id, date)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound || exceptionNotFound {
+		// 404: Not found
+		c.Logger().Debug("occurrence exception not found")
+		return echo.ErrNotFound
+	}
+	if repeatNotFound {
+		// 400: Bad request
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "repeat not found"}, "	")
+	}
+
+	// 204: No content
+	return c.JSONPretty(http.StatusNoContent, map[string]string{"message": "Deleted"}, "	")
+}
@@ -14,7 +14,7 @@ import (
 	"github.com/labstack/echo"
 )
 
-func Patch(c echo.Context) error {
+func (h *Handler) Patch(c echo.Context) error {
 	// Check `Content-Type`
 	if !strings.Contains(c.Request().Header.Get("Content-Type"), "application/json") {
 		// 415: Invalid `Content-Type`
@@ -39,6 +39,17 @@ func Patch(c echo.Context) error {
 		return echo.ErrNotFound
 	}
 
+	// Scope of update on repeating todo
+	scope := c.QueryParam("scope")
+	if scope == "" {
+		scope = todo.PatchScopeAll
+	}
+	if scope != todo.PatchScopeAll && scope != todo.PatchScopeThis && scope != todo.PatchScopeThisAndFollowing {
+		// 400: Bad request
+		c.Logger().Debug("invalid scope")
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "`scope` must be one of `this`, `this_and_following` or `all`"}, "	")
+	}
+
 	// Bind request body
 	patch := new(todo.PatchBody)
 	if err = c.Bind(patch); err != nil {
@@ -84,7 +95,42 @@ func Patch(c echo.Context) error {
 		}
 	}
 
-	p, notFound, dateNotFound, dateOverUtil, noDaysWithWeekly, err := todo.Patch(userId, id, *patch)
+	// Check labels
+	if patch.Labels != nil && len(*patch.Labels) != 0 {
+		labelNotFound, err := todo.CheckLabels(h.store, userId, *patch.Labels)
+		if err != nil {
+			// 500: Internal server error
+			c.Logger().Error(err)
+			return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+		}
+		if labelNotFound != nil {
+			// 400: Bad request
+			c.Logger().Debugf("label id: %d does not exist", *labelNotFound)
+			return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("label id: %d does not exist", *labelNotFound)}, "	")
+		}
+	}
+
+	// Check blockers
+	if patch.BlockedBy != nil && len(*patch.BlockedBy) != 0 {
+		blockerNotFound, cycle, err := todo.CheckDependencies(h.store, userId, id, *patch.BlockedBy)
+		if err != nil {
+			// 500: Internal server error
+			c.Logger().Error(err)
+			return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+		}
+		if blockerNotFound != nil {
+			// 400: Bad request
+			c.Logger().Debugf("todo id: %d does not exist", *blockerNotFound)
+			return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("todo id: %d does not exist", *blockerNotFound)}, "	")
+		}
+		if cycle {
+			// 400: Bad request
+			c.Logger().Debug("`blocked_by` makes a dependency cycle")
+			return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "`blocked_by` makes a dependency cycle"}, "	")
+		}
+	}
+
+	p, notFound, dateNotFound, dateOverUtil, noDaysWithWeekly, repeatWithScopeThis, err := todo.Patch(h.store, userId, id, *patch, scope)
 	if err != nil {
 		// 500: Internal server error
 		c.Logger().Error(err)
@@ -105,6 +151,11 @@ func Patch(c echo.Context) error {
 		c.Logger().Debug("`date` must until `repeat.until`")
 		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "`date` must until `repeat.until`"}, "	")
 	}
+	if repeatWithScopeThis {
+		// 400: Bad request
+		c.Logger().Debug("`repeat` cannot be updated with `scope=this`")
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "`repeat` cannot be updated with `scope=this`"}, "	")
+	}
 	if noDaysWithWeekly {
 		// 400: Bad request
 		c.Logger().Debug("`repeat.days` required with `repeat.unit: \"week\"`")
@@ -13,7 +13,7 @@ import (
 	"github.com/labstack/echo"
 )
 
-func Post(c echo.Context) error {
+func (h *Handler) Post(c echo.Context) error {
 	// Check `Content-Type`
 	if !strings.Contains(c.Request().Header.Get("Content-Type"), "application/json") {
 		// 415: Invalid `Content-Type`
@@ -73,7 +73,37 @@ func Post(c echo.Context) error {
 		}
 	}
 
-	p, dateNotFound, dateOverUntil, noDaysWithWeekly, err := todo.Post(userId, *post)
+	// Check labels
+	if len(post.Labels) != 0 {
+		labelNotFound, err := todo.CheckLabels(h.store, userId, post.Labels)
+		if err != nil {
+			// 500: Internal server error
+			c.Logger().Error(err)
+			return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+		}
+		if labelNotFound != nil {
+			// 400: Bad request
+			c.Logger().Debugf("label id: %d does not exist", *labelNotFound)
+			return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("label id: %d does not exist", *labelNotFound)}, "	")
+		}
+	}
+
+	// Check blockers
+	if len(post.BlockedBy) != 0 {
+		blockerNotFound, _, err := todo.CheckDependencies(h.store, userId, 0, post.BlockedBy)
+		if err != nil {
+			// 500: Internal server error
+			c.Logger().Error(err)
+			return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+		}
+		if blockerNotFound != nil {
+			// 400: Bad request
+			c.Logger().Debugf("todo id: %d does not exist", *blockerNotFound)
+			return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("todo id: %d does not exist", *blockerNotFound)}, "	")
+		}
+	}
+
+	p, dateNotFound, dateOverUntil, noDaysWithWeekly, err := todo.Post(h.store, userId, *post)
 	if err != nil {
 		// 500: Internal server error
 		c.Logger().Error(err)
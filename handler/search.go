@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"flow-todos/flags"
+	"flow-todos/jwt"
+	"flow-todos/todo"
+	"net/http"
+
+	jwtGo "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+)
+
+type SearchQuery struct {
+	ListFilterQuery
+	Q     string `query:"q" validate:"required,max=255"`
+	Limit *uint  `query:"limit" validate:"omitempty,gte=1,lte=100"`
+}
+
+func (h *Handler) Search(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Bind query
+	query := new(SearchQuery)
+	if err = c.Bind(query); err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Validate query
+	if err = c.Validate(query); err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+	queryParsed, err := query.parse()
+	if err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+	queryParsed.Limit = 20
+	if query.Limit != nil {
+		queryParsed.Limit = *query.Limit
+	}
+
+	// Search todos
+	results, err := todo.Search(h.store, userId, query.Q, queryParsed)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+
+	if results == nil {
+		return c.JSONPretty(http.StatusOK, []interface{}{}, "	")
+	}
+	return c.JSONPretty(http.StatusOK, results, "	")
+}
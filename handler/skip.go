@@ -11,7 +11,7 @@ import (
 	"github.com/labstack/echo"
 )
 
-func Skip(c echo.Context) error {
+func (h *Handler) Skip(c echo.Context) error {
 	// Check token
 	u := c.Get("user").(*jwtGo.Token)
 	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
@@ -30,7 +30,7 @@ func Skip(c echo.Context) error {
 		return echo.ErrNotFound
 	}
 
-	t, overUntil, notFound, repeatNotFound, dateNotFound, invalidUnit, err := todo.Skip(userId, id)
+	t, overUntil, notFound, repeatNotFound, dateNotFound, invalidUnit, err := todo.Skip(h.store, userId, id)
 	if err != nil {
 		// 500: Internal Server Error
 		c.Logger().Error(err)
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"flow-todos/flags"
+	"flow-todos/jwt"
+	"flow-todos/todo"
+	"net/http"
+	"strconv"
+
+	jwtGo "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+)
+
+func (h *Handler) Uncomplete(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// id
+	idStr := c.Param("id")
+
+	// string -> uint64
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	t, notFound, operationNotFound, conflict, err := todo.Uncomplete(h.store, userId, id)
+	if err != nil {
+		// 500: Internal Server Error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+	if operationNotFound {
+		// 400: Bad request
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": "no completion to undo"}, "	")
+	}
+	if conflict {
+		// 409: Conflict
+		return c.JSONPretty(http.StatusConflict, map[string]string{"message": "todo was changed after the completion"}, "	")
+	}
+
+	// 200: Success
+	return c.JSONPretty(http.StatusOK, t, "	")
+}
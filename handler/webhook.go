@@ -0,0 +1,347 @@
+package handler
+
+import (
+	"flow-todos/flags"
+	"flow-todos/jwt"
+	"flow-todos/webhook"
+	"net/http"
+	"strconv"
+	"strings"
+
+	jwtGo "github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+)
+
+type DeliveriesQuery struct {
+	// Deliveries older than the delivery id
+	Before *uint64 `query:"before" validate:"omitempty,gte=1"`
+	Limit  *int    `query:"limit" validate:"omitempty,gte=1,lte=100"`
+}
+
+// Parse `:id` and `:delivery_id`
+func webhookParams(c echo.Context) (id uint64, deliveryId uint64, err error) {
+	id, err = strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return
+	}
+	if c.Param("delivery_id") != "" {
+		deliveryId, err = strconv.ParseUint(c.Param("delivery_id"), 10, 64)
+	}
+	return
+}
+
+func (h *Handler) GetWebhooks(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	subscriptions, err := webhook.GetSubscriptions(h.webhookStore, userId)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+
+	if subscriptions == nil {
+		return c.JSONPretty(http.StatusOK, []interface{}{}, "	")
+	}
+	return c.JSONPretty(http.StatusOK, subscriptions, "	")
+}
+
+func (h *Handler) GetWebhook(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// string -> uint64
+	id, _, err := webhookParams(c)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	sub, notFound, err := webhook.GetSubscription(h.webhookStore, userId, id)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound {
+		// 404: Not found
+		c.Logger().Debug("webhook not found")
+		return echo.ErrNotFound
+	}
+
+	// 200: Success
+	return c.JSONPretty(http.StatusOK, sub, "	")
+}
+
+func (h *Handler) PostWebhook(c echo.Context) error {
+	// Check `Content-Type`
+	if !strings.Contains(c.Request().Header.Get("Content-Type"), "application/json") {
+		// 415: Invalid `Content-Type`
+		return c.JSONPretty(http.StatusUnsupportedMediaType, map[string]string{"message": "unsupported media type"}, "	")
+	}
+
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Bind request body
+	post := new(webhook.SubscriptionPostBody)
+	if err = c.Bind(post); err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Validate request body
+	if err = c.Validate(post); err != nil {
+		// 422: Unprocessable entity
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnprocessableEntity, map[string]string{"message": err.Error()}, "	")
+	}
+
+	sub, invalidUrl, err := webhook.PostSubscription(h.webhookStore, userId, *post)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if invalidUrl {
+		// 422: Unprocessable entity
+		c.Logger().Debug("url must be http or https to a public address")
+		return c.JSONPretty(http.StatusUnprocessableEntity, map[string]string{"message": "`url` must be http or https to a public address"}, "	")
+	}
+
+	// 201: Created
+	return c.JSONPretty(http.StatusCreated, sub, "	")
+}
+
+func (h *Handler) PatchWebhook(c echo.Context) error {
+	// Check `Content-Type`
+	if !strings.Contains(c.Request().Header.Get("Content-Type"), "application/json") {
+		// 415: Invalid `Content-Type`
+		return c.JSONPretty(http.StatusUnsupportedMediaType, map[string]string{"message": "unsupported media type"}, "	")
+	}
+
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// string -> uint64
+	id, _, err := webhookParams(c)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	// Bind request body
+	patch := new(webhook.SubscriptionPatchBody)
+	if err = c.Bind(patch); err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Validate request body
+	if err = c.Validate(patch); err != nil {
+		// 422: Unprocessable entity
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnprocessableEntity, map[string]string{"message": err.Error()}, "	")
+	}
+
+	sub, notFound, invalidUrl, err := webhook.PatchSubscription(h.webhookStore, userId, id, *patch)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound {
+		// 404: Not found
+		c.Logger().Debug("webhook not found")
+		return echo.ErrNotFound
+	}
+	if invalidUrl {
+		// 422: Unprocessable entity
+		c.Logger().Debug("url must be http or https to a public address")
+		return c.JSONPretty(http.StatusUnprocessableEntity, map[string]string{"message": "`url` must be http or https to a public address"}, "	")
+	}
+
+	// 200: Success
+	return c.JSONPretty(http.StatusOK, sub, "	")
+}
+
+func (h *Handler) DeleteWebhook(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// string -> uint64
+	id, _, err := webhookParams(c)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	notFound, err := webhook.DeleteSubscription(h.webhookStore, userId, id)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound {
+		// 404: Not found
+		c.Logger().Debug("webhook not found")
+		return echo.ErrNotFound
+	}
+
+	// 204: No content
+	return c.JSONPretty(http.StatusNoContent, map[string]string{"message": "Deleted"}, "	")
+}
+
+func (h *Handler) GetWebhookDeliveries(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// string -> uint64
+	id, _, err := webhookParams(c)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	// Bind query
+	query := new(DeliveriesQuery)
+	if err = c.Bind(query); err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// Validate query
+	if err = c.Validate(query); err != nil {
+		// 400: Bad request
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusBadRequest, map[string]string{"message": err.Error()}, "	")
+	}
+	before := uint64(0)
+	if query.Before != nil {
+		before = *query.Before
+	}
+	limit := webhook.DefaultDeliveriesLimit
+	if query.Limit != nil {
+		limit = *query.Limit
+	}
+
+	deliveries, notFound, err := webhook.GetDeliveries(h.webhookStore, userId, id, before, limit)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound {
+		// 404: Not found
+		c.Logger().Debug("webhook not found")
+		return echo.ErrNotFound
+	}
+
+	if deliveries == nil {
+		return c.JSONPretty(http.StatusOK, []interface{}{}, "	")
+	}
+	return c.JSONPretty(http.StatusOK, deliveries, "	")
+}
+
+func (h *Handler) GetWebhookDelivery(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// string -> uint64
+	id, deliveryId, err := webhookParams(c)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	d, notFound, err := webhook.GetDelivery(h.webhookStore, userId, id, deliveryId)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound {
+		// 404: Not found
+		c.Logger().Debug("delivery not found")
+		return echo.ErrNotFound
+	}
+
+	// 200: Success
+	return c.JSONPretty(http.StatusOK, d, "	")
+}
+
+func (h *Handler) RedeliverWebhook(c echo.Context) error {
+	// Check token
+	u := c.Get("user").(*jwtGo.Token)
+	userId, err := jwt.CheckToken(*flags.Get().JwtIssuer, u)
+	if err != nil {
+		c.Logger().Debug(err)
+		return c.JSONPretty(http.StatusUnauthorized, map[string]string{"message": err.Error()}, "	")
+	}
+
+	// string -> uint64
+	id, deliveryId, err := webhookParams(c)
+	if err != nil {
+		// 404: Not found
+		return echo.ErrNotFound
+	}
+
+	d, notFound, inactive, err := h.webhooks.Redeliver(userId, id, deliveryId)
+	if err != nil {
+		// 500: Internal server error
+		c.Logger().Error(err)
+		return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+	}
+	if notFound {
+		// 404: Not found
+		c.Logger().Debug("delivery not found")
+		return echo.ErrNotFound
+	}
+	if inactive {
+		// 409: Conflict
+		c.Logger().Debug("webhook is inactive")
+		return c.JSONPretty(http.StatusConflict, map[string]string{"message": "webhook is inactive"}, "	")
+	}
+
+	// 202: Accepted
+	return c.JSONPretty(http.StatusAccepted, d, "	")
+}
@@ -0,0 +1,218 @@
+// Package importer converts the JSON exports of other todo apps to todos.
+package importer
+
+import (
+	"errors"
+	"flow-todos/todo"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Supported apps
+const (
+	ProviderTodoist = "todoist"
+	ProviderTrello  = "trello"
+)
+
+// Status of an imported item
+const (
+	StatusCreated = todo.ImportCreated
+	// Would be created, in dry run
+	StatusValid  = todo.ImportValid
+	StatusFailed = todo.ImportFailed
+	// Not imported as a todo, like a subtask merged into a checklist
+	StatusSkipped = "skipped"
+)
+
+// Color of labels without a color
+const defaultColor = "#808080"
+
+// Item of another app converted to the body of `POST /`
+type Item struct {
+	// Id in the other app
+	SourceId string
+	Post     todo.PostBody
+	// Matched to the labels of the user by name, created if missing
+	Labels   []todo.Label
+	Warnings []string
+	// Reason the item is not imported
+	Skipped string
+	Failure string
+}
+
+type Result struct {
+	Index    int    `json:"index"`
+	SourceId string `json:"source_id"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	// Created todo, without id in dry run
+	Todo *todo.Todo `json:"todo,omitempty"`
+	// Lossy conversions
+	Warnings []string `json:"warnings,omitempty"`
+	// Reason of the failure or why the item is skipped
+	Error string `json:"error,omitempty"`
+}
+
+// Ids of projects (or lists) in the other app to project ids
+type ProjectMap map[string]uint64
+
+var ErrUnknownProvider = errors.New("unknown provider")
+
+// Discards the todos of a dry run
+var errDryRun = errors.New("dry run")
+
+// Convert parses the export of the provider
+func Convert(provider string, data []byte, projects ProjectMap) (items []Item, err error) {
+	switch provider {
+	case ProviderTodoist:
+		return Todoist(data, projects)
+	case ProviderTrello:
+		return Trello(data, projects)
+	}
+	return nil, ErrUnknownProvider
+}
+
+// ProjectIds are the mapped project ids
+func (m ProjectMap) ProjectIds() (ids []uint64) {
+	seen := map[uint64]bool{}
+	for _, id := range m {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return
+}
+
+// ParseProjectMap parses `source_id:project_id` pairs
+func ParseProjectMap(pairs []string) (m ProjectMap, err error) {
+	m = ProjectMap{}
+	for _, pair := range pairs {
+		for _, p := range strings.Split(pair, ",") {
+			i := strings.LastIndex(p, ":")
+			var id uint64
+			if i <= 0 {
+				return nil, fmt.Errorf("invalid project_map `%s`, source_id:project_id", p)
+			}
+			if _, err = fmt.Sscan(p[i+1:], &id); err != nil || id == 0 {
+				return nil, fmt.Errorf("invalid project_map `%s`, source_id:project_id", p)
+			}
+			m[p[:i]] = id
+		}
+	}
+	return
+}
+
+// Project id of the first mapped source id, with a warning if none is mapped
+func (m ProjectMap) project(sourceIds ...string) (id *uint64, warning string) {
+	for _, sourceId := range sourceIds {
+		if id, ok := m[sourceId]; ok {
+			return &id, ""
+		}
+	}
+	if len(sourceIds) == 0 || sourceIds[0] == "" {
+		return
+	}
+	return nil, fmt.Sprintf("project `%s` is not mapped and `project_id` is not set", sourceIds[0])
+}
+
+// Execution time of the duration, at least 15 minutes
+func executionTime(d time.Duration) (minutes uint, warning string) {
+	minutes = todo.RoundExecutionTime(d)
+	if d > 0 && time.Duration(minutes)*time.Minute != d {
+		warning = fmt.Sprintf("duration of %s is rounded to %d minutes", d, minutes)
+	}
+	return
+}
+
+// Checklist item with the name shortened to the maximum length
+func checklistItem(name string, checked bool) (item todo.ChecklistItemPostBody, warning string) {
+	if r := []rune(name); len(r) > 255 {
+		name = string(r[:255])
+		warning = fmt.Sprintf("checklist item `%s...` is shortened to 255 characters", string(r[:20]))
+	}
+	return todo.ChecklistItemPostBody{Name: name, Checked: checked}, warning
+}
+
+// Import creates the todos of the items in a transaction.
+// Labels are matched by name and created if missing.
+// validate checks the converted body like the body of `POST /`.
+// In dry run nothing is created.
+func Import(s todo.TodoStore, userId uint64, items []Item, dryRun bool, validate func(post todo.PostBody) error) (results []Result, err error) {
+	err = s.WithTx(func(s todo.TodoStore) (err error) {
+		results = nil
+		labels, err := s.ListLabels(userId)
+		if err != nil {
+			return
+		}
+		for i, item := range items {
+			r := Result{Index: i, SourceId: item.SourceId, Name: item.Post.Name, Status: StatusFailed, Warnings: item.Warnings, Error: item.Failure}
+			if item.Skipped != "" {
+				r.Status = StatusSkipped
+				r.Error = item.Skipped
+				results = append(results, r)
+				continue
+			}
+			post := item.Post
+			if r.Error == "" {
+				if err := validate(post); err != nil {
+					r.Error = err.Error()
+				}
+			}
+			if r.Error != "" {
+				results = append(results, r)
+				continue
+			}
+
+			// Labels by name
+			for _, l := range item.Labels {
+				var id uint64
+				for _, existing := range labels {
+					if strings.EqualFold(existing.Name, l.Name) {
+						id = existing.Id
+						break
+					}
+				}
+				if id == 0 {
+					l, err = s.InsertLabel(userId, todo.Label{Name: l.Name, Color: strings.ToLower(l.Color)})
+					if err != nil {
+						return
+					}
+					labels = append(labels, l)
+					id = l.Id
+				}
+				post.Labels = append(post.Labels, id)
+			}
+
+			t, dateNotFound, dateOverUntil, noDaysWithWeekly, err := todo.Post(s, userId, post)
+			if err != nil {
+				return err
+			}
+			switch {
+			case dateNotFound:
+				r.Error = "`date` required to set `repeat`"
+			case dateOverUntil:
+				r.Error = "`date` must until `repeat.until`"
+			case noDaysWithWeekly:
+				r.Error = "`repeat.days` required with `repeat.unit: \"week\"`"
+			default:
+				r.Status = StatusCreated
+				if dryRun {
+					r.Status = StatusValid
+					t.Id = 0
+				}
+				r.Todo = &t
+			}
+			results = append(results, r)
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return
+	})
+	if err == errDryRun {
+		err = nil
+	}
+	return
+}
@@ -0,0 +1,402 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"flow-todos/todo"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Id of Todoist, a number in old exports and a string since the Sync API v9
+type todoistId string
+
+func (id *todoistId) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		*id = ""
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		*id = todoistId(str)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	*id = todoistId(n.String())
+	return nil
+}
+
+// Export of the Sync API, or the tasks of the REST API
+type todoistExport struct {
+	Projects []todoistProject `json:"projects"`
+	Items    []todoistTask    `json:"items"`
+	Labels   []todoistLabel   `json:"labels"`
+}
+
+type todoistProject struct {
+	Id   todoistId `json:"id"`
+	Name string    `json:"name"`
+}
+
+type todoistLabel struct {
+	Id    todoistId       `json:"id"`
+	Name  string          `json:"name"`
+	Color json.RawMessage `json:"color"`
+}
+
+type todoistTask struct {
+	Id          todoistId   `json:"id"`
+	Content     string      `json:"content"`
+	Description string      `json:"description"`
+	ProjectId   todoistId   `json:"project_id"`
+	ParentId    todoistId   `json:"parent_id"`
+	Priority    int         `json:"priority"`
+	Due         *todoistDue `json:"due"`
+	Duration    *struct {
+		Amount int    `json:"amount"`
+		Unit   string `json:"unit"`
+	} `json:"duration"`
+	// Names, ids in old exports
+	Labels      []todoistId `json:"labels"`
+	Checked     bool        `json:"checked"`
+	IsCompleted bool        `json:"is_completed"`
+	IsDeleted   bool        `json:"is_deleted"`
+	ChildOrder  int         `json:"child_order"`
+	Order       int         `json:"order"`
+}
+
+type todoistDue struct {
+	// `YYYY-MM-DD`, `YYYY-MM-DDTHH:MM:SS` or `YYYY-MM-DDTHH:MM:SSZ` with time zone
+	Date string `json:"date"`
+	// REST API
+	Datetime    string `json:"datetime"`
+	Timezone    string `json:"timezone"`
+	String      string `json:"string"`
+	Lang        string `json:"lang"`
+	IsRecurring bool   `json:"is_recurring"`
+}
+
+// Hex of the named colors of Todoist
+var todoistColors = map[string]string{
+	"berry_red":   "#b8256f",
+	"red":         "#db4035",
+	"orange":      "#ff9933",
+	"yellow":      "#fad000",
+	"olive_green": "#afb83b",
+	"lime_green":  "#7ecc49",
+	"green":       "#299438",
+	"mint_green":  "#6accbc",
+	"teal":        "#158fad",
+	"sky_blue":    "#14aaf5",
+	"light_blue":  "#96c3eb",
+	"blue":        "#4073ff",
+	"grape":       "#884dff",
+	"violet":      "#af38eb",
+	"lavender":    "#eb96eb",
+	"magenta":     "#e05194",
+	"salmon":      "#ff8d85",
+	"charcoal":    "#808080",
+	"grey":        "#b8b8b8",
+	"taupe":       "#ccac93",
+}
+
+func (l todoistLabel) color() string {
+	var name string
+	if err := json.Unmarshal(l.Color, &name); err == nil {
+		if hex, ok := todoistColors[name]; ok {
+			return hex
+		}
+	}
+	return defaultColor
+}
+
+// Todoist converts the export of the Sync API (`projects`, `items`, `labels`) or an array of tasks of the REST API.
+// Projects are mapped by id, subtasks become checklist items of the top level task.
+func Todoist(data []byte, projects ProjectMap) (items []Item, err error) {
+	var export todoistExport
+	if trimmed := bytes.TrimSpace(data); len(trimmed) != 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &export.Items)
+	} else {
+		err = json.Unmarshal(data, &export)
+	}
+	if err != nil {
+		return
+	}
+
+	labels := map[todoistId]todoistLabel{}
+	for _, l := range export.Labels {
+		labels[l.Id] = l
+	}
+	tasks := map[todoistId]todoistTask{}
+	for _, t := range export.Items {
+		tasks[t.Id] = t
+	}
+	projectNames := map[todoistId]string{}
+	for _, p := range export.Projects {
+		projectNames[p.Id] = p.Name
+	}
+
+	// Subtasks of each task in order
+	children := map[todoistId][]todoistTask{}
+	for _, t := range export.Items {
+		if t.ParentId == "" || t.IsDeleted {
+			continue
+		}
+		if _, ok := tasks[t.ParentId]; ok {
+			children[t.ParentId] = append(children[t.ParentId], t)
+		}
+	}
+	for id := range children {
+		sort.SliceStable(children[id], func(i, j int) bool {
+			return children[id][i].ChildOrder+children[id][i].Order < children[id][j].ChildOrder+children[id][j].Order
+		})
+	}
+	// Subtasks at any depth after their parent
+	var subtasks func(id todoistId, visited map[todoistId]bool) []todoistTask
+	subtasks = func(id todoistId, visited map[todoistId]bool) (flattened []todoistTask) {
+		for _, child := range children[id] {
+			if visited[child.Id] {
+				continue
+			}
+			visited[child.Id] = true
+			flattened = append(flattened, child)
+			flattened = append(flattened, subtasks(child.Id, visited)...)
+		}
+		return
+	}
+	root := func(t todoistTask) todoistId {
+		for depth := 0; t.ParentId != "" && depth < len(tasks); depth++ {
+			parent, ok := tasks[t.ParentId]
+			if !ok {
+				break
+			}
+			t = parent
+		}
+		return t.Id
+	}
+
+	for _, t := range export.Items {
+		item := Item{SourceId: string(t.Id)}
+		item.Post.Name = strings.TrimSpace(t.Content)
+		if t.IsDeleted {
+			item.Skipped = "task is deleted"
+			items = append(items, item)
+			continue
+		}
+		if t.ParentId != "" {
+			if _, ok := tasks[t.ParentId]; ok {
+				item.Skipped = fmt.Sprintf("subtask is a checklist item of task `%s`", root(t))
+				items = append(items, item)
+				continue
+			}
+			item.Warnings = append(item.Warnings, fmt.Sprintf("parent task `%s` is not in the export", t.ParentId))
+		}
+		if item.Post.Name == "" {
+			item.Failure = "`content` required"
+			items = append(items, item)
+			continue
+		}
+
+		if t.Description != "" {
+			description := t.Description
+			item.Post.Description = &description
+		}
+		var warning string
+		item.Post.ProjectId, warning = projects.project(string(t.ProjectId))
+		if warning != "" && projectNames[t.ProjectId] != "" {
+			warning = fmt.Sprintf("project `%s` (%s) is not mapped and `project_id` is not set", t.ProjectId, projectNames[t.ProjectId])
+		}
+		if warning != "" {
+			item.Warnings = append(item.Warnings, warning)
+		}
+
+		// p1 (4) is the highest priority, p4 (1) is no priority
+		if t.Priority >= 2 && t.Priority <= 4 {
+			priority := uint(5 - t.Priority)
+			item.Post.Priority = &priority
+		}
+
+		completed := t.Checked || t.IsCompleted
+		if completed {
+			item.Post.Completed = &completed
+		}
+
+		for _, id := range t.Labels {
+			if l, ok := labels[id]; ok {
+				item.Labels = append(item.Labels, todo.Label{Name: l.Name, Color: l.color()})
+				continue
+			}
+			// Names since the Sync API v9
+			found := false
+			for _, l := range export.Labels {
+				if l.Name == string(id) {
+					item.Labels = append(item.Labels, todo.Label{Name: l.Name, Color: l.color()})
+					found = true
+					break
+				}
+			}
+			if !found {
+				item.Labels = append(item.Labels, todo.Label{Name: string(id), Color: defaultColor})
+			}
+		}
+
+		for _, sub := range subtasks(t.Id, map[todoistId]bool{t.Id: true}) {
+			checked := sub.Checked || sub.IsCompleted
+			checklistItem, warning := checklistItem(strings.TrimSpace(sub.Content), checked)
+			if warning != "" {
+				item.Warnings = append(item.Warnings, warning)
+			}
+			if sub.Due != nil || sub.Description != "" || len(sub.Labels) != 0 {
+				item.Warnings = append(item.Warnings, fmt.Sprintf("due, description and labels of subtask `%s` are removed", sub.Id))
+			}
+			item.Post.Checklist = append(item.Post.Checklist, checklistItem)
+		}
+
+		if t.Due != nil {
+			item.Warnings = append(item.Warnings, t.Due.apply(&item.Post)...)
+		}
+		if t.Duration != nil && t.Duration.Amount > 0 {
+			switch t.Duration.Unit {
+			case "minute":
+				minutes, warning := executionTime(time.Duration(t.Duration.Amount) * time.Minute)
+				if warning != "" {
+					item.Warnings = append(item.Warnings, warning)
+				}
+				item.Post.ExecutionTime = &minutes
+			default:
+				item.Warnings = append(item.Warnings, fmt.Sprintf("duration of %d %s is ignored", t.Duration.Amount, t.Duration.Unit))
+			}
+		}
+		if item.Post.ExecutionTime == nil {
+			minutes := uint(15)
+			item.Post.ExecutionTime = &minutes
+		}
+		items = append(items, item)
+	}
+	return
+}
+
+// Sets date, time and repeat of the due, returns warnings for lossy conversions
+func (due todoistDue) apply(post *todo.PostBody) (warnings []string) {
+	value := due.Date
+	if due.Datetime != "" {
+		value = due.Datetime
+	}
+	var t time.Time
+	var err error
+	dateOnly := false
+	switch {
+	case len(value) == len("2006-01-02"):
+		t, err = time.Parse("2006-01-02", value)
+		dateOnly = true
+	case strings.HasSuffix(value, "Z"):
+		t, err = time.Parse(time.RFC3339, value)
+		zone := due.Timezone
+		if zone == "" {
+			zone = "UTC"
+		}
+		warnings = append(warnings, fmt.Sprintf("due time in %s is not converted", zone))
+	default:
+		t, err = time.Parse("2006-01-02T15:04:05", value)
+	}
+	if err != nil {
+		return append(warnings, fmt.Sprintf("due `%s` is invalid and is ignored", value))
+	}
+	date := t.Format("2006-01-02")
+	post.Date = &date
+	if !dateOnly {
+		tm := t.Format("15:04")
+		post.Time = &tm
+	}
+
+	if !due.IsRecurring {
+		return
+	}
+	repeat, warning := todoistRecurrence(due.String, due.Lang)
+	if warning != "" {
+		warnings = append(warnings, warning)
+	}
+	post.Repeat = repeat
+	return
+}
+
+var (
+	todoistEvery    = regexp.MustCompile(`^every(!)?\s+(?:(other)\s+|(\d+)\s+)?(day|days|week|weeks|month|months|year|years|weekday|workday)$`)
+	todoistAliases  = map[string]string{"daily": "every day", "weekly": "every week", "monthly": "every month", "yearly": "every year"}
+	todoistWeekdays = map[string]string{
+		"mon": "MO", "monday": "MO", "tue": "TU", "tues": "TU", "tuesday": "TU", "wed": "WE", "wednesday": "WE",
+		"thu": "TH", "thur": "TH", "thurs": "TH", "thursday": "TH", "fri": "FR", "friday": "FR",
+		"sat": "SA", "saturday": "SA", "sun": "SU", "sunday": "SU",
+	}
+	todoistTimeSuffix = regexp.MustCompile(`\s+at\s+.*$`)
+)
+
+// Repeat of the recurring due string in English, nil with a warning if it is not supported
+func todoistRecurrence(str string, lang string) (repeat *todo.Repeat, warning string) {
+	unsupported := fmt.Sprintf("recurrence `%s` is not supported and is ignored", str)
+	if lang != "" && lang != "en" {
+		return nil, unsupported
+	}
+	s := strings.ToLower(strings.TrimSpace(str))
+	// Time is set by the due date
+	s = todoistTimeSuffix.ReplaceAllString(s, "")
+	if alias, ok := todoistAliases[s]; ok {
+		s = alias
+	}
+
+	from := ""
+	var rule string
+	if m := todoistEvery.FindStringSubmatch(s); m != nil {
+		if m[1] != "" {
+			from = "completion"
+		}
+		interval := 1
+		if m[2] != "" {
+			interval = 2
+		} else if m[3] != "" {
+			interval, _ = strconv.Atoi(m[3])
+		}
+		switch strings.TrimSuffix(m[4], "s") {
+		case "day":
+			rule = "FREQ=DAILY"
+		case "week":
+			rule = "FREQ=WEEKLY"
+		case "month":
+			rule = "FREQ=MONTHLY"
+		case "year":
+			rule = "FREQ=YEARLY"
+		default:
+			rule = "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"
+		}
+		if interval > 1 {
+			rule += fmt.Sprintf(";INTERVAL=%d", interval)
+		}
+	} else if strings.HasPrefix(s, "every ") {
+		// `every mon, fri` or `every monday and thursday`
+		var days []string
+		for _, word := range strings.FieldsFunc(strings.TrimPrefix(s, "every "), func(r rune) bool { return r == ',' || r == ' ' }) {
+			if word == "and" {
+				continue
+			}
+			day, ok := todoistWeekdays[word]
+			if !ok {
+				return nil, unsupported
+			}
+			days = append(days, day)
+		}
+		if len(days) == 0 {
+			return nil, unsupported
+		}
+		rule = "FREQ=WEEKLY;BYDAY=" + strings.Join(days, ",")
+	} else {
+		return nil, unsupported
+	}
+	return &todo.Repeat{RRule: &rule, From: from}, ""
+}
@@ -0,0 +1,193 @@
+package importer
+
+import (
+	"encoding/json"
+	"flow-todos/todo"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Board exported as JSON by Trello
+type trelloBoard struct {
+	Id         string            `json:"id"`
+	Name       string            `json:"name"`
+	Cards      []trelloCard      `json:"cards"`
+	Lists      []trelloList      `json:"lists"`
+	Labels     []trelloLabel     `json:"labels"`
+	Checklists []trelloChecklist `json:"checklists"`
+}
+
+type trelloCard struct {
+	Id           string     `json:"id"`
+	Name         string     `json:"name"`
+	Desc         string     `json:"desc"`
+	Closed       bool       `json:"closed"`
+	IdList       string     `json:"idList"`
+	IdLabels     []string   `json:"idLabels"`
+	IdChecklists []string   `json:"idChecklists"`
+	Start        *time.Time `json:"start"`
+	Due          *time.Time `json:"due"`
+	DueComplete  bool       `json:"dueComplete"`
+	Pos          float64    `json:"pos"`
+}
+
+type trelloList struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Closed bool   `json:"closed"`
+}
+
+type trelloLabel struct {
+	Id    string  `json:"id"`
+	Name  string  `json:"name"`
+	Color *string `json:"color"`
+}
+
+type trelloChecklist struct {
+	Id         string  `json:"id"`
+	IdCard     string  `json:"idCard"`
+	Name       string  `json:"name"`
+	Pos        float64 `json:"pos"`
+	CheckItems []struct {
+		Name  string  `json:"name"`
+		State string  `json:"state"`
+		Pos   float64 `json:"pos"`
+	} `json:"checkItems"`
+}
+
+// Hex of the label colors of Trello
+var trelloColors = map[string]string{
+	"green":  "#61bd4f",
+	"yellow": "#f2d600",
+	"orange": "#ff9f1a",
+	"red":    "#eb5a46",
+	"purple": "#c377e0",
+	"blue":   "#0079bf",
+	"sky":    "#00c2e0",
+	"lime":   "#51e898",
+	"pink":   "#ff78cb",
+	"black":  "#344563",
+}
+
+// Label with the color as name if it has no name
+func (l trelloLabel) label() todo.Label {
+	color := ""
+	if l.Color != nil {
+		// Variants like `green_dark`
+		color = strings.Split(*l.Color, "_")[0]
+	}
+	hex, ok := trelloColors[color]
+	if !ok {
+		hex = defaultColor
+	}
+	name := l.Name
+	if name == "" {
+		name = color
+	}
+	return todo.Label{Name: name, Color: hex}
+}
+
+// Trello converts the JSON export of a board.
+// Projects are mapped by list id or board id, checklists are merged into the checklist.
+func Trello(data []byte, projects ProjectMap) (items []Item, err error) {
+	var board trelloBoard
+	if err = json.Unmarshal(data, &board); err != nil {
+		return
+	}
+
+	lists := map[string]trelloList{}
+	for _, l := range board.Lists {
+		lists[l.Id] = l
+	}
+	labels := map[string]trelloLabel{}
+	for _, l := range board.Labels {
+		labels[l.Id] = l
+	}
+	checklists := map[string][]trelloChecklist{}
+	for _, c := range board.Checklists {
+		checklists[c.IdCard] = append(checklists[c.IdCard], c)
+	}
+
+	for _, card := range board.Cards {
+		item := Item{SourceId: card.Id}
+		item.Post.Name = strings.TrimSpace(card.Name)
+		if card.Closed {
+			item.Skipped = "card is archived"
+			items = append(items, item)
+			continue
+		}
+		if lists[card.IdList].Closed {
+			item.Skipped = fmt.Sprintf("list `%s` is archived", lists[card.IdList].Name)
+			items = append(items, item)
+			continue
+		}
+		if item.Post.Name == "" {
+			item.Failure = "`name` required"
+			items = append(items, item)
+			continue
+		}
+
+		if card.Desc != "" {
+			description := card.Desc
+			item.Post.Description = &description
+		}
+		var warning string
+		item.Post.ProjectId, warning = projects.project(card.IdList, board.Id)
+		if warning != "" {
+			warning = fmt.Sprintf("list `%s` (%s) and board `%s` are not mapped and `project_id` is not set", card.IdList, lists[card.IdList].Name, board.Id)
+			item.Warnings = append(item.Warnings, warning)
+		}
+
+		if card.DueComplete {
+			completed := true
+			item.Post.Completed = &completed
+		}
+
+		for _, id := range card.IdLabels {
+			l, ok := labels[id]
+			if !ok || l.label().Name == "" {
+				item.Warnings = append(item.Warnings, fmt.Sprintf("label `%s` is not in the export and is removed", id))
+				continue
+			}
+			item.Labels = append(item.Labels, l.label())
+		}
+
+		cardChecklists := checklists[card.Id]
+		sort.SliceStable(cardChecklists, func(i, j int) bool { return cardChecklists[i].Pos < cardChecklists[j].Pos })
+		for _, c := range cardChecklists {
+			sort.SliceStable(c.CheckItems, func(i, j int) bool { return c.CheckItems[i].Pos < c.CheckItems[j].Pos })
+			for _, i := range c.CheckItems {
+				name := i.Name
+				// Names of the checklists are kept as prefix
+				if len(cardChecklists) > 1 {
+					name = c.Name + ": " + name
+				}
+				checklistItem, warning := checklistItem(name, i.State == "complete")
+				if warning != "" {
+					item.Warnings = append(item.Warnings, warning)
+				}
+				item.Post.Checklist = append(item.Post.Checklist, checklistItem)
+			}
+		}
+
+		if card.Due != nil {
+			// Trello exports UTC
+			due := card.Due.UTC()
+			date := due.Format("2006-01-02")
+			tm := due.Format("15:04")
+			item.Post.Date = &date
+			item.Post.Time = &tm
+			item.Warnings = append(item.Warnings, "due time in UTC is not converted")
+		} else if card.Start != nil {
+			date := card.Start.UTC().Format("2006-01-02")
+			item.Post.Date = &date
+			item.Warnings = append(item.Warnings, "start date without due is the date")
+		}
+		minutes := uint(15)
+		item.Post.ExecutionTime = &minutes
+		items = append(items, item)
+	}
+	return
+}
@@ -28,3 +28,13 @@ func CheckToken(issuer string, token *jwt.Token) (id uint64, err error) {
 
 	return claims.Id, nil
 }
+
+// ParseToken verifies the signature of a token sent outside the JWT middleware
+func ParseToken(secret string, raw string) (token *jwt.Token, err error) {
+	return jwt.ParseWithClaims(raw, &JwtCustumClaims{}, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(secret), nil
+	})
+}
@@ -1,16 +1,22 @@
 package main
 
 import (
+	"database/sql"
 	"flow-todos/flags"
 	"flow-todos/handler"
 	"flow-todos/jwt"
+	"flow-todos/memory"
+	"flow-todos/migrate"
 	"flow-todos/mysql"
+	"flow-todos/outbox"
 	"flow-todos/todo"
 	"flow-todos/utils"
+	"flow-todos/webhook"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator"
@@ -36,6 +42,8 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 	cv.validator.RegisterValidation("Y-M-D", todo.DateStrValidation)
 	cv.validator.RegisterValidation("H:M", todo.HMTimeStrValidation)
 	cv.validator.RegisterValidation("step15", todo.Step15IntValidation)
+	cv.validator.RegisterValidation("rrule", todo.RRuleStrValidation)
+	cv.validator.RegisterCustomTypeFunc(todo.PatchNullUintValue, todo.PatchNullUint{})
 
 	if err := cv.validator.Struct(i); err != nil {
 		// Optionally, you could return the error to give each route more control over the status code
@@ -104,7 +112,8 @@ func main() {
 		Claims:     &jwt.JwtCustumClaims{},
 		SigningKey: []byte(*f.JwtSecret),
 		Skipper: func(c echo.Context) bool {
-			return c.Path() == "/-/readiness"
+			// Calendar feeds are authenticated by the feed token
+			return c.Path() == "/-/readiness" || strings.HasPrefix(c.Path(), "/feed/")
 		},
 	}))
 
@@ -114,7 +123,8 @@ func main() {
 			Format: logFormat(),
 			Output: os.Stdout,
 			Skipper: func(c echo.Context) bool {
-				return c.Path() == "/-/readiness"
+				// Feed urls have the token of the feed
+				return strings.HasPrefix(c.Path(), "/-/") || strings.HasPrefix(c.Path(), "/feed/")
 			},
 		}))
 		e.Logger.Info("Access logging with `alp`(https://github.com/tkuchiki/alp) enabled")
@@ -124,21 +134,156 @@ func main() {
 	e.Validator = &CustomValidator{validator: validator.New()}
 
 	//
-	// Setup DB
+	// Setup store
 	//
 
-	// DB client instance
-	e.Logger.Debugf("DB DSN `%s`", mysql.SetDSNTCP(*f.MysqlUser, *f.MysqlPasswd, *f.MysqlHost, int(*f.MysqlPort), *f.MysqlDB))
+	var store todo.TodoStore
+	var webhookStore webhook.Store
+	var db *sql.DB
+	switch *f.Store {
+	case "mysql":
+		// DB client instance
+		e.Logger.Debugf("DB DSN `%s`", mysql.SetDSNTCP(*f.MysqlUser, *f.MysqlPasswd, *f.MysqlHost, int(*f.MysqlPort), *f.MysqlDB))
 
-	// Check connection
-	d, err := mysql.Open()
-	if err != nil {
-		e.Logger.Fatal(err)
+		// Connection pool shared by all requests
+		var err error
+		db, err = mysql.Open(mysql.PoolConfig{
+			MaxOpenConns:    int(*f.MysqlMaxOpenConns),
+			MaxIdleConns:    int(*f.MysqlMaxIdleConns),
+			ConnMaxLifetime: *f.MysqlConnLifetime,
+			ConnMaxIdleTime: *f.MysqlConnIdleTime,
+		})
+		if err != nil {
+			e.Logger.Fatal(err)
+		}
+		defer db.Close()
+		e.Logger.Debugf("DB pool max open %d, max idle %d, max lifetime %s, max idle time %s", *f.MysqlMaxOpenConns, *f.MysqlMaxIdleConns, *f.MysqlConnLifetime, *f.MysqlConnIdleTime)
+
+		// Check connection
+		if err = db.Ping(); err != nil {
+			e.Logger.Fatal(err)
+		}
+		e.Logger.Info("DB connection test succeeded")
+
+		store = mysql.NewTodoStore(db)
+		webhookStore = mysql.NewWebhookStore(db)
+	case "memory":
+		store = memory.NewTodoStore()
+		webhookStore = memory.NewWebhookStore()
+		e.Logger.Warn("In-memory store enabled, todos will be lost on exit")
+	default:
+		e.Logger.Fatalf("unknown store `%s`", *f.Store)
+	}
+
+	// `migrate` subcommand
+	if args := flags.Args(); len(args) != 0 {
+		if args[0] != "migrate" {
+			e.Logger.Fatalf("unknown command `%s`", args[0])
+		}
+		if db == nil {
+			e.Logger.Fatal("`migrate` requires `--store mysql`")
+		}
+		if err := runMigrate(db, args[1:]); err != nil {
+			e.Logger.Fatal(err)
+		}
+		return
 	}
-	if err = d.Ping(); err != nil {
+
+	// Migrate
+	if *f.MigrateOnStart && db != nil {
+		m, err := migrate.New(db)
+		if err != nil {
+			e.Logger.Fatal(err)
+		}
+		done, err := m.Up(0)
+		if err != nil {
+			e.Logger.Fatal(err)
+		}
+		for _, migration := range done {
+			e.Logger.Infof("DB migration %04d_%s applied", migration.Version, migration.Name)
+		}
+	}
+
+	//
+	// Setup webhooks
+	//
+
+	if len(f.WebhookUrls) != 0 && *f.WebhookSecret == "" {
+		e.Logger.Fatal("`--webhook-secret` option is required with `--webhook-url`")
+	}
+	if err := webhook.SyncSystemSubscriptions(webhookStore, f.WebhookUrls, *f.WebhookSecret); err != nil {
 		e.Logger.Fatal(err)
 	}
-	e.Logger.Info("DB connection test succeeded")
+	e.Logger.Debugf("System webhooks %s", f.WebhookUrls.String())
+	dispatcher := webhook.NewDispatcher(webhookStore, webhook.DispatcherConfig{
+		MaxAttempts:  *f.WebhookMaxAttempts,
+		Backoff:      *f.WebhookBackoff,
+		Timeout:      *f.WebhookTimeout,
+		PollInterval: 5 * time.Second,
+	})
+	stopDispatcher := make(chan struct{})
+	defer close(stopDispatcher)
+	go dispatcher.Run(stopDispatcher, e.Logger)
+	e.Logger.Infof("Webhook dispatcher started with max %d attempts", *f.WebhookMaxAttempts)
+
+	//
+	// Setup outbox
+	//
+
+	var sinks []outbox.Sink
+	webhookSink := false
+	for _, name := range strings.Split(*f.OutboxSinks, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "webhook":
+			webhookSink = true
+			sinks = append(sinks, outbox.NewWebhookSink(dispatcher))
+		case "nats":
+			sink, err := outbox.NewNatsSink(*f.OutboxNatsUrl, *f.OutboxNatsSubject)
+			if err != nil {
+				e.Logger.Fatal(err)
+			}
+			sinks = append(sinks, sink)
+		case "file":
+			sinks = append(sinks, outbox.NewFileSink(*f.OutboxFile))
+		default:
+			e.Logger.Fatalf("unknown outbox sink `%s`", name)
+		}
+	}
+	if !webhookSink {
+		e.Logger.Warnf("Outbox sinks `%s` have no `webhook`, webhook subscriptions and `WEBHOOK_URLS` receive no events", *f.OutboxSinks)
+	}
+	// Replicas sharing the database publish one at a time, so the order is kept
+	var locker outbox.Locker
+	if db != nil {
+		locker = func(fn func() error) (bool, error) {
+			return mysql.TryLock(db, "flow-todos.outbox", fn)
+		}
+	}
+	publisher := outbox.NewDispatcher(store, sinks, locker, outbox.Config{
+		BatchSize:    100,
+		PollInterval: *f.OutboxPollInterval,
+		Backoff:      *f.OutboxBackoff,
+		MaxAttempts:  *f.OutboxMaxAttempts,
+		Retention:    *f.OutboxRetention,
+	})
+	stopPublisher := make(chan struct{})
+	defer close(stopPublisher)
+	go publisher.Run(stopPublisher, e.Logger)
+	e.Logger.Infof("Outbox dispatcher started with sinks `%s` and max %d attempts", *f.OutboxSinks, *f.OutboxMaxAttempts)
+
+	// Publish the events of a change without waiting for the poll, CalDAV requests included
+	e.Pre(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := next(c)
+			if c.Request().Method != http.MethodGet {
+				publisher.Notify()
+			}
+			return err
+		}
+	})
+
+	h := handler.New(store, webhookStore, dispatcher)
 
 	//
 	// Check health of external service
@@ -174,18 +319,99 @@ func main() {
 		return c.String(http.StatusOK, "flow-todos is Healthy.\n")
 	})
 
+	// Admin server of diagnostics and metrics, on its own port to be kept private
+	admin := echo.New()
+	admin.HideBanner = true
+	admin.Logger.SetLevel(log.Lvl(*f.LogLevel))
+	admin.GET("/-/readiness", func(c echo.Context) error {
+		return c.String(http.StatusOK, "flow-todos is Healthy.\n")
+	})
+
+	// Diagnostics route
+	admin.GET("/-/diagnostics", func(c echo.Context) error {
+		diagnostics := map[string]interface{}{"store": *f.Store}
+		if db != nil {
+			diagnostics["db_pool"] = mysql.Stats(db)
+		}
+		stats, err := publisher.Stats()
+		if err != nil {
+			c.Logger().Error(err)
+			return c.JSONPretty(http.StatusInternalServerError, map[string]string{"message": err.Error()}, "	")
+		}
+		diagnostics["outbox"] = stats
+		return c.JSONPretty(http.StatusOK, diagnostics, "	")
+	})
+
+	// Metrics route in the Prometheus text format
+	admin.GET("/-/metrics", func(c echo.Context) error {
+		stats, err := publisher.Stats()
+		if err != nil {
+			c.Logger().Error(err)
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		var b strings.Builder
+		if err = stats.WriteMetrics(&b); err != nil {
+			return err
+		}
+		return c.Blob(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+	})
+
+	// CalDAV, authenticated by the handler, uses methods unknown to the router like REPORT
+	e.Pre(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			path := c.Request().URL.Path
+			if path == "/.well-known/caldav" || path+"/" == "/caldav/" || strings.HasPrefix(path, "/caldav/") {
+				return h.CalDAV(c)
+			}
+			return next(c)
+		}
+	})
+
 	// Restricted routes
-	e.GET("/", handler.GetList)
-	e.POST("/", handler.Post)
-	e.GET(":id", handler.Get)
-	e.PATCH(":id", handler.Patch)
-	e.DELETE(":id", handler.Delete)
-	e.PATCH(":id/skip", handler.Skip)
-	e.PATCH(":id/complete", handler.Complete)
-	e.DELETE("/", handler.DeleteAll)
+	e.GET("/", h.GetList)
+	e.POST("/", h.Post)
+	e.POST("/bulk", h.Bulk)
+	e.GET("/export.ics", h.ExportICalendar)
+	e.GET("/export", h.Export)
+	e.POST("/import", h.Import)
+	e.POST("/import/:provider", h.ImportProvider)
+	e.POST("/feed-token", h.PostFeedToken)
+	e.DELETE("/feed-token", h.DeleteFeedToken)
+	e.GET("/feed/:token", h.Feed)
+	e.GET("/search", h.Search)
+	e.GET("/labels", h.GetLabels)
+	e.POST("/labels", h.PostLabel)
+	e.GET("/labels/:id", h.GetLabel)
+	e.PATCH("/labels/:id", h.PatchLabel)
+	e.DELETE("/labels/:id", h.DeleteLabel)
+	e.GET("/webhooks", h.GetWebhooks)
+	e.POST("/webhooks", h.PostWebhook)
+	e.GET("/webhooks/:id", h.GetWebhook)
+	e.PATCH("/webhooks/:id", h.PatchWebhook)
+	e.DELETE("/webhooks/:id", h.DeleteWebhook)
+	e.GET("/webhooks/:id/deliveries", h.GetWebhookDeliveries)
+	e.GET("/webhooks/:id/deliveries/:delivery_id", h.GetWebhookDelivery)
+	e.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", h.RedeliverWebhook)
+	e.GET(":id", h.Get)
+	e.PATCH(":id", h.Patch)
+	e.DELETE(":id", h.Delete)
+	e.PATCH(":id/skip", h.Skip)
+	e.PATCH(":id/complete", h.Complete)
+	e.PATCH(":id/uncomplete", h.Uncomplete)
+	e.PATCH(":id/unskip", h.Unskip)
+	e.PATCH(":id/occurrences/:date", h.PatchOccurrence)
+	e.DELETE(":id/occurrences/:date", h.DeleteOccurrence)
+	e.POST(":id/checklist", h.PostChecklistItem)
+	e.PUT(":id/checklist/order", h.ReorderChecklist)
+	e.PATCH(":id/checklist/:item_id", h.PatchChecklistItem)
+	e.DELETE(":id/checklist/:item_id", h.DeleteChecklistItem)
+	e.DELETE("/", h.DeleteAll)
 
 	//
 	// Start echo
 	//
+	go func() {
+		e.Logger.Fatal(admin.Start(fmt.Sprintf(":%d", *f.AdminPort)))
+	}()
 	e.Logger.Fatal(e.Start(fmt.Sprintf(":%d", *f.Port)))
 }
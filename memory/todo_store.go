@@ -0,0 +1,1032 @@
+package memory
+
+import (
+	"database/sql"
+	"flow-todos/todo"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Implements todo.TodoStore
+// Keeps all rows in process memory, for development and tests.
+type TodoStore struct {
+	mu   *sync.Mutex
+	data *data
+	// Lock is held by the outer `WithTx`
+	inTx bool
+}
+
+type data struct {
+	todoSeq   uint64
+	repeatSeq uint64
+	todos     map[uint64]todoRow
+	repeats   map[uint64]repeatRow
+	// Exceptions by repeat model id and date
+	exceptions   map[uint64]map[string]todo.RepeatException
+	operationSeq uint64
+	operations   map[uint64]operationRow
+	labelSeq     uint64
+	labels       map[uint64]labelRow
+	checklistSeq uint64
+	// Hashes of feed tokens by user id
+	feedTokens map[uint64]string
+	// CalDAV resources by user id and name
+	caldavResources map[uint64]map[string]todo.CalDAVResource
+	outboxSeq       uint64
+	outbox          map[uint64]todo.OutboxEvent
+}
+
+type todoRow struct {
+	userId        uint64
+	todo          todo.Todo
+	repeatModelId *uint64
+}
+
+type operationRow struct {
+	userId    uint64
+	operation todo.Operation
+}
+
+type labelRow struct {
+	userId uint64
+	label  todo.Label
+}
+
+type repeatRow struct {
+	userId uint64
+	repeat todo.Repeat
+}
+
+func NewTodoStore() *TodoStore {
+	return &TodoStore{
+		mu: &sync.Mutex{},
+		data: &data{
+			todos:      map[uint64]todoRow{},
+			repeats:    map[uint64]repeatRow{},
+			exceptions: map[uint64]map[string]todo.RepeatException{},
+			operations: map[uint64]operationRow{},
+			labels:     map[uint64]labelRow{},
+			feedTokens: map[uint64]string{},
+
+			caldavResources: map[uint64]map[string]todo.CalDAVResource{},
+			outbox:          map[uint64]todo.OutboxEvent{},
+		},
+	}
+}
+
+// Rows are replaced on write and never modified, so copying maps is enough.
+func (d *data) clone() *data {
+	c := &data{
+		todoSeq:      d.todoSeq,
+		repeatSeq:    d.repeatSeq,
+		todos:        make(map[uint64]todoRow, len(d.todos)),
+		repeats:      make(map[uint64]repeatRow, len(d.repeats)),
+		exceptions:   make(map[uint64]map[string]todo.RepeatException, len(d.exceptions)),
+		operationSeq: d.operationSeq,
+		operations:   make(map[uint64]operationRow, len(d.operations)),
+		labelSeq:     d.labelSeq,
+		checklistSeq: d.checklistSeq,
+		labels:       make(map[uint64]labelRow, len(d.labels)),
+		feedTokens:   make(map[uint64]string, len(d.feedTokens)),
+
+		caldavResources: make(map[uint64]map[string]todo.CalDAVResource, len(d.caldavResources)),
+		outboxSeq:       d.outboxSeq,
+		outbox:          make(map[uint64]todo.OutboxEvent, len(d.outbox)),
+	}
+	for k, v := range d.todos {
+		c.todos[k] = v
+	}
+	for k, v := range d.repeats {
+		c.repeats[k] = v
+	}
+	for k, v := range d.operations {
+		c.operations[k] = v
+	}
+	for k, v := range d.labels {
+		c.labels[k] = v
+	}
+	for k, v := range d.feedTokens {
+		c.feedTokens[k] = v
+	}
+	for k, v := range d.outbox {
+		c.outbox[k] = v
+	}
+	for k, v := range d.caldavResources {
+		c.caldavResources[k] = make(map[string]todo.CalDAVResource, len(v))
+		for name, r := range v {
+			c.caldavResources[k][name] = r
+		}
+	}
+	for k, v := range d.exceptions {
+		c.exceptions[k] = make(map[string]todo.RepeatException, len(v))
+		for date, e := range v {
+			c.exceptions[k][date] = e
+		}
+	}
+	return c
+}
+
+func (s *TodoStore) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (s *TodoStore) WithTx(fn func(s todo.TodoStore) error) error {
+	unlock := s.lock()
+	defer unlock()
+
+	tx := &TodoStore{mu: s.mu, data: s.data.clone(), inTx: true}
+	if err := fn(tx); err != nil {
+		// Discard changes
+		return err
+	}
+	*s.data = *tx.data
+	return nil
+}
+
+// Format like MySQL `DATE` and `TIME_FORMAT(time, '%H:%i')`, ids ordered like the joined rows
+func normalize(t *todo.Todo) {
+	if t.Date != nil {
+		if d, err := time.Parse("2006-1-2", *t.Date); err == nil {
+			date := d.Format("2006-01-02")
+			t.Date = &date
+		}
+	}
+	if t.Time != nil {
+		if d, err := time.Parse("15:4", *t.Time); err == nil {
+			tm := d.Format("15:04")
+			t.Time = &tm
+		}
+	}
+	for _, ids := range []*[]uint64{&t.Labels, &t.BlockedBy} {
+		if len(*ids) == 0 {
+			*ids = nil
+			continue
+		}
+		*ids = append([]uint64{}, *ids...)
+		sort.Slice(*ids, func(i, j int) bool { return (*ids)[i] < (*ids)[j] })
+	}
+}
+
+func (d *data) get(userId uint64, id uint64) (t todo.Todo, notFound bool) {
+	row, ok := d.todos[id]
+	if !ok || row.userId != userId {
+		notFound = true
+		return
+	}
+	t = row.todo
+	if row.repeatModelId != nil {
+		repeat := d.repeats[*row.repeatModelId].repeat
+		repeat.Days = append([]todo.RepeatDay{}, repeat.Days...)
+		sort.Slice(repeat.Days, func(i, j int) bool {
+			return repeat.Days[i].Day < repeat.Days[j].Day
+		})
+		if len(repeat.Days) == 0 {
+			repeat.Days = nil
+		}
+		repeat.Exceptions = nil
+		for _, e := range d.exceptions[*row.repeatModelId] {
+			repeat.Exceptions = append(repeat.Exceptions, e)
+		}
+		sort.Slice(repeat.Exceptions, func(i, j int) bool {
+			return repeat.Exceptions[i].Date < repeat.Exceptions[j].Date
+		})
+		t.Repeat = &repeat
+	}
+	t.Blocks = nil
+	for otherId, other := range d.todos {
+		if other.userId == userId && containsId(other.todo.BlockedBy, &id) {
+			t.Blocks = append(t.Blocks, otherId)
+		}
+	}
+	sort.Slice(t.Blocks, func(i, j int) bool {
+		return t.Blocks[i] < t.Blocks[j]
+	})
+	return
+}
+
+// Blockers of the todo are not completed
+func (d *data) blocked(t todo.Todo) bool {
+	for _, id := range t.BlockedBy {
+		if blocker, ok := d.todos[id]; ok && !blocker.todo.Completed {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *TodoStore) Get(userId uint64, id uint64) (t todo.Todo, notFound bool, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	t, notFound = s.data.get(userId, id)
+	return
+}
+
+func datetime(t todo.Todo) (dt time.Time, ok bool) {
+	if t.Date == nil {
+		return
+	}
+	dt, err := time.Parse("2006-01-02", *t.Date)
+	if err != nil {
+		return
+	}
+	if t.Time != nil {
+		tm, err := time.Parse("15:04", *t.Time)
+		if err != nil {
+			return
+		}
+		dt = dt.Add(time.Duration(tm.Hour())*time.Hour + time.Duration(tm.Minute())*time.Minute)
+	}
+	return dt, true
+}
+
+// Todos matching the filters of q in no particular order
+func (d *data) list(userId uint64, q todo.GetListQuery) (todos []todo.Todo) {
+	for id, row := range d.todos {
+		if row.userId != userId {
+			continue
+		}
+		if q.Start != nil || q.End != nil {
+			dt, ok := datetime(row.todo)
+			if !ok {
+				continue
+			}
+			if q.Start != nil && dt.Before(*q.Start) {
+				continue
+			}
+			if q.End != nil && dt.After(*q.End) {
+				continue
+			}
+		}
+		if q.ProjectIds != nil && !containsId(q.ProjectIds, row.todo.ProjectId) {
+			continue
+		}
+		if q.SprintId != nil && (row.todo.SprintId == nil || *row.todo.SprintId != *q.SprintId) {
+			continue
+		}
+		if q.OnlyCompleted && !row.todo.Completed {
+			continue
+		}
+		if !q.WithCompleted && !q.OnlyCompleted && row.todo.Completed {
+			continue
+		}
+		if q.OnlyRepeatModel && row.repeatModelId == nil {
+			continue
+		}
+		if q.HasDate != nil && *q.HasDate != (row.todo.Date != nil) {
+			continue
+		}
+		if q.IsRepeating != nil && *q.IsRepeating != (row.repeatModelId != nil) {
+			continue
+		}
+		if !q.MatchValues(row.todo) {
+			continue
+		}
+		if q.Priorities != nil && !containsPriority(q.Priorities, row.todo.Priority) {
+			continue
+		}
+		if q.Blocked != nil && *q.Blocked != d.blocked(row.todo) {
+			continue
+		}
+		if !containsLabels(row.todo.Labels, q.Labels) {
+			continue
+		}
+		t, _ := d.get(userId, id)
+		todos = append(todos, t)
+	}
+	return
+}
+
+func (s *TodoStore) List(userId uint64, q todo.GetListQuery) (todos []todo.Todo, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	todos = s.data.list(userId, q)
+	todo.SortTodos(todos, q.Sort, q.Order)
+	if q.After != nil {
+		i := sort.Search(len(todos), func(i int) bool {
+			return todo.SortsAfter(todos[i], q.Sort, q.Order, q.After)
+		})
+		todos = todos[i:]
+	}
+	if q.Limit != 0 && uint(len(todos)) > q.Limit {
+		todos = todos[:q.Limit]
+	}
+	return
+}
+
+func (s *TodoStore) Search(userId uint64, text string, q todo.GetListQuery) (todos []todo.Todo, scores []float64, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	todos, scores = todo.RankTodos(s.data.list(userId, q), text)
+	if q.Limit != 0 && uint(len(todos)) > q.Limit {
+		todos = todos[:q.Limit]
+		scores = scores[:q.Limit]
+	}
+	return
+}
+
+func containsId(ids []uint64, id *uint64) bool {
+	if id == nil {
+		return false
+	}
+	for _, i := range ids {
+		if i == *id {
+			return true
+		}
+	}
+	return false
+}
+
+// No priority is 0
+func containsPriority(priorities []uint, priority *uint) bool {
+	p := uint(0)
+	if priority != nil {
+		p = *priority
+	}
+	for _, i := range priorities {
+		if i == p {
+			return true
+		}
+	}
+	return false
+}
+
+func containsLabels(labels []uint64, ids []uint64) bool {
+	for _, id := range ids {
+		found := false
+		for _, l := range labels {
+			if l == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *data) putRepeatModel(userId uint64, r *todo.Repeat) {
+	if r.Id == 0 {
+		d.repeatSeq++
+		r.Id = d.repeatSeq
+	}
+	repeat := *r
+	if repeat.Until != nil {
+		if d, err := time.Parse("2006-1-2", *repeat.Until); err == nil {
+			until := d.Format("2006-01-02")
+			repeat.Until = &until
+		}
+	}
+	if repeat.Unit == "week" {
+		repeat.Days = append([]todo.RepeatDay{}, repeat.Days...)
+		for i, day := range repeat.Days {
+			if day.Time == nil {
+				continue
+			}
+			if d, err := time.Parse("15:4", *day.Time); err == nil {
+				tm := d.Format("15:04")
+				repeat.Days[i].Time = &tm
+			}
+		}
+	} else {
+		repeat.Days = nil
+	}
+	repeat.Exceptions = nil
+	d.repeats[r.Id] = repeatRow{userId, repeat}
+}
+
+func (d *data) hasRepeatModel(userId uint64, id uint64) bool {
+	row, ok := d.repeats[id]
+	return ok && row.userId == userId
+}
+
+// Ignore labels and blockers of other users, and duplicates
+func (d *data) dropForeignIds(userId uint64, t *todo.Todo) {
+	var labels []uint64
+	for _, id := range t.Labels {
+		if row, ok := d.labels[id]; ok && row.userId == userId && !containsId(labels, &id) {
+			labels = append(labels, id)
+		}
+	}
+	t.Labels = labels
+	var blockedBy []uint64
+	for _, id := range t.BlockedBy {
+		if row, ok := d.todos[id]; ok && row.userId == userId && !containsId(blockedBy, &id) {
+			blockedBy = append(blockedBy, id)
+		}
+	}
+	t.BlockedBy = blockedBy
+}
+
+func (d *data) deleteRepeatModelIfUnused(id uint64) {
+	for _, row := range d.todos {
+		if row.repeatModelId != nil && *row.repeatModelId == id {
+			return
+		}
+	}
+	d.deleteRepeatModel(id)
+}
+
+// Delete the todo with its operations and dependencies
+func (d *data) deleteTodo(id uint64) {
+	delete(d.todos, id)
+	for otherId, row := range d.todos {
+		if !containsId(row.todo.BlockedBy, &id) {
+			continue
+		}
+		var blockedBy []uint64
+		for _, blockerId := range row.todo.BlockedBy {
+			if blockerId != id {
+				blockedBy = append(blockedBy, blockerId)
+			}
+		}
+		row.todo.BlockedBy = blockedBy
+		d.todos[otherId] = row
+	}
+	for opId, row := range d.operations {
+		if row.operation.TodoId == id {
+			delete(d.operations, opId)
+		}
+	}
+	for _, resources := range d.caldavResources {
+		for name, r := range resources {
+			if r.TodoId == id {
+				delete(resources, name)
+			}
+		}
+	}
+}
+
+func (d *data) deleteRepeatModel(id uint64) {
+	delete(d.repeats, id)
+	delete(d.exceptions, id)
+}
+
+func (s *TodoStore) Insert(userId uint64, t todo.Todo) (inserted todo.Todo, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	var idRepeatModel *uint64
+	if t.Repeat != nil {
+		repeat := *t.Repeat
+		if repeat.Id == 0 {
+			s.data.putRepeatModel(userId, &repeat)
+		} else if !s.data.hasRepeatModel(userId, repeat.Id) {
+			err = sql.ErrNoRows
+			return
+		}
+		t.Repeat = &repeat
+		idRepeatModel = &repeat.Id
+	}
+	s.data.dropForeignIds(userId, &t)
+
+	s.data.todoSeq++
+	t.Id = s.data.todoSeq
+	now := time.Now().UTC().Truncate(time.Second)
+	t.CreatedAt = &now
+	t.UpdatedAt = &now
+	normalize(&t)
+	checklist := []todo.ChecklistItem{}
+	for _, item := range t.Checklist {
+		s.data.checklistSeq++
+		item.Id = s.data.checklistSeq
+		checklist = append(checklist, item)
+	}
+	t.Checklist = nil
+	if len(checklist) != 0 {
+		t.Checklist = checklist
+	}
+
+	row := t
+	row.Repeat = nil
+	row.Blocks = nil
+	s.data.todos[t.Id] = todoRow{userId, row, idRepeatModel}
+
+	inserted = t
+	return
+}
+
+func (s *TodoStore) Update(userId uint64, t todo.Todo) (updated todo.Todo, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	old, ok := s.data.todos[t.Id]
+	if !ok || old.userId != userId {
+		err = sql.ErrNoRows
+		return
+	}
+
+	var idRepeatModel *uint64
+	newRepeatModel := t.Repeat != nil && t.Repeat.Id == 0
+	if t.Repeat != nil {
+		repeat := *t.Repeat
+		if !newRepeatModel && !s.data.hasRepeatModel(userId, repeat.Id) {
+			err = sql.ErrNoRows
+			return
+		}
+		s.data.putRepeatModel(userId, &repeat)
+		t.Repeat = &repeat
+		idRepeatModel = &repeat.Id
+	}
+	s.data.dropForeignIds(userId, &t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	t.CreatedAt = old.todo.CreatedAt
+	t.Checklist = old.todo.Checklist
+	t.UpdatedAt = &now
+	normalize(&t)
+	row := t
+	row.Repeat = nil
+	row.Blocks = nil
+	s.data.todos[t.Id] = todoRow{userId, row, idRepeatModel}
+
+	// Delete unused repeat model, kept for the earlier occurrences when a new one is created
+	if old.repeatModelId != nil && !newRepeatModel && (idRepeatModel == nil || *old.repeatModelId != *idRepeatModel) {
+		s.data.deleteRepeatModelIfUnused(*old.repeatModelId)
+	}
+
+	updated = t
+	return
+}
+
+func (s *TodoStore) UpdateRepeatModel(userId uint64, r todo.Repeat) (err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	if !s.data.hasRepeatModel(userId, r.Id) {
+		return
+	}
+	s.data.putRepeatModel(userId, &r)
+	return
+}
+
+func (s *TodoStore) Delete(userId uint64, id uint64) (notFound bool, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	row, ok := s.data.todos[id]
+	if !ok || row.userId != userId {
+		// Not found
+		return true, nil
+	}
+	s.data.deleteTodo(id)
+	if row.repeatModelId != nil {
+		s.data.deleteRepeatModelIfUnused(*row.repeatModelId)
+	}
+	return false, nil
+}
+
+func (s *TodoStore) DeleteAll(userId uint64) (err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	for id, row := range s.data.todos {
+		if row.userId == userId {
+			s.data.deleteTodo(id)
+		}
+	}
+	for id, row := range s.data.repeats {
+		if row.userId == userId {
+			s.data.deleteRepeatModelIfUnused(id)
+		}
+	}
+	return
+}
+
+// Format like MySQL `DATE`
+func normalizeDate(date *string) {
+	if date == nil {
+		return
+	}
+	if d, err := time.Parse("2006-1-2", *date); err == nil {
+		*date = d.Format("2006-01-02")
+	}
+}
+
+func (s *TodoStore) PutFeedToken(userId uint64, hash string) (err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	s.data.feedTokens[userId] = hash
+	return
+}
+
+func (s *TodoStore) GetFeedTokenUser(hash string) (userId uint64, notFound bool, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	for id, h := range s.data.feedTokens {
+		if h == hash {
+			return id, false, nil
+		}
+	}
+	notFound = true
+	return
+}
+
+func (s *TodoStore) DeleteFeedToken(userId uint64) (notFound bool, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	if _, ok := s.data.feedTokens[userId]; !ok {
+		notFound = true
+		return
+	}
+	delete(s.data.feedTokens, userId)
+	return
+}
+
+func (s *TodoStore) PutCalDAVResource(userId uint64, r todo.CalDAVResource) (err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	if row, ok := s.data.todos[r.TodoId]; !ok || row.userId != userId {
+		return
+	}
+	if s.data.caldavResources[userId] == nil {
+		s.data.caldavResources[userId] = map[string]todo.CalDAVResource{}
+	}
+	s.data.caldavResources[userId][r.Name] = r
+	return
+}
+
+func (s *TodoStore) ListCalDAVResources(userId uint64) (resources []todo.CalDAVResource, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	for _, r := range s.data.caldavResources[userId] {
+		resources = append(resources, r)
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].Name < resources[j].Name })
+	return
+}
+
+func (s *TodoStore) DeleteCalDAVResource(userId uint64, name string) (err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	delete(s.data.caldavResources[userId], name)
+	return
+}
+
+func (s *TodoStore) PutException(userId uint64, repeatModelId uint64, e todo.RepeatException) (err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	row, ok := s.data.repeats[repeatModelId]
+	if !ok || row.userId != userId {
+		return
+	}
+	normalizeDate(&e.Date)
+	if e.NewDate != nil {
+		newDate := *e.NewDate
+		normalizeDate(&newDate)
+		e.NewDate = &newDate
+	}
+	for _, tm := range []**string{&e.Time, &e.SeriesTime} {
+		if *tm == nil {
+			continue
+		}
+		if d, err := time.Parse("15:4", **tm); err == nil {
+			str := d.Format("15:04")
+			*tm = &str
+		}
+	}
+
+	if s.data.exceptions[repeatModelId] == nil {
+		s.data.exceptions[repeatModelId] = map[string]todo.RepeatException{}
+	}
+	s.data.exceptions[repeatModelId][e.Date] = e
+	return
+}
+
+func (s *TodoStore) DeleteException(userId uint64, repeatModelId uint64, date string) (notFound bool, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	row, ok := s.data.repeats[repeatModelId]
+	if !ok || row.userId != userId {
+		return true, nil
+	}
+	normalizeDate(&date)
+	if _, ok := s.data.exceptions[repeatModelId][date]; !ok {
+		return true, nil
+	}
+	delete(s.data.exceptions[repeatModelId], date)
+	return false, nil
+}
+
+func (s *TodoStore) DeleteExceptionsBefore(userId uint64, repeatModelId uint64, date string) (err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	row, ok := s.data.repeats[repeatModelId]
+	if !ok || row.userId != userId {
+		return
+	}
+	normalizeDate(&date)
+	for d := range s.data.exceptions[repeatModelId] {
+		if d < date {
+			delete(s.data.exceptions[repeatModelId], d)
+		}
+	}
+	return
+}
+
+func (s *TodoStore) InsertOperation(userId uint64, op todo.Operation) (err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	s.data.operationSeq++
+	op.Id = s.data.operationSeq
+	s.data.operations[op.Id] = operationRow{userId, op}
+	return
+}
+
+func (s *TodoStore) LastOperation(userId uint64, todoId uint64, kind string) (op todo.Operation, notFound bool, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	notFound = true
+	for _, row := range s.data.operations {
+		if row.userId != userId || row.operation.TodoId != todoId || row.operation.Kind != kind {
+			continue
+		}
+		if notFound || row.operation.Id > op.Id {
+			op = row.operation
+			notFound = false
+		}
+	}
+	return
+}
+
+func (s *TodoStore) DeleteOperation(userId uint64, id uint64) (err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	if row, ok := s.data.operations[id]; ok && row.userId == userId {
+		delete(s.data.operations, id)
+	}
+	return
+}
+
+func (s *TodoStore) ListLabels(userId uint64) (labels []todo.Label, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	for _, row := range s.data.labels {
+		if row.userId == userId {
+			labels = append(labels, row.label)
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Id < labels[j].Id
+	})
+	return
+}
+
+func (s *TodoStore) GetLabel(userId uint64, id uint64) (l todo.Label, notFound bool, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	row, ok := s.data.labels[id]
+	if !ok || row.userId != userId {
+		notFound = true
+		return
+	}
+	return row.label, false, nil
+}
+
+func (s *TodoStore) InsertLabel(userId uint64, l todo.Label) (inserted todo.Label, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	s.data.labelSeq++
+	l.Id = s.data.labelSeq
+	s.data.labels[l.Id] = labelRow{userId, l}
+	return l, nil
+}
+
+func (s *TodoStore) UpdateLabel(userId uint64, l todo.Label) (updated todo.Label, notFound bool, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	row, ok := s.data.labels[l.Id]
+	if !ok || row.userId != userId {
+		// Not found
+		return updated, true, nil
+	}
+	s.data.labels[l.Id] = labelRow{userId, l}
+	return l, false, nil
+}
+
+func (s *TodoStore) DeleteLabel(userId uint64, id uint64) (notFound bool, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	row, ok := s.data.labels[id]
+	if !ok || row.userId != userId {
+		notFound = true
+		return
+	}
+	delete(s.data.labels, id)
+
+	// Remove from todos
+	for todoId, row := range s.data.todos {
+		var labels []uint64
+		for _, l := range row.todo.Labels {
+			if l != id {
+				labels = append(labels, l)
+			}
+		}
+		if len(labels) == len(row.todo.Labels) {
+			continue
+		}
+		row.todo.Labels = labels
+		s.data.todos[todoId] = row
+	}
+	return
+}
+
+// Replace the checklist of the todo with the result of fn on its copy
+func (d *data) updateChecklist(userId uint64, todoId uint64, fn func(checklist []todo.ChecklistItem) []todo.ChecklistItem) (ok bool) {
+	row, found := d.todos[todoId]
+	if !found || row.userId != userId {
+		return
+	}
+	checklist := fn(append([]todo.ChecklistItem{}, row.todo.Checklist...))
+	if len(checklist) == 0 {
+		checklist = nil
+	}
+	row.todo.Checklist = checklist
+	d.todos[todoId] = row
+	return true
+}
+
+func (s *TodoStore) InsertChecklistItem(userId uint64, todoId uint64, item todo.ChecklistItem) (inserted todo.ChecklistItem, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	s.data.updateChecklist(userId, todoId, func(checklist []todo.ChecklistItem) []todo.ChecklistItem {
+		s.data.checklistSeq++
+		item.Id = s.data.checklistSeq
+		return append(checklist, item)
+	})
+	return item, nil
+}
+
+func (s *TodoStore) UpdateChecklistItem(userId uint64, todoId uint64, item todo.ChecklistItem) (updated todo.ChecklistItem, notFound bool, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	notFound = true
+	s.data.updateChecklist(userId, todoId, func(checklist []todo.ChecklistItem) []todo.ChecklistItem {
+		for i := range checklist {
+			if checklist[i].Id == item.Id {
+				checklist[i] = item
+				notFound = false
+			}
+		}
+		return checklist
+	})
+	if notFound {
+		return
+	}
+	return item, false, nil
+}
+
+func (s *TodoStore) DeleteChecklistItem(userId uint64, todoId uint64, id uint64) (notFound bool, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	notFound = true
+	s.data.updateChecklist(userId, todoId, func(checklist []todo.ChecklistItem) (kept []todo.ChecklistItem) {
+		for _, item := range checklist {
+			if item.Id == id {
+				notFound = false
+				continue
+			}
+			kept = append(kept, item)
+		}
+		return
+	})
+	return
+}
+
+func (s *TodoStore) ReorderChecklist(userId uint64, todoId uint64, ids []uint64) (err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	position := map[uint64]int{}
+	for i, id := range ids {
+		position[id] = i
+	}
+	s.data.updateChecklist(userId, todoId, func(checklist []todo.ChecklistItem) []todo.ChecklistItem {
+		sort.SliceStable(checklist, func(i, j int) bool {
+			return position[checklist[i].Id] < position[checklist[j].Id]
+		})
+		return checklist
+	})
+	return
+}
+
+func (s *TodoStore) ListDependencies(userId uint64) (dependencies []todo.Dependency, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	for id, row := range s.data.todos {
+		if row.userId != userId {
+			continue
+		}
+		for _, blockerId := range row.todo.BlockedBy {
+			dependencies = append(dependencies, todo.Dependency{TodoId: id, BlockerId: blockerId})
+		}
+	}
+	return
+}
+
+func (s *TodoStore) InsertOutboxEvent(e todo.OutboxEvent) (err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	s.data.outboxSeq++
+	e.Id = s.data.outboxSeq
+	e.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	s.data.outbox[e.Id] = e
+	return
+}
+
+func (s *TodoStore) ListOutboxEvents(after uint64, limit int) (events []todo.OutboxEvent, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	for _, e := range s.data.outbox {
+		if e.PublishedAt == nil && e.DeadAt == nil && e.Id > after {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Id < events[j].Id })
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return
+}
+
+func (s *TodoStore) UpdateOutboxEvent(e todo.OutboxEvent) (err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	current, ok := s.data.outbox[e.Id]
+	if !ok {
+		return
+	}
+	current.Attempts = e.Attempts
+	current.LastError = e.LastError
+	current.NextAttemptAt = e.NextAttemptAt
+	current.DeadAt = e.DeadAt
+	current.PublishedAt = e.PublishedAt
+	s.data.outbox[e.Id] = current
+	return
+}
+
+func (s *TodoStore) OutboxStats() (pending int, dead int, oldest *time.Time, err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	for _, e := range s.data.outbox {
+		if e.PublishedAt != nil {
+			continue
+		}
+		if e.DeadAt != nil {
+			dead++
+			continue
+		}
+		pending++
+		if oldest == nil || e.CreatedAt.Before(*oldest) {
+			createdAt := e.CreatedAt
+			oldest = &createdAt
+		}
+	}
+	return
+}
+
+func (s *TodoStore) DeletePublishedOutboxEvents(before time.Time) (err error) {
+	unlock := s.lock()
+	defer unlock()
+
+	for id, e := range s.data.outbox {
+		if e.PublishedAt != nil && e.PublishedAt.Before(before) {
+			delete(s.data.outbox, id)
+		}
+	}
+	return
+}
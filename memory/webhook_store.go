@@ -0,0 +1,174 @@
+package memory
+
+import (
+	"flow-todos/webhook"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Implements webhook.Store
+type WebhookStore struct {
+	mu              *sync.Mutex
+	subscriptionSeq uint64
+	subscriptions   map[uint64]webhook.Subscription
+	deliverySeq     uint64
+	deliveries      map[uint64]webhook.Delivery
+}
+
+func NewWebhookStore() *WebhookStore {
+	return &WebhookStore{
+		mu:            &sync.Mutex{},
+		subscriptions: map[uint64]webhook.Subscription{},
+		deliveries:    map[uint64]webhook.Delivery{},
+	}
+}
+
+// Copy of the slice shared with the caller
+func copySubscription(s webhook.Subscription) webhook.Subscription {
+	s.Events = append([]string{}, s.Events...)
+	return s
+}
+
+func (s *WebhookStore) ListSubscriptions(userId uint64) (subscriptions []webhook.Subscription, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sub := range s.subscriptions {
+		if sub.UserId == userId {
+			subscriptions = append(subscriptions, copySubscription(sub))
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].Id < subscriptions[j].Id })
+	return
+}
+
+func (s *WebhookStore) GetSubscription(userId uint64, id uint64) (sub webhook.Subscription, notFound bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subscriptions[id]
+	if !ok || sub.UserId != userId {
+		return webhook.Subscription{}, true, nil
+	}
+	return copySubscription(sub), false, nil
+}
+
+func (s *WebhookStore) InsertSubscription(sub webhook.Subscription) (inserted webhook.Subscription, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptionSeq++
+	sub.Id = s.subscriptionSeq
+	sub.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	sub.UpdatedAt = sub.CreatedAt
+	sub = copySubscription(sub)
+	s.subscriptions[sub.Id] = sub
+	return copySubscription(sub), nil
+}
+
+func (s *WebhookStore) UpdateSubscription(sub webhook.Subscription) (updated webhook.Subscription, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.subscriptions[sub.Id]
+	if !ok || current.UserId != sub.UserId {
+		return sub, nil
+	}
+	current.Url = sub.Url
+	current.Events = append([]string{}, sub.Events...)
+	current.Secret = sub.Secret
+	current.Active = sub.Active
+	current.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	s.subscriptions[sub.Id] = current
+	return copySubscription(current), nil
+}
+
+func (s *WebhookStore) DeleteSubscription(userId uint64, id uint64) (notFound bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subscriptions[id]
+	if !ok || sub.UserId != userId {
+		return true, nil
+	}
+	delete(s.subscriptions, id)
+	for k, d := range s.deliveries {
+		if d.SubscriptionId == id {
+			delete(s.deliveries, k)
+		}
+	}
+	return
+}
+
+func (s *WebhookStore) InsertDelivery(d webhook.Delivery) (inserted webhook.Delivery, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliverySeq++
+	d.Id = s.deliverySeq
+	d.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	s.deliveries[d.Id] = d
+	return d, nil
+}
+
+func (s *WebhookStore) UpdateDelivery(d webhook.Delivery) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.deliveries[d.Id]
+	if !ok {
+		return
+	}
+	current.Status = d.Status
+	current.Attempts = d.Attempts
+	current.ResponseStatus = d.ResponseStatus
+	current.Error = d.Error
+	current.NextAttemptAt = d.NextAttemptAt
+	current.DeliveredAt = d.DeliveredAt
+	s.deliveries[d.Id] = current
+	return
+}
+
+func (s *WebhookStore) GetDelivery(userId uint64, subscriptionId uint64, id uint64) (d webhook.Delivery, notFound bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deliveries[id]
+	if !ok || d.UserId != userId || d.SubscriptionId != subscriptionId {
+		return webhook.Delivery{}, true, nil
+	}
+	return
+}
+
+func (s *WebhookStore) ListDeliveries(userId uint64, subscriptionId uint64, before uint64, limit int) (deliveries []webhook.Delivery, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.deliveries {
+		if d.UserId == userId && d.SubscriptionId == subscriptionId && (before == 0 || d.Id < before) {
+			deliveries = append(deliveries, d)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].Id > deliveries[j].Id })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return
+}
+
+func (s *WebhookStore) ClaimDeliveries(now time.Time, lease time.Duration, limit int) (deliveries []webhook.Delivery, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.deliveries {
+		if d.Status == webhook.DeliveryPending && d.NextAttemptAt != nil && !d.NextAttemptAt.After(now) {
+			deliveries = append(deliveries, d)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].NextAttemptAt.Equal(*deliveries[j].NextAttemptAt) {
+			return deliveries[i].NextAttemptAt.Before(*deliveries[j].NextAttemptAt)
+		}
+		return deliveries[i].Id < deliveries[j].Id
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	leased := now.Add(lease)
+	for _, d := range deliveries {
+		d.NextAttemptAt = &leased
+		s.deliveries[d.Id] = d
+	}
+	return
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flow-todos/migrate"
+	"fmt"
+	"strconv"
+)
+
+// flow-todos migrate [up [version] | down [steps] | status]
+func runMigrate(db *sql.DB, args []string) (err error) {
+	m, err := migrate.New(db)
+	if err != nil {
+		return
+	}
+
+	command := "up"
+	if len(args) != 0 {
+		command = args[0]
+	}
+	var n uint64
+	if len(args) > 1 {
+		n, err = strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return
+		}
+	}
+
+	switch command {
+	case "up":
+		var done []migrate.Migration
+		done, err = m.Up(n)
+		for _, migration := range done {
+			fmt.Printf("applied  %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		var done []migrate.Migration
+		done, err = m.Down(int(n))
+		for _, migration := range done {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+	case "status":
+		var status []migrate.Status
+		status, err = m.Status()
+		for _, s := range status {
+			if s.AppliedAt != nil {
+				fmt.Printf("%04d_%s\tapplied at %s\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02T15:04:05Z"))
+			} else {
+				fmt.Printf("%04d_%s\tpending\n", s.Version, s.Name)
+			}
+		}
+	default:
+		err = errors.New("usage: migrate [up [version] | down [steps] | status]")
+	}
+	return
+}
@@ -0,0 +1,299 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// Name of the advisory lock taken with `GET_LOCK()` while migrating
+const lockName = "flow-todos.migrate"
+
+type Migration struct {
+	Version  uint64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+type Status struct {
+	Version   uint64     `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+type Migrator struct {
+	db          *sql.DB
+	migrations  []Migration
+	LockTimeout time.Duration
+}
+
+// `0001_init.up.sql`
+var fileNameRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load migrations embedded in the binary ordered by version
+func Load() (migrations []Migration, err error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return
+	}
+	byVersion := map[uint64]*Migration{}
+	for _, entry := range entries {
+		m := fileNameRegexp.FindStringSubmatch(entry.Name())
+		if m == nil {
+			err = fmt.Errorf("invalid migration file name `%s`", entry.Name())
+			return
+		}
+		var version uint64
+		version, err = strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return
+		}
+		var content []byte
+		content, err = files.ReadFile("sql/" + entry.Name())
+		if err != nil {
+			return
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		}
+		if m[3] == "up" {
+			migration.Up = string(content)
+			sum := sha256.Sum256(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			err = fmt.Errorf("migration %04d has no up file", migration.Version)
+			return
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return
+}
+
+func New(db *sql.DB) (m *Migrator, err error) {
+	migrations, err := Load()
+	if err != nil {
+		return
+	}
+	m = &Migrator{db: db, migrations: migrations, LockTimeout: time.Minute}
+	return
+}
+
+// Split a migration file into statements.
+// Statements end with `;` at the end of a line, lines starting with `--` are comments.
+func statements(content string) (stmts []string) {
+	var current []string
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current = append(current, line)
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(strings.Join(current, "\n")), ";"))
+			current = nil
+		}
+	}
+	if len(current) != 0 {
+		stmts = append(stmts, strings.TrimSpace(strings.Join(current, "\n")))
+	}
+	return
+}
+
+// Run fn holding the advisory lock, so replicas do not migrate concurrently.
+// `GET_LOCK()` is bound to the session, so all queries run on a single connection.
+func (m *Migrator) withLock(fn func(conn *sql.Conn) error) (err error) {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var locked *int
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(m.LockTimeout.Seconds())).Scan(&locked)
+	if err != nil {
+		return
+	}
+	if locked == nil || *locked != 1 {
+		return errors.New("failed to acquire migration lock, another migration may be running")
+	}
+	defer func() {
+		_, err2 := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+		if err == nil {
+			err = err2
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx,
+		"CREATE TABLE IF NOT EXISTS `schema_migrations` ("+
+			"`version` BIGINT UNSIGNED NOT NULL,"+
+			"`name` VARCHAR(255) NOT NULL,"+
+			"`checksum` CHAR(64) NOT NULL,"+
+			"`applied_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,"+
+			"PRIMARY KEY (`version`))",
+	)
+	if err != nil {
+		return
+	}
+	return fn(conn)
+}
+
+type applied struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Read `schema_migrations` and verify checksums of the applied migrations
+func (m *Migrator) applied(conn *sql.Conn) (rows map[uint64]applied, err error) {
+	ctx := context.Background()
+	r, err := conn.QueryContext(ctx, "SELECT version, name, checksum, DATE_FORMAT(applied_at, '%Y-%m-%dT%H:%i:%sZ') FROM schema_migrations")
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	rows = map[uint64]applied{}
+	for r.Next() {
+		var version uint64
+		var a applied
+		var appliedAt string
+		err = r.Scan(&version, &a.name, &a.checksum, &appliedAt)
+		if err != nil {
+			return
+		}
+		a.appliedAt, err = time.Parse(time.RFC3339, appliedAt)
+		if err != nil {
+			return
+		}
+		rows[version] = a
+	}
+	if err = r.Err(); err != nil {
+		return
+	}
+
+	known := map[uint64]bool{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		if a, ok := rows[migration.Version]; ok && a.checksum != migration.Checksum {
+			err = fmt.Errorf("checksum mismatch for applied migration %04d_%s", migration.Version, migration.Name)
+			return
+		}
+	}
+	for version, a := range rows {
+		if !known[version] {
+			err = fmt.Errorf("applied migration %04d_%s is unknown to this binary", version, a.name)
+			return
+		}
+	}
+	return
+}
+
+// Up applies pending migrations up to `target` (0: latest)
+func (m *Migrator) Up(target uint64) (done []Migration, err error) {
+	err = m.withLock(func(conn *sql.Conn) (err error) {
+		rows, err := m.applied(conn)
+		if err != nil {
+			return
+		}
+		ctx := context.Background()
+		for _, migration := range m.migrations {
+			if target != 0 && migration.Version > target {
+				break
+			}
+			if _, ok := rows[migration.Version]; ok {
+				continue
+			}
+			for _, stmt := range statements(migration.Up) {
+				if _, err = conn.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+				}
+			}
+			_, err = conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)", migration.Version, migration.Name, migration.Checksum)
+			if err != nil {
+				return
+			}
+			done = append(done, migration)
+		}
+		return
+	})
+	return
+}
+
+// Down reverts the last `steps` applied migrations
+func (m *Migrator) Down(steps int) (done []Migration, err error) {
+	err = m.withLock(func(conn *sql.Conn) (err error) {
+		rows, err := m.applied(conn)
+		if err != nil {
+			return
+		}
+		ctx := context.Background()
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := rows[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %04d_%s is irreversible", migration.Version, migration.Name)
+			}
+			for _, stmt := range statements(migration.Down) {
+				if _, err = conn.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+				}
+			}
+			_, err = conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+			if err != nil {
+				return
+			}
+			done = append(done, migration)
+		}
+		return
+	})
+	return
+}
+
+func (m *Migrator) Status() (status []Status, err error) {
+	err = m.withLock(func(conn *sql.Conn) (err error) {
+		rows, err := m.applied(conn)
+		if err != nil {
+			return
+		}
+		for _, migration := range m.migrations {
+			s := Status{Version: migration.Version, Name: migration.Name}
+			if a, ok := rows[migration.Version]; ok {
+				appliedAt := a.appliedAt
+				s.AppliedAt = &appliedAt
+			}
+			status = append(status, s)
+		}
+		return
+	})
+	return
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `todos`;
+DROP TABLE IF EXISTS `repeat_days`;
+DROP TABLE IF EXISTS `repeat_models`;
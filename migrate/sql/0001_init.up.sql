@@ -1,21 +1,15 @@
-SET SQL_MODE = "NO_AUTO_VALUE_ON_ZERO";
-START TRANSACTION;
-SET time_zone = "+00:00";
-
 --
--- Database: `flow-todos`
+-- Initial schema, formerly `.db/init.sql`
+-- `IF NOT EXISTS` lets databases created by `.db/init.sql` adopt this migration.
 --
 
-CREATE DATABASE IF NOT EXISTS `flow-todos` DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;
-USE `flow-todos`;
-
 -- --------------------------------------------------------
 
 --
 -- Table structure for table `repeat_models`
 --
 
-CREATE TABLE `repeat_models` (
+CREATE TABLE IF NOT EXISTS `repeat_models` (
   `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
   `user_id` BIGINT UNSIGNED NOT NULL,
   `until` DATE DEFAULT NULL,
@@ -31,7 +25,7 @@ CREATE TABLE `repeat_models` (
 -- Table structure for table `repeat_days`
 --
 
-CREATE TABLE `repeat_days` (
+CREATE TABLE IF NOT EXISTS `repeat_days` (
   `repeat_model_id` BIGINT UNSIGNED,
   `day` TINYINT(3) UNSIGNED NOT NULL,
   `time` TIME DEFAULT NULL,
@@ -45,7 +39,7 @@ CREATE TABLE `repeat_days` (
 -- Table structure for table `todos`
 --
 
-CREATE TABLE `todos` (
+CREATE TABLE IF NOT EXISTS `todos` (
   `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
   `user_id` BIGINT UNSIGNED NOT NULL,
   `name` VARCHAR(255) NOT NULL,
@@ -61,4 +55,4 @@ CREATE TABLE `todos` (
   `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   FOREIGN KEY (`repeat_model_id`) REFERENCES `repeat_models` (`id`) ON DELETE RESTRICT,
   PRIMARY KEY (id)
-);
\ No newline at end of file
+);
@@ -0,0 +1,5 @@
+UPDATE `todos` SET `repeat_model_id` = NULL WHERE `repeat_model_id` IN (SELECT `id` FROM `repeat_models` WHERE `unit` IS NULL);
+DELETE FROM `repeat_models` WHERE `unit` IS NULL;
+ALTER TABLE `repeat_models`
+  DROP `rrule`,
+  MODIFY `unit` VARCHAR(7) NOT NULL;
@@ -0,0 +1,8 @@
+--
+-- RFC 5545 recurrence rule, stored alongside the unit based repeat
+-- `unit` is null for repeats defined only by `rrule`
+--
+
+ALTER TABLE `repeat_models`
+  MODIFY `unit` VARCHAR(7) DEFAULT NULL,
+  ADD `rrule` VARCHAR(255) DEFAULT NULL AFTER `date`;
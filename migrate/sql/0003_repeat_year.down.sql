@@ -0,0 +1,7 @@
+UPDATE `todos` SET `repeat_model_id` = NULL WHERE `repeat_model_id` IN (SELECT `id` FROM `repeat_models` WHERE `unit` = 'year');
+DELETE FROM `repeat_models` WHERE `unit` = 'year';
+ALTER TABLE `repeat_models`
+  DROP `leap_day`,
+  DROP `month`,
+  DROP CHECK `repeat_models_unit_chk`,
+  ADD CONSTRAINT `repeat_models_chk_1` CHECK(`unit` IN('day','week','month'));
@@ -0,0 +1,10 @@
+--
+-- Yearly repeat anchored to `month` and `date`
+-- `leap_day` is the fallback of Feb 29 in common years ('feb28' or 'mar1')
+--
+
+ALTER TABLE `repeat_models`
+  DROP CHECK `repeat_models_chk_1`,
+  ADD CONSTRAINT `repeat_models_unit_chk` CHECK(`unit` IN('day','week','month','year')),
+  ADD `month` TINYINT UNSIGNED DEFAULT NULL CHECK(`month` BETWEEN 1 AND 12) AFTER `date`,
+  ADD `leap_day` VARCHAR(5) DEFAULT NULL CHECK(`leap_day` IN('feb28','mar1')) AFTER `month`;
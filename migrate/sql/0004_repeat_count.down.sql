@@ -0,0 +1,3 @@
+ALTER TABLE `repeat_models`
+  DROP `done`,
+  DROP `count`;
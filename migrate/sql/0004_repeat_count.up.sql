@@ -0,0 +1,8 @@
+--
+-- Repeat ends after `count` occurrences
+-- `done` is the number of occurrences completed or skipped in the chain of todos
+--
+
+ALTER TABLE `repeat_models`
+  ADD `count` INT UNSIGNED DEFAULT NULL CHECK(`count` >= 1) AFTER `rrule`,
+  ADD `done` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `count`;
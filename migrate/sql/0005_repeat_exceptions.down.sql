@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `repeat_exceptions`;
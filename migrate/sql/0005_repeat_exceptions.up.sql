@@ -0,0 +1,22 @@
+--
+-- Exceptions of single occurrences of repeats (cancelled or moved with overrides)
+-- `series_*` keep the values replaced by the overrides once the occurrence became a todo
+--
+
+CREATE TABLE IF NOT EXISTS `repeat_exceptions` (
+  `repeat_model_id` BIGINT UNSIGNED NOT NULL,
+  `date` DATE NOT NULL,
+  `cancelled` TINYINT(1) NOT NULL DEFAULT '0',
+  `new_date` DATE DEFAULT NULL,
+  `time` TIME DEFAULT NULL,
+  `name` VARCHAR(255) DEFAULT NULL,
+  `execution_time` INT DEFAULT NULL COMMENT 'minute',
+  `applied` TINYINT(1) NOT NULL DEFAULT '0',
+  `series_time` TIME DEFAULT NULL,
+  `series_name` VARCHAR(255) DEFAULT NULL,
+  `series_execution_time` INT DEFAULT NULL COMMENT 'minute',
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`repeat_model_id`, `date`),
+  FOREIGN KEY (`repeat_model_id`) REFERENCES `repeat_models` (`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+ALTER TABLE `repeat_models`
+  DROP `repeat_from`;
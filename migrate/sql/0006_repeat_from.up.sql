@@ -0,0 +1,6 @@
+--
+-- Anchor of the next occurrence, the scheduled date or the completion of the todo
+--
+
+ALTER TABLE `repeat_models`
+  ADD `repeat_from` VARCHAR(10) NOT NULL DEFAULT 'schedule' CHECK(`repeat_from` IN('schedule','completion')) AFTER `done`;
@@ -0,0 +1,16 @@
+--
+-- Operations on todos (`complete`, `skip`) recorded to be undone
+-- `data` holds the todos before and after the operation
+--
+
+CREATE TABLE IF NOT EXISTS `todo_operations` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  `todo_id` BIGINT UNSIGNED NOT NULL,
+  `kind` VARCHAR(10) NOT NULL CHECK(`kind` IN('complete','skip')),
+  `data` JSON NOT NULL,
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `todo_operations_todo` (`user_id`, `todo_id`, `kind`),
+  FOREIGN KEY (`todo_id`) REFERENCES `todos` (`id`) ON DELETE CASCADE
+);
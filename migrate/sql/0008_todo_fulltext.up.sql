@@ -0,0 +1,6 @@
+--
+-- Full-text search on todos (`GET /search`)
+--
+
+ALTER TABLE `todos`
+  ADD FULLTEXT INDEX `todos_fulltext` (`name`, `description`);
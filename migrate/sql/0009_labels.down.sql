@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `todo_labels`;
+DROP TABLE IF EXISTS `labels`;
@@ -0,0 +1,24 @@
+--
+-- Labels of todos, like `@errand`, across projects and sprints
+--
+
+CREATE TABLE IF NOT EXISTS `labels` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  `name` VARCHAR(255) NOT NULL,
+  `color` CHAR(7) NOT NULL,
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `labels_name` (`user_id`, `name`)
+);
+
+CREATE TABLE IF NOT EXISTS `todo_labels` (
+  `todo_id` BIGINT UNSIGNED NOT NULL,
+  `label_id` BIGINT UNSIGNED NOT NULL,
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`todo_id`, `label_id`),
+  KEY `todo_labels_label` (`label_id`),
+  FOREIGN KEY (`todo_id`) REFERENCES `todos` (`id`) ON DELETE CASCADE,
+  FOREIGN KEY (`label_id`) REFERENCES `labels` (`id`) ON DELETE CASCADE
+);
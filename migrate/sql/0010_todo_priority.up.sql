@@ -0,0 +1,6 @@
+--
+-- Priority of todos, 1 (highest) to 4
+--
+
+ALTER TABLE `todos`
+  ADD `priority` TINYINT UNSIGNED DEFAULT NULL CHECK(`priority` BETWEEN 1 AND 4) AFTER `completed`;
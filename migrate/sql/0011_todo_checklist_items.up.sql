@@ -0,0 +1,16 @@
+--
+-- Checklist items of todos, ordered by `position`
+--
+
+CREATE TABLE IF NOT EXISTS `todo_checklist_items` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `todo_id` BIGINT UNSIGNED NOT NULL,
+  `name` VARCHAR(255) NOT NULL,
+  `checked` BOOLEAN NOT NULL DEFAULT false,
+  `position` INT UNSIGNED NOT NULL,
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `todo_checklist_items_todo` (`todo_id`, `position`),
+  FOREIGN KEY (`todo_id`) REFERENCES `todos` (`id`) ON DELETE CASCADE
+);
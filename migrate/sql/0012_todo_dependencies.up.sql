@@ -0,0 +1,13 @@
+--
+-- Dependencies between todos, `todo_id` is blocked by `blocker_id` until the blocker is completed
+--
+
+CREATE TABLE IF NOT EXISTS `todo_dependencies` (
+  `todo_id` BIGINT UNSIGNED NOT NULL,
+  `blocker_id` BIGINT UNSIGNED NOT NULL,
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`todo_id`, `blocker_id`),
+  KEY `todo_dependencies_blocker` (`blocker_id`),
+  FOREIGN KEY (`todo_id`) REFERENCES `todos` (`id`) ON DELETE CASCADE,
+  FOREIGN KEY (`blocker_id`) REFERENCES `todos` (`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,11 @@
+--
+-- Secret tokens of the calendar feed URLs, only the SHA-256 of a token is stored
+--
+
+CREATE TABLE IF NOT EXISTS `feed_tokens` (
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  `token_hash` CHAR(64) NOT NULL,
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`user_id`),
+  UNIQUE KEY `feed_tokens_hash` (`token_hash`)
+);
@@ -0,0 +1,14 @@
+--
+-- Names and UIDs of the CalDAV resources created by clients, other todos are served as `<id>.ics`
+--
+
+CREATE TABLE IF NOT EXISTS `caldav_resources` (
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  `name` VARCHAR(255) NOT NULL,
+  `todo_id` BIGINT UNSIGNED NOT NULL,
+  `uid` VARCHAR(255) NOT NULL,
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`user_id`, `name`),
+  KEY `caldav_resources_todo` (`todo_id`),
+  FOREIGN KEY (`todo_id`) REFERENCES `todos` (`id`) ON DELETE CASCADE
+);
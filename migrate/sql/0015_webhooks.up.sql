@@ -0,0 +1,41 @@
+--
+-- Webhook subscriptions of users, and of the system with `user_id` 0
+--
+
+CREATE TABLE IF NOT EXISTS `webhook_subscriptions` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  `url` VARCHAR(2048) NOT NULL,
+  `events` VARCHAR(255) NOT NULL COMMENT 'Comma separated',
+  `secret` VARCHAR(255) NOT NULL,
+  `active` BOOLEAN NOT NULL DEFAULT TRUE,
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `webhook_subscriptions_user` (`user_id`)
+);
+
+--
+-- Log of deliveries, pending deliveries are sent when `next_attempt_at` is due
+--
+
+CREATE TABLE IF NOT EXISTS `webhook_deliveries` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `subscription_id` BIGINT UNSIGNED NOT NULL,
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  `event_id` CHAR(32) NOT NULL,
+  `event` VARCHAR(32) NOT NULL,
+  `payload` MEDIUMTEXT NOT NULL,
+  `status` ENUM('pending', 'succeeded', 'failed') NOT NULL DEFAULT 'pending',
+  `attempts` INT UNSIGNED NOT NULL DEFAULT 0,
+  `response_status` INT DEFAULT NULL,
+  `error` TEXT DEFAULT NULL,
+  `next_attempt_at` DATETIME DEFAULT NULL,
+  `delivered_at` DATETIME DEFAULT NULL,
+  `redelivery_of` BIGINT UNSIGNED DEFAULT NULL,
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `webhook_deliveries_subscription` (`subscription_id`, `id`),
+  KEY `webhook_deliveries_due` (`status`, `next_attempt_at`),
+  FOREIGN KEY (`subscription_id`) REFERENCES `webhook_subscriptions` (`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,17 @@
+--
+-- Events of todos written in the transaction of the change, published by the outbox dispatcher
+--
+
+CREATE TABLE IF NOT EXISTS `outbox` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `user_id` BIGINT UNSIGNED NOT NULL,
+  `type` VARCHAR(32) NOT NULL,
+  `data` MEDIUMTEXT NOT NULL,
+  `attempts` INT UNSIGNED NOT NULL DEFAULT 0,
+  `last_error` TEXT DEFAULT NULL,
+  `next_attempt_at` DATETIME DEFAULT NULL,
+  `published_at` DATETIME DEFAULT NULL,
+  `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `outbox_published` (`published_at`, `id`)
+);
@@ -0,0 +1,6 @@
+--
+-- Events given up after the maximum attempts, kept for inspection and not retried
+--
+
+ALTER TABLE `outbox`
+  ADD `dead_at` DATETIME DEFAULT NULL AFTER `next_attempt_at`;
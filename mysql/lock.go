@@ -0,0 +1,31 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+)
+
+// TryLock runs fn holding the advisory lock of the name, so only one replica runs it at a time.
+// locked is false if another session holds the lock, fn is not run then.
+// `GET_LOCK()` is bound to the session, so the lock is held on a dedicated connection.
+func TryLock(db *sql.DB, name string, fn func() error) (locked bool, err error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var result *int
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", name).Scan(&result)
+	if err != nil || result == nil || *result != 1 {
+		return
+	}
+	defer func() {
+		_, err2 := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", name)
+		if err == nil {
+			err = err2
+		}
+	}()
+	return true, fn()
+}
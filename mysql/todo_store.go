@@ -0,0 +1,1311 @@
+package mysql
+
+import (
+	"database/sql"
+	"flow-todos/todo"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Implements todo.TodoStore
+type TodoStore struct {
+	db        *sql.DB
+	tx        *sql.Tx
+	savepoint int
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Prepare(query string) (*sql.Stmt, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func NewTodoStore(db *sql.DB) *TodoStore {
+	return &TodoStore{db: db}
+}
+
+func (s *TodoStore) conn() execer {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+func (s *TodoStore) WithTx(fn func(s todo.TodoStore) error) (err error) {
+	if s.tx != nil {
+		// Nested transaction
+		name := fmt.Sprintf("sp%d", s.savepoint+1)
+		_, err = s.tx.Exec("SAVEPOINT " + name)
+		if err != nil {
+			return
+		}
+		err = fn(&TodoStore{db: s.db, tx: s.tx, savepoint: s.savepoint + 1})
+		if err != nil {
+			if _, err2 := s.tx.Exec("ROLLBACK TO SAVEPOINT " + name); err2 != nil {
+				err = err2
+			}
+			return
+		}
+		_, err = s.tx.Exec("RELEASE SAVEPOINT " + name)
+		return
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return
+	}
+	err = fn(&TodoStore{db: s.db, tx: tx})
+	if err != nil {
+		if err2 := tx.Rollback(); err2 != nil {
+			err = err2
+		}
+		return
+	}
+	err = tx.Commit()
+	return
+}
+
+const selectTodoColumns = `SELECT
+		todo.id, todo.name, todo.description, todo.date, TIME_FORMAT(todo.time, '%H:%i') AS time, todo.execution_time, todo.sprint_id, todo.project_id, todo.completed, todo.priority,
+		DATE_FORMAT(todo.created_at, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(todo.updated_at, '%Y-%m-%d %H:%i:%s'),
+		rpm.id, rpm.until, rpm.unit, rpm.every_other, rpm.date, rpm.month, rpm.leap_day, rpm.rrule, rpm.count, rpm.done, rpm.repeat_from, rpd.day, TIME_FORMAT(rpd.time, '%H:%i') AS day_time`
+
+const joinRepeatModels = `LEFT JOIN repeat_models as rpm ON todo.repeat_model_id = rpm.id
+		LEFT JOIN repeat_days as rpd ON rpm.id = rpd.repeat_model_id`
+
+const selectTodos = selectTodoColumns + `
+	FROM todos as todo
+		` + joinRepeatModels
+
+// Scan rows selected by `selectTodos`, rows of a todo must be consecutive
+func scanTodos(rows *sql.Rows) (todos []todo.Todo, err error) {
+	for rows.Next() {
+		t := todo.Todo{}
+		var executionTime *uint
+		var createdAt, updatedAt *string
+		var repeatId *uint64
+		var repeatUnit *string
+		var repeatDone *uint
+		var repeatFrom *string
+		repeatModel := todo.Repeat{}
+		var repeatDayNum *uint
+		var repeatDayTime *string
+		err = rows.Scan(
+			&t.Id, &t.Name, &t.Description, &t.Date, &t.Time, &executionTime, &t.SprintId, &t.ProjectId, &t.Completed, &t.Priority,
+			&createdAt, &updatedAt,
+			&repeatId, &repeatModel.Until, &repeatUnit, &repeatModel.EveryOther, &repeatModel.Date, &repeatModel.Month, &repeatModel.LeapDay, &repeatModel.RRule, &repeatModel.Count, &repeatDone, &repeatFrom, &repeatDayNum, &repeatDayTime,
+		)
+		if err != nil {
+			return
+		}
+		if executionTime != nil {
+			t.ExecutionTime = *executionTime
+		}
+		t.CreatedAt, err = parseTimestamp(createdAt)
+		if err != nil {
+			return
+		}
+		t.UpdatedAt, err = parseTimestamp(updatedAt)
+		if err != nil {
+			return
+		}
+		if repeatId != nil {
+			repeatModel.Id = *repeatId
+			if repeatUnit != nil {
+				repeatModel.Unit = *repeatUnit
+			}
+			if repeatDone != nil {
+				repeatModel.Done = *repeatDone
+			}
+			if repeatFrom != nil {
+				repeatModel.From = *repeatFrom
+			}
+			if repeatModel.Unit == "week" && repeatDayNum != nil {
+				repeatModel.Days = []todo.RepeatDay{{Day: *repeatDayNum, Time: repeatDayTime}}
+			}
+			t.Repeat = &repeatModel
+		}
+
+		if len(todos) != 0 && todos[len(todos)-1].Id == t.Id {
+			// Join repeat days
+			last := &todos[len(todos)-1]
+			if last.Repeat != nil && t.Repeat != nil {
+				last.Repeat.Days = append(last.Repeat.Days, t.Repeat.Days...)
+			}
+			continue
+		}
+		todos = append(todos, t)
+	}
+	err = rows.Err()
+	return
+}
+
+func (s *TodoStore) Get(userId uint64, id uint64) (t todo.Todo, notFound bool, err error) {
+	stmt, err := s.conn().Prepare(selectTodos + " WHERE todo.user_id = ? AND todo.id = ? ORDER BY rpd.day, rpd.time")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(userId, id)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	todos, err := scanTodos(rows)
+	if err != nil {
+		return
+	}
+	if len(todos) == 0 {
+		// Not found
+		notFound = true
+		return
+	}
+	err = s.fill(todos)
+	if err != nil {
+		return
+	}
+	t = todos[0]
+	return
+}
+
+// `WHERE` clause of the filters of q
+func listWhere(userId uint64, q todo.GetListQuery) (whereStr string, queryParams []interface{}) {
+	whereStr = " WHERE todo.user_id = ?"
+	queryParams = []interface{}{userId}
+	if q.Start != nil && q.End != nil {
+		whereStr += " AND ADDTIME(CONVERT(todo.date,DATETIME),COALESCE(todo.time,0)) BETWEEN ? AND ?"
+		queryParams = append(queryParams, q.Start, q.End)
+	} else if q.Start != nil {
+		whereStr += " AND ADDTIME(CONVERT(todo.date,DATETIME),COALESCE(todo.time,0)) >= ?"
+		queryParams = append(queryParams, q.Start)
+	} else if q.End != nil {
+		whereStr += " AND ADDTIME(CONVERT(todo.date,DATETIME),COALESCE(todo.time,0)) <= ?"
+		queryParams = append(queryParams, q.End)
+	}
+	if q.ProjectIds != nil {
+		if len(q.ProjectIds) == 0 {
+			whereStr += " AND false"
+		} else {
+			whereStr += " AND todo.project_id IN (?" + strings.Repeat(", ?", len(q.ProjectIds)-1) + ")"
+			for _, id := range q.ProjectIds {
+				queryParams = append(queryParams, id)
+			}
+		}
+	}
+	if q.SprintId != nil {
+		whereStr += " AND todo.sprint_id = ?"
+		queryParams = append(queryParams, q.SprintId)
+	}
+	if q.OnlyCompleted {
+		whereStr += " AND todo.completed = true"
+	} else if !q.WithCompleted {
+		whereStr += " AND todo.completed = false"
+	}
+	if q.OnlyRepeatModel {
+		whereStr += " AND todo.repeat_model_id IS NOT NULL"
+	}
+	if q.HasDate != nil {
+		if *q.HasDate {
+			whereStr += " AND todo.date IS NOT NULL"
+		} else {
+			whereStr += " AND todo.date IS NULL"
+		}
+	}
+	if q.IsRepeating != nil {
+		if *q.IsRepeating {
+			whereStr += " AND todo.repeat_model_id IS NOT NULL"
+		} else {
+			whereStr += " AND todo.repeat_model_id IS NULL"
+		}
+	}
+	if q.Text != nil {
+		pattern := "%" + escapeLike(*q.Text) + "%"
+		whereStr += " AND (todo.name LIKE ? OR todo.description LIKE ?)"
+		queryParams = append(queryParams, pattern, pattern)
+	}
+	if q.MinExecutionTime != nil {
+		whereStr += " AND COALESCE(todo.execution_time, 0) >= ?"
+		queryParams = append(queryParams, q.MinExecutionTime)
+	}
+	if q.MaxExecutionTime != nil {
+		whereStr += " AND COALESCE(todo.execution_time, 0) <= ?"
+		queryParams = append(queryParams, q.MaxExecutionTime)
+	}
+	if q.Priorities != nil {
+		if len(q.Priorities) == 0 {
+			whereStr += " AND false"
+		} else {
+			whereStr += " AND COALESCE(todo.priority, 0) IN (?" + strings.Repeat(", ?", len(q.Priorities)-1) + ")"
+			for _, p := range q.Priorities {
+				queryParams = append(queryParams, p)
+			}
+		}
+	}
+	if q.Blocked != nil {
+		blocked := "EXISTS (SELECT 1 FROM todo_dependencies JOIN todos AS blocker ON todo_dependencies.blocker_id = blocker.id WHERE todo_dependencies.todo_id = todo.id AND blocker.completed = false)"
+		if *q.Blocked {
+			whereStr += " AND " + blocked
+		} else {
+			whereStr += " AND NOT " + blocked
+		}
+	}
+	for _, id := range q.Labels {
+		whereStr += " AND EXISTS (SELECT 1 FROM todo_labels WHERE todo_labels.todo_id = todo.id AND todo_labels.label_id = ?)"
+		queryParams = append(queryParams, id)
+	}
+	return
+}
+
+func (s *TodoStore) List(userId uint64, q todo.GetListQuery) (todos []todo.Todo, err error) {
+	// Generate query
+	whereStr, queryParams := listWhere(userId, q)
+
+	// Sort and cursor
+	exprs := sortExprs(q.Sort, q.Order)
+	orderStr := " ORDER BY " + strings.Join(exprs, " "+strings.ToUpper(orderOrAsc(q.Order))+", ") + " " + strings.ToUpper(orderOrAsc(q.Order))
+	if q.After != nil && len(q.After) >= len(exprs) {
+		// Keys end with the date, which the id already decides for stored todos
+		op := ">"
+		if q.Order == todo.OrderDesc {
+			op = "<"
+		}
+		whereStr += " AND (" + strings.Join(exprs, ", ") + ") " + op + " (?" + strings.Repeat(", ?", len(exprs)-1) + ")"
+		for _, key := range q.After[:len(exprs)] {
+			queryParams = append(queryParams, key)
+		}
+	}
+	limitStr := ""
+	if q.Limit != 0 {
+		limitStr = " LIMIT ?"
+		queryParams = append(queryParams, q.Limit)
+	}
+
+	// Limit todos before joining repeat days
+	queryStr := selectTodoColumns + `
+	FROM (SELECT * FROM todos AS todo` + whereStr + orderStr + limitStr + `) AS todo
+		` + joinRepeatModels + orderStr + ", rpd.day, rpd.time"
+
+	stmt, err := s.conn().Prepare(queryStr)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(queryParams...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	todos, err = scanTodos(rows)
+	if err != nil {
+		return
+	}
+	err = s.fill(todos)
+	return
+}
+
+func (s *TodoStore) Search(userId uint64, text string, q todo.GetListQuery) (todos []todo.Todo, scores []float64, err error) {
+	// Rank by the full-text index `todos_fulltext`
+	whereStr, queryParams := listWhere(userId, q)
+	queryStr := "SELECT todo.id, MATCH(todo.name, todo.description) AGAINST(? IN NATURAL LANGUAGE MODE) AS score FROM todos AS todo" +
+		whereStr + " AND MATCH(todo.name, todo.description) AGAINST(? IN NATURAL LANGUAGE MODE) ORDER BY score DESC, todo.id"
+	queryParams = append([]interface{}{text}, append(queryParams, text)...)
+	if q.Limit != 0 {
+		queryStr += " LIMIT ?"
+		queryParams = append(queryParams, q.Limit)
+	}
+
+	rows, err := s.conn().Query(queryStr, queryParams...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	var ids []interface{}
+	for rows.Next() {
+		var id uint64
+		var score float64
+		err = rows.Scan(&id, &score)
+		if err != nil {
+			return
+		}
+		ids = append(ids, id)
+		scores = append(scores, score)
+	}
+	err = rows.Err()
+	if err != nil || len(ids) == 0 {
+		return
+	}
+
+	// Todos in the ranked order
+	rows2, err := s.conn().Query(
+		selectTodos+" WHERE todo.user_id = ? AND todo.id IN (?"+strings.Repeat(", ?", len(ids)-1)+") ORDER BY todo.id, rpd.day, rpd.time",
+		append([]interface{}{userId}, ids...)...,
+	)
+	if err != nil {
+		return
+	}
+	defer rows2.Close()
+	found, err := scanTodos(rows2)
+	if err != nil {
+		return
+	}
+	err = s.fill(found)
+	if err != nil {
+		return
+	}
+	byId := map[uint64]todo.Todo{}
+	for _, t := range found {
+		byId[t.Id] = t
+	}
+	for _, id := range ids {
+		todos = append(todos, byId[id.(uint64)])
+	}
+	return
+}
+
+// Escape wildcards of `LIKE` with the default escape character
+func escapeLike(str string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(str)
+}
+
+func orderOrAsc(order string) string {
+	if order == todo.OrderDesc {
+		return todo.OrderDesc
+	}
+	return todo.OrderAsc
+}
+
+// SQL expressions of `todo.SortKeys` without the trailing date
+func sortExprs(sortKey string, order string) (exprs []string) {
+	nullDate, nullTime, nullPriority := "'9999-12-31'", "'99:99'", "'9'"
+	if order == todo.OrderDesc {
+		nullDate, nullTime, nullPriority = "''", "''", "''"
+	}
+	date := "COALESCE(DATE_FORMAT(todo.date, '%Y-%m-%d'), " + nullDate + ")"
+	tm := "COALESCE(TIME_FORMAT(todo.time, '%H:%i'), " + nullTime + ")"
+	priority := "COALESCE(CAST(todo.priority AS CHAR), " + nullPriority + ")"
+
+	switch sortKey {
+	case todo.SortTime:
+		exprs = []string{tm}
+	case todo.SortName:
+		// Compare bytes as Go does
+		exprs = []string{"CAST(todo.name AS BINARY)"}
+	case todo.SortExecutionTime:
+		exprs = []string{"LPAD(COALESCE(todo.execution_time, 0), 10, '0')"}
+	case todo.SortCreatedAt:
+		exprs = []string{"DATE_FORMAT(todo.created_at, '%Y-%m-%d %H:%i:%s')"}
+	case todo.SortUpdatedAt:
+		exprs = []string{"DATE_FORMAT(todo.updated_at, '%Y-%m-%d %H:%i:%s')"}
+	case todo.SortPriority:
+		exprs = []string{priority, date, tm}
+	default:
+		exprs = []string{date, tm}
+	}
+	if sortKey != todo.SortPriority {
+		exprs = append(exprs, priority)
+	}
+	return append(exprs, "LPAD(todo.id, 20, '0')")
+}
+
+// DATETIME formatted by `DATE_FORMAT` in UTC
+func parseTimestamp(str *string) (t *time.Time, err error) {
+	if str == nil {
+		return
+	}
+	parsed, err := time.Parse("2006-01-02 15:04:05", *str)
+	if err != nil {
+		return
+	}
+	return &parsed, nil
+}
+
+// Set `repeat.exceptions`, `labels`, `checklist`, `blocked_by` and `blocks` of the todos
+func (s *TodoStore) fill(todos []todo.Todo) (err error) {
+	err = s.fillExceptions(todos)
+	if err != nil {
+		return
+	}
+	err = s.fillLabels(todos)
+	if err != nil {
+		return
+	}
+	err = s.fillChecklist(todos)
+	if err != nil {
+		return
+	}
+	return s.fillDependencies(todos)
+}
+
+// Set `blocked_by` and `blocks` of the todos ordered by id
+func (s *TodoStore) fillDependencies(todos []todo.Todo) (err error) {
+	if len(todos) == 0 {
+		return
+	}
+	byId := map[uint64]*todo.Todo{}
+	var ids []interface{}
+	for i := range todos {
+		byId[todos[i].Id] = &todos[i]
+		ids = append(ids, todos[i].Id)
+	}
+
+	in := "(?" + strings.Repeat(", ?", len(ids)-1) + ")"
+	rows, err := s.conn().Query(
+		"SELECT todo_id, blocker_id FROM todo_dependencies WHERE todo_id IN "+in+" OR blocker_id IN "+in+" ORDER BY todo_id, blocker_id",
+		append(ids, ids...)...,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	var dependencies []todo.Dependency
+	for rows.Next() {
+		d := todo.Dependency{}
+		err = rows.Scan(&d.TodoId, &d.BlockerId)
+		if err != nil {
+			return
+		}
+		dependencies = append(dependencies, d)
+		if t, ok := byId[d.TodoId]; ok {
+			t.BlockedBy = append(t.BlockedBy, d.BlockerId)
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+
+	// Ordered by todo_id
+	for _, d := range dependencies {
+		if t, ok := byId[d.BlockerId]; ok {
+			t.Blocks = append(t.Blocks, d.TodoId)
+		}
+	}
+	return
+}
+
+// Set `checklist` of the todos ordered by position
+func (s *TodoStore) fillChecklist(todos []todo.Todo) (err error) {
+	if len(todos) == 0 {
+		return
+	}
+	byId := map[uint64]*todo.Todo{}
+	var queryParams []interface{}
+	for i := range todos {
+		byId[todos[i].Id] = &todos[i]
+		queryParams = append(queryParams, todos[i].Id)
+	}
+
+	rows, err := s.conn().Query(
+		"SELECT todo_id, id, name, checked FROM todo_checklist_items WHERE todo_id IN (?"+strings.Repeat(", ?", len(queryParams)-1)+") ORDER BY todo_id, position, id",
+		queryParams...,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todoId uint64
+		item := todo.ChecklistItem{}
+		err = rows.Scan(&todoId, &item.Id, &item.Name, &item.Checked)
+		if err != nil {
+			return
+		}
+		t := byId[todoId]
+		t.Checklist = append(t.Checklist, item)
+	}
+	return rows.Err()
+}
+
+// Set `labels` of the todos ordered by id
+func (s *TodoStore) fillLabels(todos []todo.Todo) (err error) {
+	if len(todos) == 0 {
+		return
+	}
+	byId := map[uint64]*todo.Todo{}
+	var queryParams []interface{}
+	for i := range todos {
+		byId[todos[i].Id] = &todos[i]
+		queryParams = append(queryParams, todos[i].Id)
+	}
+
+	rows, err := s.conn().Query(
+		"SELECT todo_id, label_id FROM todo_labels WHERE todo_id IN (?"+strings.Repeat(", ?", len(queryParams)-1)+") ORDER BY todo_id, label_id",
+		queryParams...,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todoId, labelId uint64
+		err = rows.Scan(&todoId, &labelId)
+		if err != nil {
+			return
+		}
+		t := byId[todoId]
+		t.Labels = append(t.Labels, labelId)
+	}
+	return rows.Err()
+}
+
+// Set `repeat.exceptions` of the todos
+func (s *TodoStore) fillExceptions(todos []todo.Todo) (err error) {
+	byRepeatId := map[uint64][]*todo.Repeat{}
+	var queryParams []interface{}
+	for i := range todos {
+		if todos[i].Repeat == nil {
+			continue
+		}
+		id := todos[i].Repeat.Id
+		if _, ok := byRepeatId[id]; !ok {
+			queryParams = append(queryParams, id)
+		}
+		byRepeatId[id] = append(byRepeatId[id], todos[i].Repeat)
+	}
+	if len(queryParams) == 0 {
+		return
+	}
+
+	rows, err := s.conn().Query(
+		`SELECT
+			repeat_model_id, date, cancelled, new_date, TIME_FORMAT(time, '%H:%i'), name, execution_time,
+			applied, TIME_FORMAT(series_time, '%H:%i'), series_name, series_execution_time
+		FROM repeat_exceptions
+		WHERE repeat_model_id IN (?`+strings.Repeat(", ?", len(queryParams)-1)+`)
+		ORDER BY repeat_model_id, date`,
+		queryParams...,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var repeatId uint64
+		e := todo.RepeatException{}
+		err = rows.Scan(
+			&repeatId, &e.Date, &e.Cancelled, &e.NewDate, &e.Time, &e.Name, &e.ExecutionTime,
+			&e.Applied, &e.SeriesTime, &e.SeriesName, &e.SeriesExecutionTime,
+		)
+		if err != nil {
+			return
+		}
+		for _, r := range byRepeatId[repeatId] {
+			r.Exceptions = append(r.Exceptions, e)
+		}
+	}
+	return rows.Err()
+}
+
+// Empty string as NULL
+func nullString(str string) *string {
+	if str == "" {
+		return nil
+	}
+	return &str
+}
+
+func (s *TodoStore) insertRepeatModel(userId uint64, r *todo.Repeat) (err error) {
+	stmt, err := s.conn().Prepare("INSERT INTO repeat_models (user_id, until, unit, every_other, date, month, leap_day, rrule, count, done, repeat_from) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.Exec(userId, r.Until, nullString(r.Unit), r.EveryOther, r.Date, r.Month, r.LeapDay, r.RRule, r.Count, r.Done, nullString(r.From))
+	if err != nil {
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+	r.Id = uint64(id)
+
+	return s.insertRepeatDays(r)
+}
+
+// sql.ErrNoRows unless the repeat model of the user exists
+func (s *TodoStore) checkRepeatModel(userId uint64, id uint64) (err error) {
+	var exists bool
+	return s.conn().QueryRow("SELECT true FROM repeat_models WHERE user_id = ? AND id = ?", userId, id).Scan(&exists)
+}
+
+func (s *TodoStore) updateRepeatModel(userId uint64, r *todo.Repeat) (err error) {
+	stmt, err := s.conn().Prepare("UPDATE repeat_models SET until = ?, unit = ?, every_other = ?, date = ?, month = ?, leap_day = ?, rrule = ?, count = ?, done = ?, repeat_from = COALESCE(?, 'schedule') WHERE user_id = ? AND id = ?")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(r.Until, nullString(r.Unit), r.EveryOther, r.Date, r.Month, r.LeapDay, r.RRule, r.Count, r.Done, nullString(r.From), userId, r.Id)
+	if err != nil {
+		return
+	}
+
+	// Replace repeat days
+	stmtDeleteRepeatDays, err := s.conn().Prepare("DELETE FROM repeat_days WHERE repeat_model_id = ?")
+	if err != nil {
+		return
+	}
+	defer stmtDeleteRepeatDays.Close()
+	_, err = stmtDeleteRepeatDays.Exec(r.Id)
+	if err != nil {
+		return
+	}
+	return s.insertRepeatDays(r)
+}
+
+func (s *TodoStore) insertRepeatDays(r *todo.Repeat) (err error) {
+	if r.Unit != "week" || len(r.Days) == 0 {
+		return
+	}
+	queryStr := "INSERT INTO repeat_days (repeat_model_id, day, time) VALUES"
+	var queryParams []interface{}
+	for _, day := range r.Days {
+		queryStr += " (?, ?, ?),"
+		queryParams = append(queryParams, r.Id, day.Day, day.Time)
+	}
+	queryStr = strings.TrimRight(queryStr, ",")
+	stmt, err := s.conn().Prepare(queryStr)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(queryParams...)
+	return
+}
+
+func (s *TodoStore) Insert(userId uint64, t todo.Todo) (inserted todo.Todo, err error) {
+	// Repeat model
+	var idRepeatModel *uint64
+	if t.Repeat != nil {
+		repeat := *t.Repeat
+		if repeat.Id == 0 {
+			err = s.insertRepeatModel(userId, &repeat)
+		} else {
+			err = s.checkRepeatModel(userId, repeat.Id)
+		}
+		if err != nil {
+			return
+		}
+		t.Repeat = &repeat
+		idRepeatModel = &repeat.Id
+	}
+
+	// Insert DB
+	stmt, err := s.conn().Prepare("INSERT INTO todos (user_id, name, description, date, time, execution_time, sprint_id, project_id, completed, priority, repeat_model_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	result, err := stmt.Exec(userId, t.Name, t.Description, t.Date, t.Time, t.ExecutionTime, t.SprintId, t.ProjectId, t.Completed, t.Priority, idRepeatModel)
+	if err != nil {
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+
+	t.Id = uint64(id)
+
+	t.Labels, err = s.replaceLabels(userId, t.Id, t.Labels)
+	if err != nil {
+		return
+	}
+	t.BlockedBy, err = s.replaceDependencies(userId, t.Id, t.BlockedBy)
+	if err != nil {
+		return
+	}
+
+	// Checklist
+	for i := range t.Checklist {
+		var result sql.Result
+		result, err = s.conn().Exec(
+			"INSERT INTO todo_checklist_items (todo_id, name, checked, position) VALUES (?, ?, ?, ?)",
+			t.Id, t.Checklist[i].Name, t.Checklist[i].Checked, i,
+		)
+		if err != nil {
+			return
+		}
+		var itemId int64
+		itemId, err = result.LastInsertId()
+		if err != nil {
+			return
+		}
+		t.Checklist[i].Id = uint64(itemId)
+	}
+	inserted = t
+	return
+}
+
+func (s *TodoStore) Update(userId uint64, t todo.Todo) (updated todo.Todo, err error) {
+	// Current repeat model
+	var oldIdRepeatModel *uint64
+	err = s.conn().QueryRow("SELECT repeat_model_id FROM todos WHERE user_id = ? AND id = ?", userId, t.Id).Scan(&oldIdRepeatModel)
+	if err != nil {
+		return
+	}
+
+	// Repeat model
+	var idRepeatModel *uint64
+	newRepeatModel := t.Repeat != nil && t.Repeat.Id == 0
+	if t.Repeat != nil {
+		repeat := *t.Repeat
+		if newRepeatModel {
+			err = s.insertRepeatModel(userId, &repeat)
+		} else if err = s.checkRepeatModel(userId, repeat.Id); err == nil {
+			err = s.updateRepeatModel(userId, &repeat)
+		}
+		if err != nil {
+			return
+		}
+		t.Repeat = &repeat
+		idRepeatModel = &repeat.Id
+	}
+
+	// Update row
+	stmt, err := s.conn().Prepare("UPDATE todos SET name = ?, description = ?, date = ?, time = ?, execution_time = ?, sprint_id = ?, project_id = ?, completed = ?, priority = ?, repeat_model_id = ? WHERE user_id = ? AND id = ?")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(t.Name, t.Description, t.Date, t.Time, t.ExecutionTime, t.SprintId, t.ProjectId, t.Completed, t.Priority, idRepeatModel, userId, t.Id)
+	if err != nil {
+		return
+	}
+
+	t.Labels, err = s.replaceLabels(userId, t.Id, t.Labels)
+	if err != nil {
+		return
+	}
+	t.BlockedBy, err = s.replaceDependencies(userId, t.Id, t.BlockedBy)
+	if err != nil {
+		return
+	}
+
+	// Delete unused repeat model, kept for the earlier occurrences when a new one is created
+	if oldIdRepeatModel != nil && !newRepeatModel && (idRepeatModel == nil || *oldIdRepeatModel != *idRepeatModel) {
+		err = s.deleteRepeatModelIfUnused(*oldIdRepeatModel)
+		if err != nil {
+			return
+		}
+	}
+
+	updated = t
+	return
+}
+
+// Replace labels of the todo, ids of other users are ignored and not kept
+func (s *TodoStore) replaceLabels(userId uint64, todoId uint64, labels []uint64) (kept []uint64, err error) {
+	_, err = s.conn().Exec("DELETE FROM todo_labels WHERE todo_id = ?", todoId)
+	if err != nil {
+		return
+	}
+	kept, err = s.ownIds("labels", userId, labels)
+	if err != nil || len(kept) == 0 {
+		return
+	}
+	queryParams := []interface{}{}
+	for _, id := range kept {
+		queryParams = append(queryParams, todoId, id)
+	}
+	_, err = s.conn().Exec(
+		"INSERT INTO todo_labels (todo_id, label_id) VALUES (?, ?)"+strings.Repeat(", (?, ?)", len(kept)-1),
+		queryParams...,
+	)
+	return
+}
+
+// Replace blockers of the todo, ids of other users are ignored and not kept
+func (s *TodoStore) replaceDependencies(userId uint64, todoId uint64, blockedBy []uint64) (kept []uint64, err error) {
+	_, err = s.conn().Exec("DELETE FROM todo_dependencies WHERE todo_id = ?", todoId)
+	if err != nil {
+		return
+	}
+	kept, err = s.ownIds("todos", userId, blockedBy)
+	if err != nil || len(kept) == 0 {
+		return
+	}
+	queryParams := []interface{}{}
+	for _, id := range kept {
+		queryParams = append(queryParams, todoId, id)
+	}
+	_, err = s.conn().Exec(
+		"INSERT INTO todo_dependencies (todo_id, blocker_id) VALUES (?, ?)"+strings.Repeat(", (?, ?)", len(kept)-1),
+		queryParams...,
+	)
+	return
+}
+
+// Ids of the rows of the user in table, ordered by id
+func (s *TodoStore) ownIds(table string, userId uint64, ids []uint64) (own []uint64, err error) {
+	if len(ids) == 0 {
+		return
+	}
+	queryParams := []interface{}{userId}
+	for _, id := range ids {
+		queryParams = append(queryParams, id)
+	}
+	rows, err := s.conn().Query("SELECT id FROM "+table+" WHERE user_id = ? AND id IN (?"+strings.Repeat(", ?", len(ids)-1)+") ORDER BY id", queryParams...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uint64
+		err = rows.Scan(&id)
+		if err != nil {
+			return
+		}
+		own = append(own, id)
+	}
+	err = rows.Err()
+	return
+}
+
+func (s *TodoStore) UpdateRepeatModel(userId uint64, r todo.Repeat) (err error) {
+	return s.updateRepeatModel(userId, &r)
+}
+
+// Delete the repeat model unless a todo still uses it, exceptions are deleted by the foreign key
+func (s *TodoStore) deleteRepeatModelIfUnused(id uint64) (err error) {
+	_, err = s.conn().Exec("DELETE FROM repeat_models WHERE id = ? AND NOT EXISTS (SELECT 1 FROM todos WHERE repeat_model_id = ?)", id, id)
+	return
+}
+
+func (s *TodoStore) Delete(userId uint64, id uint64) (notFound bool, err error) {
+	var idRepeatModel *uint64
+	err = s.conn().QueryRow("SELECT repeat_model_id FROM todos WHERE user_id = ? AND id = ?", userId, id).Scan(&idRepeatModel)
+	if err == sql.ErrNoRows {
+		// Not found
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = s.conn().Exec("DELETE FROM todos WHERE user_id = ? AND id = ?", userId, id)
+	if err != nil {
+		return false, err
+	}
+	// Repeat models are shared by the occurrences of a series
+	if idRepeatModel != nil {
+		err = s.deleteRepeatModelIfUnused(*idRepeatModel)
+	}
+	return false, err
+}
+
+func (s *TodoStore) DeleteAll(userId uint64) (err error) {
+	_, err = s.conn().Exec("DELETE FROM todos WHERE user_id = ?", userId)
+	if err != nil {
+		return
+	}
+	_, err = s.conn().Exec(
+		"DELETE FROM repeat_models WHERE user_id = ? AND NOT EXISTS (SELECT 1 FROM todos WHERE todos.repeat_model_id = repeat_models.id)",
+		userId,
+	)
+	return
+}
+
+func (s *TodoStore) PutFeedToken(userId uint64, hash string) (err error) {
+	_, err = s.conn().Exec("INSERT INTO feed_tokens (user_id, token_hash) VALUES (?, ?) ON DUPLICATE KEY UPDATE token_hash = VALUES(token_hash), created_at = CURRENT_TIMESTAMP", userId, hash)
+	return
+}
+
+func (s *TodoStore) GetFeedTokenUser(hash string) (userId uint64, notFound bool, err error) {
+	err = s.conn().QueryRow("SELECT user_id FROM feed_tokens WHERE token_hash = ?", hash).Scan(&userId)
+	if err == sql.ErrNoRows {
+		// Not found
+		return 0, true, nil
+	}
+	return
+}
+
+func (s *TodoStore) DeleteFeedToken(userId uint64) (notFound bool, err error) {
+	result, err := s.conn().Exec("DELETE FROM feed_tokens WHERE user_id = ?", userId)
+	if err != nil {
+		return
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	notFound = affected == 0
+	return
+}
+
+func (s *TodoStore) PutCalDAVResource(userId uint64, r todo.CalDAVResource) (err error) {
+	_, err = s.conn().Exec(
+		`INSERT INTO caldav_resources (user_id, name, todo_id, uid)
+			SELECT user_id, ?, id, ? FROM todos WHERE user_id = ? AND id = ?
+			ON DUPLICATE KEY UPDATE todo_id = VALUES(todo_id), uid = VALUES(uid)`,
+		r.Name, r.Uid, userId, r.TodoId,
+	)
+	return
+}
+
+func (s *TodoStore) ListCalDAVResources(userId uint64) (resources []todo.CalDAVResource, err error) {
+	rows, err := s.conn().Query("SELECT name, todo_id, uid FROM caldav_resources WHERE user_id = ? ORDER BY name", userId)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r todo.CalDAVResource
+		if err = rows.Scan(&r.Name, &r.TodoId, &r.Uid); err != nil {
+			return
+		}
+		resources = append(resources, r)
+	}
+	err = rows.Err()
+	return
+}
+
+func (s *TodoStore) DeleteCalDAVResource(userId uint64, name string) (err error) {
+	_, err = s.conn().Exec("DELETE FROM caldav_resources WHERE user_id = ? AND name = ?", userId, name)
+	return
+}
+
+func (s *TodoStore) PutException(userId uint64, repeatModelId uint64, e todo.RepeatException) (err error) {
+	stmt, err := s.conn().Prepare(
+		`INSERT INTO repeat_exceptions
+			(repeat_model_id, date, cancelled, new_date, time, name, execution_time, applied, series_time, series_name, series_execution_time)
+			SELECT id, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? FROM repeat_models WHERE user_id = ? AND id = ?
+			ON DUPLICATE KEY UPDATE
+				cancelled = VALUES(cancelled), new_date = VALUES(new_date), time = VALUES(time), name = VALUES(name), execution_time = VALUES(execution_time),
+				applied = VALUES(applied), series_time = VALUES(series_time), series_name = VALUES(series_name), series_execution_time = VALUES(series_execution_time)`,
+	)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		e.Date, e.Cancelled, e.NewDate, e.Time, e.Name, e.ExecutionTime,
+		e.Applied, e.SeriesTime, e.SeriesName, e.SeriesExecutionTime,
+		userId, repeatModelId,
+	)
+	return
+}
+
+func (s *TodoStore) DeleteException(userId uint64, repeatModelId uint64, date string) (notFound bool, err error) {
+	stmt, err := s.conn().Prepare(
+		`DELETE repeat_exceptions
+			FROM repeat_exceptions JOIN repeat_models ON repeat_exceptions.repeat_model_id = repeat_models.id
+			WHERE repeat_models.user_id = ? AND repeat_models.id = ? AND repeat_exceptions.date = ?`,
+	)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+	result, err := stmt.Exec(userId, repeatModelId, date)
+	if err != nil {
+		return false, err
+	}
+	affectedRowCount, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affectedRowCount == 0 {
+		// Not found
+		return true, nil
+	}
+	return false, nil
+}
+
+func (s *TodoStore) DeleteExceptionsBefore(userId uint64, repeatModelId uint64, date string) (err error) {
+	stmt, err := s.conn().Prepare(
+		`DELETE repeat_exceptions
+			FROM repeat_exceptions JOIN repeat_models ON repeat_exceptions.repeat_model_id = repeat_models.id
+			WHERE repeat_models.user_id = ? AND repeat_models.id = ? AND repeat_exceptions.date < ?`,
+	)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(userId, repeatModelId, date)
+	return
+}
+
+func (s *TodoStore) InsertOperation(userId uint64, op todo.Operation) (err error) {
+	stmt, err := s.conn().Prepare("INSERT INTO todo_operations (user_id, todo_id, kind, data) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(userId, op.TodoId, op.Kind, op.Data)
+	return
+}
+
+func (s *TodoStore) LastOperation(userId uint64, todoId uint64, kind string) (op todo.Operation, notFound bool, err error) {
+	err = s.conn().QueryRow(
+		"SELECT id, todo_id, kind, data FROM todo_operations WHERE user_id = ? AND todo_id = ? AND kind = ? ORDER BY id DESC LIMIT 1",
+		userId, todoId, kind,
+	).Scan(&op.Id, &op.TodoId, &op.Kind, &op.Data)
+	if err == sql.ErrNoRows {
+		// Not found
+		return op, true, nil
+	}
+	return
+}
+
+func (s *TodoStore) DeleteOperation(userId uint64, id uint64) (err error) {
+	_, err = s.conn().Exec("DELETE FROM todo_operations WHERE user_id = ? AND id = ?", userId, id)
+	return
+}
+
+func (s *TodoStore) ListLabels(userId uint64) (labels []todo.Label, err error) {
+	rows, err := s.conn().Query("SELECT id, name, color FROM labels WHERE user_id = ? ORDER BY id", userId)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l := todo.Label{}
+		err = rows.Scan(&l.Id, &l.Name, &l.Color)
+		if err != nil {
+			return
+		}
+		labels = append(labels, l)
+	}
+	err = rows.Err()
+	return
+}
+
+func (s *TodoStore) GetLabel(userId uint64, id uint64) (l todo.Label, notFound bool, err error) {
+	err = s.conn().QueryRow("SELECT id, name, color FROM labels WHERE user_id = ? AND id = ?", userId, id).Scan(&l.Id, &l.Name, &l.Color)
+	if err == sql.ErrNoRows {
+		// Not found
+		return l, true, nil
+	}
+	return
+}
+
+func (s *TodoStore) InsertLabel(userId uint64, l todo.Label) (inserted todo.Label, err error) {
+	result, err := s.conn().Exec("INSERT INTO labels (user_id, name, color) VALUES (?, ?, ?)", userId, l.Name, l.Color)
+	if err != nil {
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+	l.Id = uint64(id)
+	inserted = l
+	return
+}
+
+func (s *TodoStore) UpdateLabel(userId uint64, l todo.Label) (updated todo.Label, notFound bool, err error) {
+	result, err := s.conn().Exec("UPDATE labels SET name = ?, color = ? WHERE user_id = ? AND id = ?", l.Name, l.Color, userId, l.Id)
+	if err != nil {
+		return
+	}
+	notFound, err = s.notFound(result, "SELECT COUNT(*) FROM labels WHERE user_id = ? AND id = ?", userId, l.Id)
+	if err != nil || notFound {
+		return
+	}
+	updated = l
+	return
+}
+
+func (s *TodoStore) DeleteLabel(userId uint64, id uint64) (notFound bool, err error) {
+	// `todo_labels` rows are deleted by the foreign key
+	result, err := s.conn().Exec("DELETE FROM labels WHERE user_id = ? AND id = ?", userId, id)
+	if err != nil {
+		return
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	notFound = affected == 0
+	return
+}
+
+func (s *TodoStore) InsertChecklistItem(userId uint64, todoId uint64, item todo.ChecklistItem) (inserted todo.ChecklistItem, err error) {
+	result, err := s.conn().Exec(
+		`INSERT INTO todo_checklist_items (todo_id, name, checked, position)
+			SELECT todos.id, ?, ?, (SELECT COALESCE(MAX(position) + 1, 0) FROM todo_checklist_items WHERE todo_id = todos.id)
+			FROM todos WHERE todos.user_id = ? AND todos.id = ?`,
+		item.Name, item.Checked, userId, todoId,
+	)
+	if err != nil {
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+	item.Id = uint64(id)
+	inserted = item
+	return
+}
+
+func (s *TodoStore) UpdateChecklistItem(userId uint64, todoId uint64, item todo.ChecklistItem) (updated todo.ChecklistItem, notFound bool, err error) {
+	result, err := s.conn().Exec(
+		`UPDATE todo_checklist_items AS item JOIN todos ON item.todo_id = todos.id
+			SET item.name = ?, item.checked = ?
+			WHERE todos.user_id = ? AND item.todo_id = ? AND item.id = ?`,
+		item.Name, item.Checked, userId, todoId, item.Id,
+	)
+	if err != nil {
+		return
+	}
+	notFound, err = s.notFound(result,
+		"SELECT COUNT(*) FROM todo_checklist_items AS item JOIN todos ON item.todo_id = todos.id WHERE todos.user_id = ? AND item.todo_id = ? AND item.id = ?",
+		userId, todoId, item.Id,
+	)
+	if err != nil || notFound {
+		return
+	}
+	updated = item
+	return
+}
+
+// Whether the row of an UPDATE does not exist.
+// Unchanged rows are not counted as affected, so the row is looked up by query.
+func (s *TodoStore) notFound(result sql.Result, query string, args ...interface{}) (notFound bool, err error) {
+	affected, err := result.RowsAffected()
+	if err != nil || affected != 0 {
+		return
+	}
+	var count int
+	err = s.conn().QueryRow(query, args...).Scan(&count)
+	return count == 0, err
+}
+
+func (s *TodoStore) DeleteChecklistItem(userId uint64, todoId uint64, id uint64) (notFound bool, err error) {
+	result, err := s.conn().Exec(
+		`DELETE item FROM todo_checklist_items AS item JOIN todos ON item.todo_id = todos.id
+			WHERE todos.user_id = ? AND item.todo_id = ? AND item.id = ?`,
+		userId, todoId, id,
+	)
+	if err != nil {
+		return
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	notFound = affected == 0
+	return
+}
+
+func (s *TodoStore) ReorderChecklist(userId uint64, todoId uint64, ids []uint64) (err error) {
+	stmt, err := s.conn().Prepare(
+		`UPDATE todo_checklist_items AS item JOIN todos ON item.todo_id = todos.id
+			SET item.position = ?
+			WHERE todos.user_id = ? AND item.todo_id = ? AND item.id = ?`,
+	)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	for i, id := range ids {
+		_, err = stmt.Exec(i, userId, todoId, id)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (s *TodoStore) ListDependencies(userId uint64) (dependencies []todo.Dependency, err error) {
+	rows, err := s.conn().Query(
+		`SELECT todo_dependencies.todo_id, todo_dependencies.blocker_id
+			FROM todo_dependencies JOIN todos ON todo_dependencies.todo_id = todos.id
+			WHERE todos.user_id = ?`,
+		userId,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d := todo.Dependency{}
+		err = rows.Scan(&d.TodoId, &d.BlockerId)
+		if err != nil {
+			return
+		}
+		dependencies = append(dependencies, d)
+	}
+	err = rows.Err()
+	return
+}
+
+func (s *TodoStore) InsertOutboxEvent(e todo.OutboxEvent) (err error) {
+	_, err = s.conn().Exec("INSERT INTO outbox (user_id, type, data) VALUES (?, ?, ?)", e.UserId, e.Type, string(e.Data))
+	return
+}
+
+func (s *TodoStore) ListOutboxEvents(after uint64, limit int) (events []todo.OutboxEvent, err error) {
+	rows, err := s.conn().Query(
+		`SELECT id, user_id, type, data, attempts, last_error,
+				DATE_FORMAT(next_attempt_at, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(created_at, '%Y-%m-%d %H:%i:%s')
+			FROM outbox WHERE published_at IS NULL AND dead_at IS NULL AND id > ? ORDER BY id LIMIT ?`,
+		after, limit,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := todo.OutboxEvent{}
+		var nextAttemptAt, createdAt *string
+		err = rows.Scan(&e.Id, &e.UserId, &e.Type, &e.Data, &e.Attempts, &e.LastError, &nextAttemptAt, &createdAt)
+		if err != nil {
+			return
+		}
+		e.NextAttemptAt, err = parseTimestamp(nextAttemptAt)
+		if err != nil {
+			return
+		}
+		var created *time.Time
+		created, err = parseTimestamp(createdAt)
+		if err != nil {
+			return
+		}
+		e.CreatedAt = *created
+		events = append(events, e)
+	}
+	err = rows.Err()
+	return
+}
+
+func (s *TodoStore) UpdateOutboxEvent(e todo.OutboxEvent) (err error) {
+	_, err = s.conn().Exec(
+		"UPDATE outbox SET attempts = ?, last_error = ?, next_attempt_at = ?, dead_at = ?, published_at = ? WHERE id = ?",
+		e.Attempts, e.LastError, e.NextAttemptAt, e.DeadAt, e.PublishedAt, e.Id,
+	)
+	return
+}
+
+func (s *TodoStore) OutboxStats() (pending int, dead int, oldest *time.Time, err error) {
+	var createdAt *string
+	err = s.conn().QueryRow(
+		`SELECT COUNT(dead_at IS NULL OR NULL), COUNT(dead_at),
+				DATE_FORMAT(MIN(IF(dead_at IS NULL, created_at, NULL)), '%Y-%m-%d %H:%i:%s')
+			FROM outbox WHERE published_at IS NULL`,
+	).Scan(&pending, &dead, &createdAt)
+	if err != nil {
+		return
+	}
+	oldest, err = parseTimestamp(createdAt)
+	return
+}
+
+func (s *TodoStore) DeletePublishedOutboxEvents(before time.Time) (err error) {
+	_, err = s.conn().Exec("DELETE FROM outbox WHERE published_at < ?", before)
+	return
+}
@@ -0,0 +1,238 @@
+package mysql
+
+import (
+	"database/sql"
+	"flow-todos/webhook"
+	"strings"
+	"time"
+)
+
+// Implements webhook.Store
+type WebhookStore struct {
+	db *sql.DB
+}
+
+func NewWebhookStore(db *sql.DB) *WebhookStore {
+	return &WebhookStore{db: db}
+}
+
+// `*sql.Row` or `*sql.Rows`
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+const selectSubscriptions = `SELECT id, user_id, url, events, secret, active,
+		DATE_FORMAT(created_at, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(updated_at, '%Y-%m-%d %H:%i:%s')
+	FROM webhook_subscriptions`
+
+func scanSubscription(row scanner) (s webhook.Subscription, err error) {
+	var events string
+	var createdAt, updatedAt *string
+	err = row.Scan(&s.Id, &s.UserId, &s.Url, &events, &s.Secret, &s.Active, &createdAt, &updatedAt)
+	if err != nil {
+		return
+	}
+	if events != "" {
+		s.Events = strings.Split(events, ",")
+	}
+	created, err := parseTimestamp(createdAt)
+	if err != nil {
+		return
+	}
+	updated, err := parseTimestamp(updatedAt)
+	if err != nil {
+		return
+	}
+	s.CreatedAt, s.UpdatedAt = *created, *updated
+	return
+}
+
+func (s *WebhookStore) ListSubscriptions(userId uint64) (subscriptions []webhook.Subscription, err error) {
+	rows, err := s.db.Query(selectSubscriptions+" WHERE user_id = ? ORDER BY id", userId)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sub webhook.Subscription
+		if sub, err = scanSubscription(rows); err != nil {
+			return
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+	err = rows.Err()
+	return
+}
+
+func (s *WebhookStore) GetSubscription(userId uint64, id uint64) (sub webhook.Subscription, notFound bool, err error) {
+	sub, err = scanSubscription(s.db.QueryRow(selectSubscriptions+" WHERE user_id = ? AND id = ?", userId, id))
+	if err == sql.ErrNoRows {
+		// Not found
+		return sub, true, nil
+	}
+	return
+}
+
+func (s *WebhookStore) InsertSubscription(sub webhook.Subscription) (inserted webhook.Subscription, err error) {
+	result, err := s.db.Exec(
+		"INSERT INTO webhook_subscriptions (user_id, url, events, secret, active) VALUES (?, ?, ?, ?, ?)",
+		sub.UserId, sub.Url, strings.Join(sub.Events, ","), sub.Secret, sub.Active,
+	)
+	if err != nil {
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+	inserted, _, err = s.GetSubscription(sub.UserId, uint64(id))
+	return
+}
+
+func (s *WebhookStore) UpdateSubscription(sub webhook.Subscription) (updated webhook.Subscription, err error) {
+	_, err = s.db.Exec(
+		"UPDATE webhook_subscriptions SET url = ?, events = ?, secret = ?, active = ? WHERE user_id = ? AND id = ?",
+		sub.Url, strings.Join(sub.Events, ","), sub.Secret, sub.Active, sub.UserId, sub.Id,
+	)
+	if err != nil {
+		return
+	}
+	updated, _, err = s.GetSubscription(sub.UserId, sub.Id)
+	return
+}
+
+func (s *WebhookStore) DeleteSubscription(userId uint64, id uint64) (notFound bool, err error) {
+	// Deliveries are deleted by the foreign key
+	result, err := s.db.Exec("DELETE FROM webhook_subscriptions WHERE user_id = ? AND id = ?", userId, id)
+	if err != nil {
+		return
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	notFound = affected == 0
+	return
+}
+
+const selectDeliveries = `SELECT id, subscription_id, user_id, event_id, event, payload, status, attempts, response_status, error,
+		DATE_FORMAT(next_attempt_at, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(delivered_at, '%Y-%m-%d %H:%i:%s'), redelivery_of,
+		DATE_FORMAT(created_at, '%Y-%m-%d %H:%i:%s')
+	FROM webhook_deliveries`
+
+func scanDelivery(row scanner) (d webhook.Delivery, err error) {
+	var payload []byte
+	var nextAttemptAt, deliveredAt, createdAt *string
+	err = row.Scan(
+		&d.Id, &d.SubscriptionId, &d.UserId, &d.EventId, &d.Event, &payload, &d.Status, &d.Attempts, &d.ResponseStatus, &d.Error,
+		&nextAttemptAt, &deliveredAt, &d.RedeliveryOf, &createdAt,
+	)
+	if err != nil {
+		return
+	}
+	d.Payload = payload
+	if d.NextAttemptAt, err = parseTimestamp(nextAttemptAt); err != nil {
+		return
+	}
+	if d.DeliveredAt, err = parseTimestamp(deliveredAt); err != nil {
+		return
+	}
+	created, err := parseTimestamp(createdAt)
+	if err != nil {
+		return
+	}
+	d.CreatedAt = *created
+	return
+}
+
+func scanDeliveries(rows *sql.Rows) (deliveries []webhook.Delivery, err error) {
+	defer rows.Close()
+	for rows.Next() {
+		var d webhook.Delivery
+		if d, err = scanDelivery(rows); err != nil {
+			return
+		}
+		deliveries = append(deliveries, d)
+	}
+	err = rows.Err()
+	return
+}
+
+func (s *WebhookStore) InsertDelivery(d webhook.Delivery) (inserted webhook.Delivery, err error) {
+	result, err := s.db.Exec(
+		`INSERT INTO webhook_deliveries (subscription_id, user_id, event_id, event, payload, status, attempts, next_attempt_at, redelivery_of)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.SubscriptionId, d.UserId, d.EventId, d.Event, string(d.Payload), d.Status, d.Attempts, d.NextAttemptAt, d.RedeliveryOf,
+	)
+	if err != nil {
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+	inserted, _, err = s.GetDelivery(d.UserId, d.SubscriptionId, uint64(id))
+	return
+}
+
+func (s *WebhookStore) UpdateDelivery(d webhook.Delivery) (err error) {
+	_, err = s.db.Exec(
+		`UPDATE webhook_deliveries SET status = ?, attempts = ?, response_status = ?, error = ?, next_attempt_at = ?, delivered_at = ?
+			WHERE id = ?`,
+		d.Status, d.Attempts, d.ResponseStatus, d.Error, d.NextAttemptAt, d.DeliveredAt, d.Id,
+	)
+	return
+}
+
+func (s *WebhookStore) GetDelivery(userId uint64, subscriptionId uint64, id uint64) (d webhook.Delivery, notFound bool, err error) {
+	d, err = scanDelivery(s.db.QueryRow(selectDeliveries+" WHERE user_id = ? AND subscription_id = ? AND id = ?", userId, subscriptionId, id))
+	if err == sql.ErrNoRows {
+		// Not found
+		return d, true, nil
+	}
+	return
+}
+
+func (s *WebhookStore) ListDeliveries(userId uint64, subscriptionId uint64, before uint64, limit int) (deliveries []webhook.Delivery, err error) {
+	query := selectDeliveries + " WHERE user_id = ? AND subscription_id = ?"
+	args := []interface{}{userId, subscriptionId}
+	if before != 0 {
+		query += " AND id < ?"
+		args = append(args, before)
+	}
+	rows, err := s.db.Query(query+" ORDER BY id DESC LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return
+	}
+	return scanDeliveries(rows)
+}
+
+func (s *WebhookStore) ClaimDeliveries(now time.Time, lease time.Duration, limit int) (deliveries []webhook.Delivery, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	// Rows are locked until the lease is set
+	rows, err := tx.Query(selectDeliveries+" WHERE status = 'pending' AND next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ? FOR UPDATE", now, limit)
+	if err != nil {
+		return
+	}
+	deliveries, err = scanDeliveries(rows)
+	if err != nil || len(deliveries) == 0 {
+		return
+	}
+	args := []interface{}{now.Add(lease)}
+	for _, d := range deliveries {
+		args = append(args, d.Id)
+	}
+	_, err = tx.Exec("UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id IN (?"+strings.Repeat(", ?", len(deliveries)-1)+")", args...)
+	return
+}
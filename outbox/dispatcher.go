@@ -0,0 +1,208 @@
+package outbox
+
+import (
+	"flow-todos/todo"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Upper bound of the backoff between attempts
+const maxBackoff = 5 * time.Minute
+
+// Interval to delete the published events older than the retention
+const cleanupInterval = time.Minute
+
+// Locker runs fn unless another process is dispatching, locked is false then.
+// nil runs fn, for a store used by a single process.
+type Locker func(fn func() error) (locked bool, err error)
+
+type Config struct {
+	// Events read at once
+	BatchSize int
+	// Interval to look for pending events written by other processes and due retries
+	PollInterval time.Duration
+	// Delay before the first retry, doubled on each retry
+	Backoff time.Duration
+	// Attempts before the event is dead and no longer holds the following events of the user, 0 retries forever
+	MaxAttempts uint
+	// Time published events are kept, 0 keeps them forever
+	Retention time.Duration
+}
+
+// Dispatcher publishes the pending events of the outbox to the sinks in the background.
+// A failed event is retried with backoff and holds the following events of the user, so the order is kept.
+// After `MaxAttempts` the event is dead, it is kept unpublished and the following events of the user are published.
+type Dispatcher struct {
+	store  todo.TodoStore
+	sinks  []Sink
+	locker Locker
+	config Config
+	wake   chan struct{}
+	now    func() time.Time
+
+	mu              sync.Mutex
+	published       uint64
+	failures        uint64
+	dead            uint64
+	lastPublishedAt *time.Time
+	lastPublishLag  time.Duration
+	lastCleanup     time.Time
+}
+
+func NewDispatcher(s todo.TodoStore, sinks []Sink, locker Locker, config Config) *Dispatcher {
+	if locker == nil {
+		locker = func(fn func() error) (bool, error) {
+			return true, fn()
+		}
+	}
+	return &Dispatcher{
+		store:  s,
+		sinks:  sinks,
+		locker: locker,
+		config: config,
+		wake:   make(chan struct{}, 1),
+		now:    func() time.Time { return time.Now().UTC().Truncate(time.Second) },
+	}
+}
+
+// Notify wakes the dispatcher up after events are written
+func (d *Dispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run publishes pending events until stop is closed
+func (d *Dispatcher) Run(stop <-chan struct{}, logger Logger) {
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		locked, err := d.locker(func() error {
+			return d.dispatch(logger)
+		})
+		if err != nil {
+			logger.Error(err)
+		}
+		if !locked {
+			logger.Debugf("outbox is dispatched by another process")
+		}
+		select {
+		case <-stop:
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Publishes the due events in the order of the ids
+func (d *Dispatcher) dispatch(logger Logger) (err error) {
+	now := d.now()
+	// Users whose earlier event is not published yet
+	held := map[uint64]bool{}
+	var after uint64
+	for {
+		var events []todo.OutboxEvent
+		events, err = d.store.ListOutboxEvents(after, d.config.BatchSize)
+		if err != nil {
+			return
+		}
+		for _, e := range events {
+			after = e.Id
+			if held[e.UserId] {
+				continue
+			}
+			if e.NextAttemptAt != nil && e.NextAttemptAt.After(now) {
+				held[e.UserId] = true
+				continue
+			}
+			var published, dead bool
+			if published, dead, err = d.publish(e, logger); err != nil {
+				return
+			}
+			if !published && !dead {
+				held[e.UserId] = true
+			}
+		}
+		if len(events) < d.config.BatchSize {
+			break
+		}
+	}
+	return d.cleanup(now)
+}
+
+// Publishes the event to all sinks and records the result
+func (d *Dispatcher) publish(e todo.OutboxEvent, logger Logger) (published bool, dead bool, err error) {
+	var failure error
+	for _, sink := range d.sinks {
+		if failure = sink.Publish(e); failure != nil {
+			failure = fmt.Errorf("%s: %w", sink.Name(), failure)
+			break
+		}
+	}
+	now := d.now()
+	e.Attempts++
+	if failure == nil {
+		e.LastError = nil
+		e.NextAttemptAt = nil
+		e.PublishedAt = &now
+	} else {
+		message := failure.Error()
+		e.LastError = &message
+		if d.config.MaxAttempts != 0 && e.Attempts >= d.config.MaxAttempts {
+			e.NextAttemptAt = nil
+			e.DeadAt = &now
+		} else {
+			next := now.Add(d.backoff(e.Attempts))
+			e.NextAttemptAt = &next
+		}
+	}
+	// Published again after a restart if the result is not saved
+	if err = d.store.UpdateOutboxEvent(e); err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if failure != nil {
+		d.failures++
+		if e.DeadAt != nil {
+			d.dead++
+			logger.Error(fmt.Errorf("outbox event %d of `%s` is dead after %d attempts: %w", e.Id, e.Type, e.Attempts, failure))
+			return false, true, nil
+		}
+		logger.Debugf("outbox event %d of `%s` failed at attempt %d: %s", e.Id, e.Type, e.Attempts, failure)
+		return
+	}
+	d.published++
+	d.lastPublishedAt = &now
+	d.lastPublishLag = now.Sub(e.CreatedAt)
+	logger.Debugf("outbox event %d of `%s` published", e.Id, e.Type)
+	return true, false, nil
+}
+
+// Delay before the retry after the attempt
+func (d *Dispatcher) backoff(attempts uint) time.Duration {
+	backoff := d.config.Backoff
+	for i := uint(1); i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+// Deletes the events published before the retention
+func (d *Dispatcher) cleanup(now time.Time) (err error) {
+	if d.config.Retention == 0 || now.Sub(d.lastCleanup) < cleanupInterval {
+		return
+	}
+	if err = d.store.DeletePublishedOutboxEvents(now.Add(-d.config.Retention)); err != nil {
+		return
+	}
+	d.lastCleanup = now
+	return
+}
@@ -0,0 +1,82 @@
+package outbox_test
+
+import (
+	"errors"
+	"flow-todos/memory"
+	"flow-todos/outbox"
+	"flow-todos/todo"
+	"testing"
+	"time"
+)
+
+func TestDispatcherDeadEvent(t *testing.T) {
+	s := memory.NewTodoStore()
+	for _, typ := range []string{"todo.created", "todo.updated"} {
+		if err := s.InsertOutboxEvent(todo.OutboxEvent{UserId: 1, Type: typ, Data: []byte(`{}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink := &recordingSink{fail: "todo.created"}
+	d := outbox.NewDispatcher(s, []outbox.Sink{sink}, nil, outbox.Config{BatchSize: 10, PollInterval: time.Hour, MaxAttempts: 2})
+	logger := &recordingLogger{}
+	dispatch := func() {
+		stop := make(chan struct{})
+		close(stop)
+		d.Run(stop, logger)
+	}
+
+	// The failing event holds the following event of the user
+	dispatch()
+	if len(sink.published) != 0 || len(logger.errors) != 0 {
+		t.Errorf("published %v, errors %v after the first attempt", sink.published, logger.errors)
+	}
+	// The dead event no longer holds it
+	dispatch()
+	if len(sink.published) != 1 || sink.published[0] != "todo.updated" || len(logger.errors) != 1 {
+		t.Errorf("published %v, errors %v after the last attempt", sink.published, logger.errors)
+	}
+	// The dead event is not retried
+	sink.attempts = 0
+	dispatch()
+	if sink.attempts != 0 {
+		t.Errorf("dead event is retried")
+	}
+
+	stats, err := d.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Pending != 0 || stats.Dead != 1 || stats.DeadLettered != 1 || stats.Failures != 2 || stats.Published != 1 {
+		t.Errorf("Stats returned %+v", stats)
+	}
+}
+
+// Fails the events of a type
+type recordingSink struct {
+	fail      string
+	attempts  int
+	published []string
+}
+
+func (s *recordingSink) Name() string {
+	return "recording"
+}
+
+func (s *recordingSink) Publish(e todo.OutboxEvent) error {
+	s.attempts++
+	if e.Type == s.fail {
+		return errors.New("unavailable")
+	}
+	s.published = append(s.published, e.Type)
+	return nil
+}
+
+type recordingLogger struct {
+	errors []interface{}
+}
+
+func (l *recordingLogger) Error(i ...interface{}) {
+	l.errors = append(l.errors, i...)
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {}
@@ -0,0 +1,41 @@
+package outbox
+
+import (
+	"encoding/json"
+	"flow-todos/todo"
+	"os"
+	"sync"
+)
+
+// FileSink appends the events to a file as JSON lines, for development and tests
+type FileSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+func (s *FileSink) Name() string {
+	return "file"
+}
+
+func (s *FileSink) Publish(e todo.OutboxEvent) (err error) {
+	line, err := json.Marshal(NewMessage(e))
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
+}
@@ -0,0 +1,183 @@
+package outbox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flow-todos/todo"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeout of the connection and of a publication
+const natsTimeout = 10 * time.Second
+
+// NatsSink publishes the events to the subjects `<prefix>.<type>` of a NATS server,
+// with the id of the event in the header `Nats-Msg-Id` so JetStream streams drop duplicates.
+// A publication succeeds when the server answers the following `PING`.
+// A publication on a connection closed by the server is sent again once on a new connection.
+type NatsSink struct {
+	mu     sync.Mutex
+	url    *url.URL
+	prefix string
+	conn   net.Conn
+	reader *bufio.Reader
+	// Server supports `HPUB`
+	headers bool
+}
+
+// NewNatsSink connects lazily to the url `nats://[user:password@]host[:port]`
+func NewNatsSink(rawUrl string, prefix string) (s *NatsSink, err error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return
+	}
+	if u.Scheme != "nats" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid nats url `%s`", rawUrl)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	return &NatsSink{url: u, prefix: prefix}, nil
+}
+
+func (s *NatsSink) Name() string {
+	return "nats"
+}
+
+func (s *NatsSink) Publish(e todo.OutboxEvent) (err error) {
+	payload, err := json.Marshal(NewMessage(e))
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reused := s.conn != nil
+	err = s.publish(e.Type, e.Id, payload)
+	if err != nil && reused {
+		// The server may have closed the idle connection, the id dedupes the message if it was received
+		err = s.publish(e.Type, e.Id, payload)
+	}
+	return
+}
+
+// Publishes the message followed by a `PING`, connecting if needed
+func (s *NatsSink) publish(eventType string, id uint64, payload []byte) (err error) {
+	if err = s.connect(); err != nil {
+		return
+	}
+	subject := s.prefix + "." + eventType
+	var b bytes.Buffer
+	if s.headers {
+		header := "NATS/1.0\r\nNats-Msg-Id: " + strconv.FormatUint(id, 10) + "\r\n\r\n"
+		fmt.Fprintf(&b, "HPUB %s %d %d\r\n%s%s\r\n", subject, len(header), len(header)+len(payload), header, payload)
+	} else {
+		fmt.Fprintf(&b, "PUB %s %d\r\n%s\r\n", subject, len(payload), payload)
+	}
+	b.WriteString("PING\r\n")
+	return s.send(b.Bytes())
+}
+
+// Writes the commands and waits for the `PONG`, the connection is closed on failure
+func (s *NatsSink) send(commands []byte) (err error) {
+	defer func() {
+		if err != nil {
+			s.conn.Close()
+			s.conn = nil
+		}
+	}()
+	s.conn.SetDeadline(time.Now().Add(natsTimeout))
+	if _, err = s.conn.Write(commands); err != nil {
+		return
+	}
+	return s.awaitPong()
+}
+
+// Reads the `INFO` of the server and sends `CONNECT`, unless connected
+func (s *NatsSink) connect() (err error) {
+	if s.conn != nil {
+		return
+	}
+	conn, err := net.DialTimeout("tcp", s.url.Host, natsTimeout)
+	if err != nil {
+		return
+	}
+	s.conn, s.reader = conn, bufio.NewReader(conn)
+	defer func() {
+		if err != nil {
+			conn.Close()
+			s.conn = nil
+		}
+	}()
+	conn.SetDeadline(time.Now().Add(natsTimeout))
+
+	line, err := s.reader.ReadString('\n')
+	if err != nil {
+		return
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected greeting of nats server `%s`", strings.TrimSpace(line))
+	}
+	var info struct {
+		Headers bool `json:"headers"`
+	}
+	if err = json.Unmarshal([]byte(strings.TrimSpace(line[len("INFO "):])), &info); err != nil {
+		return
+	}
+	s.headers = info.Headers
+
+	options := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "flow-todos",
+		"lang":     "go",
+		"version":  "1.0.0",
+		"protocol": 1,
+		"headers":  info.Headers,
+	}
+	if user := s.url.User; user != nil {
+		if password, ok := user.Password(); ok {
+			options["user"], options["pass"] = user.Username(), password
+		} else {
+			options["auth_token"] = user.Username()
+		}
+	}
+	body, err := json.Marshal(options)
+	if err != nil {
+		return
+	}
+	if _, err = fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", body); err != nil {
+		return
+	}
+	return s.awaitPong()
+}
+
+// Reads until `PONG`, the server handled the commands sent before the `PING`
+func (s *NatsSink) awaitPong() (err error) {
+	for {
+		var line string
+		line, err = s.reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return
+		case line == "PING":
+			if _, err = s.conn.Write([]byte("PONG\r\n")); err != nil {
+				return
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("nats: " + strings.Trim(strings.TrimPrefix(line, "-ERR"), " '"))
+		}
+		// `+OK` and `INFO` updates are ignored
+	}
+}
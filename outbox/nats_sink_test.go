@@ -0,0 +1,199 @@
+package outbox_test
+
+import (
+	"bufio"
+	"flow-todos/outbox"
+	"flow-todos/todo"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Fake NATS server serving each connection with the next handler
+func natsServer(t *testing.T, handlers ...func(c *natsConn) error) (url string, errs chan error) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	errs = make(chan error, len(handlers))
+	go func() {
+		for _, handle := range handlers {
+			conn, err := l.Accept()
+			if err != nil {
+				errs <- err
+				return
+			}
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			c := &natsConn{conn, bufio.NewReader(conn)}
+			errs <- handle(c)
+			conn.Close()
+		}
+	}()
+	return "nats://" + l.Addr().String(), errs
+}
+
+// Result of the next connection of the fake server
+func served(t *testing.T, errs chan error) {
+	t.Helper()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("server: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("server: no connection")
+	}
+}
+
+type natsConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *natsConn) expect(prefix string) (line string, err error) {
+	line, err = c.reader.ReadString('\n')
+	if err != nil {
+		return
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if !strings.HasPrefix(line, prefix) {
+		err = fmt.Errorf("read `%s`, want `%s`", line, prefix)
+	}
+	return
+}
+
+func (c *natsConn) send(line string) (err error) {
+	_, err = c.Write([]byte(line + "\r\n"))
+	return
+}
+
+// Sends `INFO` and answers `CONNECT` and `PING`
+func (c *natsConn) handshake(info string) (connect string, err error) {
+	if err = c.send("INFO " + info); err != nil {
+		return
+	}
+	if connect, err = c.expect("CONNECT "); err != nil {
+		return
+	}
+	if _, err = c.expect("PING"); err != nil {
+		return
+	}
+	err = c.send("PONG")
+	return
+}
+
+// Reads a `HPUB` and the following `PING`, returns the subject, the header and the payload
+func (c *natsConn) hpub() (subject string, header string, payload string, err error) {
+	line, err := c.expect("HPUB ")
+	if err != nil {
+		return
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 4 {
+		return "", "", "", fmt.Errorf("invalid `%s`", line)
+	}
+	headerSize, _ := strconv.Atoi(fields[2])
+	totalSize, _ := strconv.Atoi(fields[3])
+	body := make([]byte, totalSize+2)
+	if _, err = io.ReadFull(c.reader, body); err != nil {
+		return
+	}
+	if _, err = c.expect("PING"); err != nil {
+		return
+	}
+	return fields[1], string(body[:headerSize]), string(body[headerSize:totalSize]), nil
+}
+
+func TestNatsSinkPublish(t *testing.T) {
+	url, errs := natsServer(t, func(c *natsConn) (err error) {
+		connect, err := c.handshake(`{"headers":true}`)
+		if err != nil {
+			return
+		}
+		if !strings.Contains(connect, `"user":"u"`) || !strings.Contains(connect, `"pass":"p"`) || !strings.Contains(connect, `"headers":true`) {
+			return fmt.Errorf("invalid `%s`", connect)
+		}
+		subject, header, payload, err := c.hpub()
+		if err != nil {
+			return
+		}
+		if subject != "flow-todos.todo.created" || !strings.Contains(header, "Nats-Msg-Id: 7\r\n") || !strings.Contains(payload, `"id":"7"`) {
+			return fmt.Errorf("invalid message `%s` `%s` `%s`", subject, header, payload)
+		}
+		// The server pings before answering the ping of the publication
+		if err = c.send("PING"); err != nil {
+			return
+		}
+		if _, err = c.expect("PONG"); err != nil {
+			return
+		}
+		return c.send("PONG")
+	})
+	s, err := outbox.NewNatsSink(strings.Replace(url, "nats://", "nats://u:p@", 1), "flow-todos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Publish(todo.OutboxEvent{Id: 7, UserId: 1, Type: "todo.created", Data: []byte(`{}`)}); err != nil {
+		t.Errorf("Publish: %v", err)
+	}
+	served(t, errs)
+}
+
+func TestNatsSinkReconnect(t *testing.T) {
+	publish := func(c *natsConn) (err error) {
+		if _, err = c.handshake(`{"headers":true}`); err != nil {
+			return
+		}
+		if _, _, _, err = c.hpub(); err != nil {
+			return
+		}
+		return c.send("PONG")
+	}
+	// The first connection is closed by the server after a publication
+	url, errs := natsServer(t, publish, publish)
+	s, err := outbox.NewNatsSink(url, "flow-todos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id := uint64(1); id <= 2; id++ {
+		if err = s.Publish(todo.OutboxEvent{Id: id, UserId: 1, Type: "todo.updated", Data: []byte(`{}`)}); err != nil {
+			t.Errorf("Publish %d: %v", id, err)
+		}
+		served(t, errs)
+	}
+}
+
+func TestNatsSinkError(t *testing.T) {
+	url, errs := natsServer(t, func(c *natsConn) (err error) {
+		if _, err = c.handshake(`{}`); err != nil {
+			return
+		}
+		line, err := c.expect("PUB flow-todos.todo.deleted ")
+		if err != nil {
+			return
+		}
+		size, _ := strconv.Atoi(strings.Fields(line)[2])
+		if _, err = io.ReadFull(c.reader, make([]byte, size+2)); err != nil {
+			return
+		}
+		if _, err = c.expect("PING"); err != nil {
+			return
+		}
+		return c.send("-ERR 'Permissions Violation for Publish to flow-todos.todo.deleted'")
+	})
+	s, err := outbox.NewNatsSink(url, "flow-todos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Publish(todo.OutboxEvent{Id: 3, UserId: 1, Type: "todo.deleted", Data: []byte(`{}`)})
+	if err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Errorf("Publish returned %v, want the error of the server", err)
+	}
+	served(t, errs)
+}
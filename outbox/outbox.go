@@ -0,0 +1,44 @@
+// Package outbox publishes the events written to the outbox in the transactions of the changes of todos.
+// Events are published at least once, in order for each user, to all sinks.
+package outbox
+
+import (
+	"encoding/json"
+	"flow-todos/todo"
+	"strconv"
+	"time"
+)
+
+// Sink receives the published events.
+// An event is published again to all sinks if any sink fails, so consumers dedupe by the id.
+type Sink interface {
+	Name() string
+	Publish(e todo.OutboxEvent) error
+}
+
+// Logger is satisfied by `echo.Logger`
+type Logger interface {
+	Error(i ...interface{})
+	Debugf(format string, args ...interface{})
+}
+
+// Message is the JSON of the events published to NATS and files
+type Message struct {
+	// Id of the outbox event, increasing in the order of the changes
+	Id        string    `json:"id"`
+	Type      string    `json:"type"`
+	UserId    uint64    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	// `todo.EventData`
+	Data json.RawMessage `json:"data"`
+}
+
+func NewMessage(e todo.OutboxEvent) Message {
+	return Message{
+		Id:        strconv.FormatUint(e.Id, 10),
+		Type:      e.Type,
+		UserId:    e.UserId,
+		CreatedAt: e.CreatedAt,
+		Data:      e.Data,
+	}
+}
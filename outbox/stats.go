@@ -0,0 +1,73 @@
+package outbox
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+type Stats struct {
+	Sinks []string `json:"sinks"`
+	// Events not published yet, dead events excluded
+	Pending int `json:"pending"`
+	// Events given up after the maximum attempts
+	Dead int `json:"dead"`
+	// Age of the oldest pending event
+	LagSeconds float64 `json:"lag_seconds"`
+	// Time from the change to the publication of the last published event
+	LastPublishLagSeconds float64 `json:"last_publish_lag_seconds"`
+	// Counts since the start of the process
+	Published       uint64     `json:"published"`
+	Failures        uint64     `json:"failures"`
+	DeadLettered    uint64     `json:"dead_lettered"`
+	LastPublishedAt *time.Time `json:"last_published_at"`
+}
+
+func (d *Dispatcher) Stats() (s Stats, err error) {
+	pending, dead, oldest, err := d.store.OutboxStats()
+	if err != nil {
+		return
+	}
+	s.Pending = pending
+	s.Dead = dead
+	if oldest != nil {
+		s.LagSeconds = d.now().Sub(*oldest).Seconds()
+	}
+	for _, sink := range d.sinks {
+		s.Sinks = append(s.Sinks, sink.Name())
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s.LastPublishLagSeconds = d.lastPublishLag.Seconds()
+	s.Published = d.published
+	s.Failures = d.failures
+	s.DeadLettered = d.dead
+	s.LastPublishedAt = d.lastPublishedAt
+	return
+}
+
+// WriteMetrics writes the stats in the Prometheus text format
+func (s Stats) WriteMetrics(w io.Writer) (err error) {
+	metrics := []struct {
+		name  string
+		kind  string
+		help  string
+		value float64
+	}{
+		{"flow_todos_outbox_pending_events", "gauge", "Events not published yet, dead events excluded.", float64(s.Pending)},
+		{"flow_todos_outbox_dead_events", "gauge", "Events given up after the maximum attempts.", float64(s.Dead)},
+		{"flow_todos_outbox_lag_seconds", "gauge", "Age of the oldest pending event.", s.LagSeconds},
+		{"flow_todos_outbox_last_publish_lag_seconds", "gauge", "Time from the change to the publication of the last published event.", s.LastPublishLagSeconds},
+		{"flow_todos_outbox_published_total", "counter", "Events published by this process.", float64(s.Published)},
+		{"flow_todos_outbox_failures_total", "counter", "Failed attempts to publish by this process.", float64(s.Failures)},
+		{"flow_todos_outbox_dead_lettered_total", "counter", "Events given up by this process.", float64(s.DeadLettered)},
+	}
+	for _, m := range metrics {
+		_, err = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", m.name, m.help, m.name, m.kind, m.name, m.value)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,30 @@
+package outbox
+
+import (
+	"flow-todos/todo"
+	"flow-todos/webhook"
+	"strconv"
+)
+
+// WebhookSink queues the deliveries of the events to the webhook subscriptions
+type WebhookSink struct {
+	dispatcher *webhook.Dispatcher
+}
+
+func NewWebhookSink(d *webhook.Dispatcher) *WebhookSink {
+	return &WebhookSink{dispatcher: d}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Publish(e todo.OutboxEvent) error {
+	return s.dispatcher.Emit(webhook.Event{
+		Id:        strconv.FormatUint(e.Id, 10),
+		Type:      e.Type,
+		UserId:    e.UserId,
+		CreatedAt: e.CreatedAt,
+		Data:      e.Data,
+	})
+}
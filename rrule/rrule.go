@@ -0,0 +1,467 @@
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date based subset of RFC 5545 recurrence rules.
+// Occurrences are dates (UTC midnight), BYHOUR, BYMINUTE, BYSECOND and BYWEEKNO are not supported.
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+type Weekday struct {
+	// 0: every, 1: first, -1: last, ...
+	N   int
+	Day time.Weekday
+}
+
+type RRule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []Weekday
+	ByMonthDay []int
+	ByYearDay  []int
+	ByMonth    []int
+	BySetPos   []int
+	Wkst       time.Weekday
+}
+
+// Upper bound of COUNT, occurrences are iterated to find the end of the rule
+const MaxCount = 1000
+
+var weekdays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Date returns t as a date (UTC midnight)
+func Date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	for i, w := range weekdays {
+		if w == s {
+			return time.Weekday(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday `%s`", s)
+}
+
+func parseInts(s string, min int, max int, allowNegative bool) (values []int, err error) {
+	for _, v := range strings.Split(s, ",") {
+		var n int
+		n, err = strconv.Atoi(v)
+		if err != nil {
+			return
+		}
+		abs := n
+		if n < 0 {
+			if !allowNegative {
+				err = fmt.Errorf("`%d` must be positive", n)
+				return
+			}
+			abs = -n
+		}
+		if abs < min || abs > max {
+			err = fmt.Errorf("`%d` out of range", n)
+			return
+		}
+		values = append(values, n)
+	}
+	return
+}
+
+func parseUntil(s string) (until time.Time, err error) {
+	for _, layout := range []string{"20060102", "20060102T150405Z", "20060102T150405"} {
+		until, err = time.Parse(layout, s)
+		if err == nil {
+			until = Date(until)
+			return
+		}
+	}
+	err = fmt.Errorf("invalid UNTIL `%s`", s)
+	return
+}
+
+// Parse `FREQ=MONTHLY;BYDAY=2TU` (`RRULE:` prefix is optional)
+func Parse(s string) (r RRule, err error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	r.Interval = 1
+	r.Wkst = time.Monday
+
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			err = fmt.Errorf("invalid rule part `%s`", part)
+			return
+		}
+		key, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		switch key {
+		case "FREQ":
+			switch Frequency(value) {
+			case Daily, Weekly, Monthly, Yearly:
+				r.Freq = Frequency(value)
+			default:
+				err = fmt.Errorf("unsupported FREQ `%s`", value)
+				return
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err != nil || r.Interval < 1 {
+				err = fmt.Errorf("invalid INTERVAL `%s`", value)
+				return
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err != nil || r.Count < 1 || r.Count > MaxCount {
+				err = fmt.Errorf("invalid COUNT `%s`", value)
+				return
+			}
+		case "UNTIL":
+			var until time.Time
+			until, err = parseUntil(value)
+			if err != nil {
+				return
+			}
+			r.Until = &until
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				if len(v) < 2 {
+					err = fmt.Errorf("invalid BYDAY `%s`", v)
+					return
+				}
+				wd := Weekday{}
+				wd.Day, err = parseWeekday(v[len(v)-2:])
+				if err != nil {
+					return
+				}
+				if n := v[:len(v)-2]; n != "" {
+					wd.N, err = strconv.Atoi(n)
+					if err != nil || wd.N == 0 || wd.N < -53 || wd.N > 53 {
+						err = fmt.Errorf("invalid BYDAY `%s`", v)
+						return
+					}
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseInts(value, 1, 31, true)
+			if err != nil {
+				err = fmt.Errorf("invalid BYMONTHDAY: %w", err)
+				return
+			}
+		case "BYYEARDAY":
+			r.ByYearDay, err = parseInts(value, 1, 366, true)
+			if err != nil {
+				err = fmt.Errorf("invalid BYYEARDAY: %w", err)
+				return
+			}
+		case "BYMONTH":
+			r.ByMonth, err = parseInts(value, 1, 12, false)
+			if err != nil {
+				err = fmt.Errorf("invalid BYMONTH: %w", err)
+				return
+			}
+		case "BYSETPOS":
+			r.BySetPos, err = parseInts(value, 1, 366, true)
+			if err != nil {
+				err = fmt.Errorf("invalid BYSETPOS: %w", err)
+				return
+			}
+		case "WKST":
+			r.Wkst, err = parseWeekday(value)
+			if err != nil {
+				return
+			}
+		default:
+			err = fmt.Errorf("unsupported rule part `%s`", key)
+			return
+		}
+	}
+
+	if r.Freq == "" {
+		err = errors.New("FREQ required")
+		return
+	}
+	if r.Count != 0 && r.Until != nil {
+		err = errors.New("COUNT and UNTIL must not occur in the same rule")
+		return
+	}
+	if r.Freq != Monthly && r.Freq != Yearly {
+		for _, wd := range r.ByDay {
+			if wd.N != 0 {
+				err = fmt.Errorf("BYDAY with ordinal requires FREQ=MONTHLY or FREQ=YEARLY")
+				return
+			}
+		}
+	}
+	return
+}
+
+func joinInts(values []int) string {
+	var s []string
+	for _, v := range values {
+		s = append(s, strconv.Itoa(v))
+	}
+	return strings.Join(s, ",")
+}
+
+// String formats the rule without `RRULE:` prefix
+func (r RRule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count != 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	if len(r.ByMonth) != 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+	if len(r.ByMonthDay) != 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByYearDay) != 0 {
+		parts = append(parts, "BYYEARDAY="+joinInts(r.ByYearDay))
+	}
+	if len(r.ByDay) != 0 {
+		var days []string
+		for _, wd := range r.ByDay {
+			day := weekdays[wd.Day]
+			if wd.N != 0 {
+				day = strconv.Itoa(wd.N) + day
+			}
+			days = append(days, day)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.BySetPos) != 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	if r.Wkst != time.Monday {
+		parts = append(parts, "WKST="+weekdays[r.Wkst])
+	}
+	return strings.Join(parts, ";")
+}
+
+// Number of consecutive periods without occurrence before an iterator gives up
+const maxEmptyPeriods = 1000
+
+type Iterator struct {
+	r       RRule
+	dtstart time.Time
+	period  int
+	empty   int
+	buf     []time.Time
+	emitted int
+	done    bool
+}
+
+// Iterator returns occurrences of the rule from dtstart in ascending order
+func (r RRule) Iterator(dtstart time.Time) *Iterator {
+	dtstart = Date(dtstart)
+	if r.Interval < 1 {
+		r.Interval = 1
+	}
+
+	// Values not specified are taken from dtstart
+	noByDays := len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && len(r.ByYearDay) == 0
+	switch r.Freq {
+	case Yearly:
+		if noByDays && len(r.ByMonth) == 0 {
+			r.ByMonth = []int{int(dtstart.Month())}
+		}
+		if noByDays {
+			r.ByMonthDay = []int{dtstart.Day()}
+		}
+	case Monthly:
+		if noByDays {
+			r.ByMonthDay = []int{dtstart.Day()}
+		}
+	case Weekly:
+		if noByDays {
+			r.ByDay = []Weekday{{Day: dtstart.Weekday()}}
+		}
+	}
+
+	return &Iterator{r: r, dtstart: dtstart}
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func daysInYear(year int) int {
+	return time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
+
+// Range of dates [start, end) of the n-th period
+func (it *Iterator) periodRange(n int) (start time.Time, end time.Time) {
+	d := it.dtstart
+	step := n * it.r.Interval
+	switch it.r.Freq {
+	case Daily:
+		start = d.AddDate(0, 0, step)
+		end = start.AddDate(0, 0, 1)
+	case Weekly:
+		weekStart := d.AddDate(0, 0, -((int(d.Weekday()) - int(it.r.Wkst) + 7) % 7))
+		start = weekStart.AddDate(0, 0, 7*step)
+		end = start.AddDate(0, 0, 7)
+	case Monthly:
+		start = time.Date(d.Year(), d.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(0, 1, 0)
+	case Yearly:
+		start = time.Date(d.Year()+step, 1, 1, 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(1, 0, 0)
+	}
+	return
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (it *Iterator) match(d time.Time) bool {
+	r := it.r
+	if len(r.ByMonth) != 0 && !containsInt(r.ByMonth, int(d.Month())) {
+		return false
+	}
+	dim := daysIn(d.Year(), d.Month())
+	if len(r.ByMonthDay) != 0 && !containsInt(r.ByMonthDay, d.Day()) && !containsInt(r.ByMonthDay, d.Day()-dim-1) {
+		return false
+	}
+	diy := daysInYear(d.Year())
+	if len(r.ByYearDay) != 0 && !containsInt(r.ByYearDay, d.YearDay()) && !containsInt(r.ByYearDay, d.YearDay()-diy-1) {
+		return false
+	}
+	if len(r.ByDay) != 0 {
+		matched := false
+		for _, wd := range r.ByDay {
+			if wd.Day != d.Weekday() {
+				continue
+			}
+			if wd.N == 0 {
+				matched = true
+				break
+			}
+			// Ordinal in the month or the year
+			var nth, nthLast int
+			if r.Freq == Monthly || len(r.ByMonth) != 0 {
+				nth = (d.Day()-1)/7 + 1
+				nthLast = -((dim-d.Day())/7 + 1)
+			} else {
+				nth = (d.YearDay()-1)/7 + 1
+				nthLast = -((diy-d.YearDay())/7 + 1)
+			}
+			if wd.N == nth || wd.N == nthLast {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// Occurrences in the n-th period, not filtered by dtstart
+func (it *Iterator) expand(n int) (dates []time.Time) {
+	start, end := it.periodRange(n)
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		if it.match(d) {
+			dates = append(dates, d)
+		}
+	}
+
+	if len(it.r.BySetPos) == 0 || len(dates) == 0 {
+		return
+	}
+	var selected []time.Time
+	for i, d := range dates {
+		if containsInt(it.r.BySetPos, i+1) || containsInt(it.r.BySetPos, i-len(dates)) {
+			selected = append(selected, d)
+		}
+	}
+	return selected
+}
+
+func (it *Iterator) Next() (t time.Time, ok bool) {
+	for !it.done && len(it.buf) == 0 {
+		if it.empty >= maxEmptyPeriods {
+			it.done = true
+			break
+		}
+		for _, d := range it.expand(it.period) {
+			if !d.Before(it.dtstart) {
+				it.buf = append(it.buf, d)
+			}
+		}
+		sort.Slice(it.buf, func(i, j int) bool { return it.buf[i].Before(it.buf[j]) })
+		if len(it.buf) == 0 {
+			it.empty++
+		} else {
+			it.empty = 0
+		}
+		it.period++
+	}
+	if it.done {
+		return
+	}
+
+	t = it.buf[0]
+	it.buf = it.buf[1:]
+	if it.r.Until != nil && t.After(*it.r.Until) || it.r.Count != 0 && it.emitted >= it.r.Count {
+		it.done = true
+		return time.Time{}, false
+	}
+	it.emitted++
+	return t, true
+}
+
+// After returns the first occurrence after t
+func (r RRule) After(dtstart time.Time, t time.Time) (next time.Time, ok bool) {
+	t = Date(t)
+	it := r.Iterator(dtstart)
+	for {
+		next, ok = it.Next()
+		if !ok || next.After(t) {
+			return
+		}
+	}
+}
+
+// Between returns the occurrences in [from, to]
+func (r RRule) Between(dtstart time.Time, from time.Time, to time.Time) (dates []time.Time) {
+	from, to = Date(from), Date(to)
+	it := r.Iterator(dtstart)
+	for {
+		d, ok := it.Next()
+		if !ok || d.After(to) {
+			return
+		}
+		if !d.Before(from) {
+			dates = append(dates, d)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package todo
+
+import "errors"
+
+// Operations of `POST /bulk`
+const (
+	BulkPatch    = "patch"
+	BulkComplete = "complete"
+	BulkSkip     = "skip"
+	BulkDelete   = "delete"
+)
+
+// Modes of `POST /bulk`
+const (
+	// All operations are applied or none
+	BulkModeAtomic = "atomic"
+	// Each operation is applied unless it fails
+	BulkModePartial = "partial"
+)
+
+type BulkBody struct {
+	Mode       string          `json:"mode" validate:"omitempty,oneof=atomic partial"`
+	Operations []BulkOperation `json:"operations" validate:"required,min=1,max=100,dive"`
+}
+
+type BulkOperation struct {
+	Op string `json:"op" validate:"required,oneof=patch complete skip delete"`
+	Id uint64 `json:"id" validate:"required,gte=1"`
+	// Body of `patch`
+	Patch *PatchBody `json:"patch" validate:"omitempty"`
+	// Scope of `patch`, `all` by default
+	Scope string `json:"scope" validate:"omitempty,oneof=this this_and_following all"`
+	// `force` of `complete`
+	Force bool `json:"force" validate:"omitempty"`
+}
+
+// Result of an operation of `POST /bulk` with the status of the equivalent single request
+type BulkResult struct {
+	Op      string `json:"op"`
+	Id      uint64 `json:"id"`
+	Status  int    `json:"status"`
+	Message string `json:"message,omitempty"`
+	// Updated todo, and the successor of a completed repeating todo
+	Todos     []Todo   `json:"todos,omitempty"`
+	BlockedBy []uint64 `json:"blocked_by,omitempty"`
+}
+
+// ProjectIds returns the distinct project ids set by the patches
+func (b *BulkBody) ProjectIds() (ids []uint64) {
+	for _, o := range b.Operations {
+		if o.Patch != nil && o.Patch.ProjectId.UInt64 != nil && *o.Patch.ProjectId.UInt64 != nil && !containsId(ids, **o.Patch.ProjectId.UInt64) {
+			ids = append(ids, **o.Patch.ProjectId.UInt64)
+		}
+	}
+	return
+}
+
+// SprintIds returns the distinct sprint ids set by the patches
+func (b *BulkBody) SprintIds() (ids []uint64) {
+	for _, o := range b.Operations {
+		if o.Patch != nil && o.Patch.SprintId.UInt64 != nil && *o.Patch.SprintId.UInt64 != nil && !containsId(ids, **o.Patch.SprintId.UInt64) {
+			ids = append(ids, **o.Patch.SprintId.UInt64)
+		}
+	}
+	return
+}
+
+// Discards the changes of a failed operation
+var errBulkFailed = errors.New("bulk operation failed")
+
+// Bulk runs apply for each operation in a transaction.
+// apply reports a failed operation with a result status of 400 or more.
+// In atomic mode the first failure discards all changes and stops,
+// in partial mode only the changes of the failed operation are discarded.
+func Bulk(s TodoStore, body BulkBody, apply func(s TodoStore, o BulkOperation) (r BulkResult, err error)) (results []BulkResult, failed *int, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		results = nil
+		for i, o := range body.Operations {
+			var r BulkResult
+			err = s.WithTx(func(s TodoStore) (err error) {
+				r, err = apply(s, o)
+				if err == nil && r.Status >= 400 {
+					err = errBulkFailed
+				}
+				return
+			})
+			if err != nil && err != errBulkFailed {
+				return
+			}
+			r.Op, r.Id = o.Op, o.Id
+			results = append(results, r)
+			if err == errBulkFailed && body.Mode != BulkModePartial {
+				failed = &i
+				return
+			}
+		}
+		return nil
+	})
+	if err == errBulkFailed {
+		err = nil
+	}
+	return
+}
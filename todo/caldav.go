@@ -0,0 +1,466 @@
+package todo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Preconditions of RFC 4791 violated by a PUT
+const (
+	CalDAVValidData          = "valid-calendar-data"
+	CalDAVSupportedComponent = "supported-calendar-component"
+	CalDAVNoUidConflict      = "no-uid-conflict"
+)
+
+// Discards the changes of a failed PUT
+var errCalDAVFailed = errors.New("caldav failed")
+
+// Resource of the CalDAV collection named by the client, like `<uuid>.ics`.
+// Other todos are served as `<id>.ics` with the UID of `ICalendar`.
+type CalDAVResource struct {
+	Name   string
+	TodoId uint64
+	Uid    string
+}
+
+// Calendar object of a todo in the CalDAV collection
+type CalDAVObject struct {
+	Name string
+	Uid  string
+	Todo Todo
+	// Calendar with a single VTODO
+	Data string
+	ETag string
+}
+
+func newCalDAVObject(t Todo, labels []Label, r CalDAVResource) (o CalDAVObject) {
+	// Stamped with the last modification, so the data changes only with the todo
+	var stamp time.Time
+	if t.UpdatedAt != nil {
+		stamp = *t.UpdatedAt
+	} else if t.CreatedAt != nil {
+		stamp = *t.CreatedAt
+	}
+
+	var b strings.Builder
+	w := func(name string, value string) {
+		writeContentLine(&b, name+":"+value)
+	}
+	w("BEGIN", "VCALENDAR")
+	w("VERSION", "2.0")
+	w("PRODID", icalProdId)
+	w("CALSCALE", "GREGORIAN")
+	writeTodo(w, t, labels, "VTODO", r.Uid, stamp)
+	w("END", "VCALENDAR")
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return CalDAVObject{
+		Name: r.Name,
+		Uid:  r.Uid,
+		Todo: t,
+		Data: b.String(),
+		ETag: `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}
+}
+
+// Resource of the todo without a resource created by a client
+func defaultCalDAVResource(id uint64) CalDAVResource {
+	return CalDAVResource{Name: fmt.Sprintf("%d.ics", id), TodoId: id, Uid: icalUid(id)}
+}
+
+// CalDAVObjects returns the objects of all todos of the user in the order of creation
+func CalDAVObjects(s TodoStore, userId uint64) (objects []CalDAVObject, err error) {
+	todos, err := s.List(userId, GetListQuery{WithCompleted: true, Sort: SortCreatedAt, Order: OrderAsc})
+	if err != nil {
+		return
+	}
+	labels, err := s.ListLabels(userId)
+	if err != nil {
+		return
+	}
+	resources, err := s.ListCalDAVResources(userId)
+	if err != nil {
+		return
+	}
+	byTodo := map[uint64]CalDAVResource{}
+	for _, r := range resources {
+		byTodo[r.TodoId] = r
+	}
+	for _, t := range todos {
+		r, ok := byTodo[t.Id]
+		if !ok {
+			r = defaultCalDAVResource(t.Id)
+		}
+		objects = append(objects, newCalDAVObject(t, labels, r))
+	}
+	return
+}
+
+// CalDAVCTag changes whenever an object of the collection changes
+func CalDAVCTag(objects []CalDAVObject) string {
+	h := sha256.New()
+	for _, o := range objects {
+		h.Write([]byte(o.Name + o.ETag + "\n"))
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// Resource of the name, created by a client or `<id>.ics`
+func getCalDAVResource(s TodoStore, userId uint64, name string) (r CalDAVResource, notFound bool, err error) {
+	resources, err := s.ListCalDAVResources(userId)
+	if err != nil {
+		return
+	}
+	for _, r := range resources {
+		if r.Name == name {
+			return r, false, nil
+		}
+	}
+	id, err := strconv.ParseUint(strings.TrimSuffix(name, ".ics"), 10, 64)
+	if err != nil || !strings.HasSuffix(name, ".ics") {
+		return r, true, nil
+	}
+	for _, r := range resources {
+		if r.TodoId == id {
+			// Served by the name of the client
+			return r, true, nil
+		}
+	}
+	return defaultCalDAVResource(id), false, nil
+}
+
+// GetCalDAVObject returns the object of the resource
+func GetCalDAVObject(s TodoStore, userId uint64, name string) (o CalDAVObject, notFound bool, err error) {
+	r, notFound, err := getCalDAVResource(s, userId, name)
+	if err != nil || notFound {
+		return
+	}
+	t, notFound, err := s.Get(userId, r.TodoId)
+	if err != nil || notFound {
+		return
+	}
+	labels, err := s.ListLabels(userId)
+	if err != nil {
+		return
+	}
+	return newCalDAVObject(t, labels, r), false, nil
+}
+
+// Precondition of the request headers `If-Match` and `If-None-Match`, empty if not given
+func calDAVPreconditionFailed(etag string, exists bool, ifMatch string, ifNoneMatch string) bool {
+	if ifNoneMatch == "*" && exists || ifNoneMatch != "" && ifNoneMatch != "*" && exists && ifNoneMatch == etag {
+		return true
+	}
+	if ifMatch != "" && (!exists || ifMatch != "*" && ifMatch != etag) {
+		return true
+	}
+	return false
+}
+
+// PutCalDAVObject creates or updates the todo of the resource from a calendar with a single VTODO.
+// Properties which differ from the current object are applied by `Patch`,
+// a change of `STATUS` by `Complete` and `Uncomplete`, so repeat successors are created as usual.
+// validate checks the converted body like the body of `POST /`.
+// precondition is the violated precondition of RFC 4791 with the reason in failure,
+// failure alone is a conflict with the state of the todo.
+func PutCalDAVObject(s TodoStore, userId uint64, name string, ics string, ifMatch string, ifNoneMatch string, validate func(post PostBody) error) (o CalDAVObject, created bool, preconditionFailed bool, precondition string, failure string, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		components, invalid := ParseICalendar(ics)
+		if invalid || len(components) == 0 {
+			precondition, failure = CalDAVValidData, "VCALENDAR with a VTODO required"
+			return
+		}
+		if len(components) > 1 || components[0].Name != "VTODO" {
+			precondition, failure = CalDAVSupportedComponent, "a single VTODO is supported, without overrides of occurrences"
+			return
+		}
+		c := components[0]
+		uid := c.property("UID")
+		if uid == nil || uid.Value == "" {
+			precondition, failure = CalDAVValidData, "UID required"
+			return
+		}
+		labels, err := s.ListLabels(userId)
+		if err != nil {
+			return
+		}
+		var post PostBody
+		var exdates []string
+		post, exdates, _, failure = c.PostBody(labels)
+		if failure == "" {
+			if err := validate(post); err != nil {
+				failure = err.Error()
+			}
+		}
+		if failure != "" {
+			precondition = CalDAVValidData
+			return
+		}
+
+		current, notFound, err := GetCalDAVObject(s, userId, name)
+		if err != nil {
+			return
+		}
+		if preconditionFailed = calDAVPreconditionFailed(current.ETag, !notFound, ifMatch, ifNoneMatch); preconditionFailed {
+			return
+		}
+
+		if !notFound {
+			if current.Uid != uid.Value {
+				precondition, failure = CalDAVNoUidConflict, "UID of a resource cannot change"
+				return
+			}
+			failure, err = applyCalDAV(s, userId, current, labels, post, exdates)
+			if err != nil {
+				return
+			}
+			if failure != "" {
+				return errCalDAVFailed
+			}
+			o, _, err = GetCalDAVObject(s, userId, name)
+			return
+		}
+
+		// The UID identifies one resource
+		objects, err := CalDAVObjects(s, userId)
+		if err != nil {
+			return
+		}
+		for _, o := range objects {
+			if o.Uid == uid.Value {
+				precondition, failure = CalDAVNoUidConflict, fmt.Sprintf("UID is used by `%s`", o.Name)
+				return
+			}
+		}
+		var t Todo
+		t, failure, err = importTodo(s, userId, post, exdates)
+		if err != nil {
+			return
+		}
+		if failure != "" {
+			precondition = CalDAVValidData
+			return errCalDAVFailed
+		}
+		err = s.PutCalDAVResource(userId, CalDAVResource{Name: name, TodoId: t.Id, Uid: uid.Value})
+		if err != nil {
+			return
+		}
+		created = true
+		o, _, err = GetCalDAVObject(s, userId, name)
+		return
+	})
+	if err == errCalDAVFailed {
+		err = nil
+	}
+	return
+}
+
+// Apply the differences of post to the todo of the object
+func applyCalDAV(s TodoStore, userId uint64, current CalDAVObject, labels []Label, post PostBody, exdates []string) (failure string, err error) {
+	// The current object converted like the new one, so unchanged properties are equal
+	components, _ := ParseICalendar(current.Data)
+	base, baseExdates, _, _ := components[0].PostBody(labels)
+	id := current.Todo.Id
+
+	var patch PatchBody
+	changed := false
+	if post.Name != base.Name {
+		patch.Name = &post.Name
+		changed = true
+	}
+	if !equalStringPtr(post.Description, base.Description) {
+		patch.Description.String = &post.Description
+		changed = true
+	}
+	if !equalStringPtr(post.Date, base.Date) {
+		patch.Date.String = &post.Date
+		changed = true
+	}
+	if !equalStringPtr(post.Time, base.Time) {
+		patch.Time.String = &post.Time
+		changed = true
+	}
+	if *post.ExecutionTime != *base.ExecutionTime {
+		patch.ExecutionTime = post.ExecutionTime
+		changed = true
+	}
+	if !equalUintPtr(post.Priority, base.Priority) {
+		patch.Priority.UInt = &post.Priority
+		changed = true
+	}
+	if !equalIds(post.Labels, base.Labels) {
+		ids := append([]uint64{}, post.Labels...)
+		patch.Labels = &ids
+		changed = true
+	}
+	if post.Repeat == nil && base.Repeat != nil {
+		var repeat *PatchRepeatBody
+		patch.Repeat.Repeat = &repeat
+		changed = true
+	} else if post.Repeat != nil && (base.Repeat == nil || !equalStringPtr(post.Repeat.RRule, base.Repeat.RRule) || !equalStringPtr(post.Repeat.Until, base.Repeat.Until)) {
+		var count *uint
+		repeat := &PatchRepeatBody{
+			RRule: PatchNullJSONRRuleString{String: &post.Repeat.RRule},
+			Until: PatchNullJSONDateString{String: &post.Repeat.Until},
+			// `COUNT` is in the rule
+			Count: PatchNullUint{UInt: &count},
+		}
+		patch.Repeat.Repeat = &repeat
+		changed = true
+	}
+
+	if changed {
+		_, _, dateNotFound, dateOverUntil, noDaysWithWeekly, _, err := Patch(s, userId, id, patch, PatchScopeAll)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case dateNotFound:
+			return "`date` required to set `repeat`", nil
+		case dateOverUntil:
+			return "`date` must until `repeat.until`", nil
+		case noDaysWithWeekly:
+			return "`repeat.days` required with `repeat.unit: \"week\"`", nil
+		}
+	}
+
+	// New cancelled occurrences
+	if post.Repeat != nil {
+		t, _, err := s.Get(userId, id)
+		if err != nil {
+			return "", err
+		}
+		cancelled := false
+		for _, date := range exdates {
+			if t.Repeat == nil || containsString(baseExdates, date) {
+				continue
+			}
+			err = s.PutException(userId, t.Repeat.Id, RepeatException{Date: date, Cancelled: true})
+			if err != nil {
+				return "", err
+			}
+			cancelled = true
+		}
+		if cancelled {
+			err = recordEventId(s, userId, EventUpdated, id)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	completed := post.Completed != nil && *post.Completed
+	switch {
+	case completed && !current.Todo.Completed:
+		_, _, _, dateNotFound, invalidUnit, blockedBy, err := Complete(s, userId, id, false)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case len(blockedBy) != 0:
+			return fmt.Sprintf("todo is blocked by incomplete todos %v", blockedBy), nil
+		case invalidUnit:
+			return "invalid todo repeat unit", nil
+		case dateNotFound:
+			return "todo.date does not exists", nil
+		}
+	case !completed && current.Todo.Completed:
+		_, _, operationNotFound, conflict, err := Uncomplete(s, userId, id)
+		if err != nil {
+			return "", err
+		}
+		if operationNotFound || conflict {
+			// Not completed by `Complete`, or changed since
+			incomplete := false
+			_, _, _, _, _, _, err = Patch(s, userId, id, PatchBody{Completed: &incomplete}, PatchScopeAll)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	return
+}
+
+// DeleteCalDAVObject deletes the todo of the resource
+func DeleteCalDAVObject(s TodoStore, userId uint64, name string, ifMatch string) (notFound bool, preconditionFailed bool, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		var o CalDAVObject
+		o, notFound, err = GetCalDAVObject(s, userId, name)
+		if err != nil || notFound {
+			return
+		}
+		if preconditionFailed = calDAVPreconditionFailed(o.ETag, true, ifMatch, ""); preconditionFailed {
+			return
+		}
+		if _, err = Delete(s, userId, o.Todo.Id); err != nil {
+			return
+		}
+		return s.DeleteCalDAVResource(userId, name)
+	})
+	return
+}
+
+// CalDAVFilter is the filter of a `calendar-query` report
+type CalDAVFilter struct {
+	// Completed or incomplete todos only
+	Completed *bool
+	// Todos on dates overlapping the range, repeating todos from their date
+	Start *time.Time
+	End   *time.Time
+}
+
+// Match is true if the todo may have occurrences matching the filter
+func (f CalDAVFilter) Match(t Todo) bool {
+	if f.Completed != nil && t.Completed != *f.Completed {
+		return false
+	}
+	if f.Start == nil && f.End == nil {
+		return true
+	}
+	if t.Date == nil {
+		// Todos without date are due at any time
+		return true
+	}
+	date, err := time.Parse("2006-1-2", *t.Date)
+	if err != nil {
+		return true
+	}
+	if f.End != nil && !date.Before(*f.End) {
+		return false
+	}
+	if f.Start != nil && t.Repeat == nil && !date.AddDate(0, 0, 1).After(*f.Start) {
+		return false
+	}
+	return true
+}
+
+func equalStringPtr(a *string, b *string) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func equalUintPtr(a *uint, b *uint) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+// Same ids in any order
+func equalIds(a []uint64, b []uint64) bool {
+	a, b = append([]uint64{}, a...), append([]uint64{}, b...)
+	sort.Slice(a, func(i, j int) bool { return a[i] < a[j] })
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
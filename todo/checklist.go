@@ -0,0 +1,137 @@
+package todo
+
+// Item of the checklist of a todo
+type ChecklistItem struct {
+	Id      uint64 `json:"id,omitempty"`
+	Name    string `json:"name"`
+	Checked bool   `json:"checked"`
+}
+
+type ChecklistItemPostBody struct {
+	Name    string `json:"name" validate:"required,max=255"`
+	Checked bool   `json:"checked" validate:"omitempty"`
+}
+
+type ChecklistItemPatchBody struct {
+	Name    *string `json:"name" validate:"omitempty,gte=1,max=255"`
+	Checked *bool   `json:"checked" validate:"omitempty"`
+}
+
+type ChecklistOrderBody struct {
+	// All item ids of the checklist in the new order
+	Ids []uint64 `json:"ids" validate:"required"`
+}
+
+// Copy of the checklist with all items unchecked, inserted as new items
+func uncheckedChecklist(checklist []ChecklistItem) (unchecked []ChecklistItem) {
+	for _, item := range checklist {
+		unchecked = append(unchecked, ChecklistItem{Name: item.Name})
+	}
+	return
+}
+
+func (t *Todo) checklistItem(id uint64) *ChecklistItem {
+	for i := range t.Checklist {
+		if t.Checklist[i].Id == id {
+			return &t.Checklist[i]
+		}
+	}
+	return nil
+}
+
+// PostChecklistItem appends an item to the checklist of the todo
+func PostChecklistItem(s TodoStore, userId uint64, todoId uint64, post ChecklistItemPostBody) (item ChecklistItem, notFound bool, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		_, notFound, err = s.Get(userId, todoId)
+		if err != nil || notFound {
+			return
+		}
+		item, err = s.InsertChecklistItem(userId, todoId, ChecklistItem{Name: post.Name, Checked: post.Checked})
+		if err != nil {
+			return
+		}
+		return recordEventId(s, userId, EventUpdated, todoId)
+	})
+	return
+}
+
+func PatchChecklistItem(s TodoStore, userId uint64, todoId uint64, id uint64, patch ChecklistItemPatchBody) (item ChecklistItem, notFound bool, itemNotFound bool, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		var t Todo
+		t, notFound, err = s.Get(userId, todoId)
+		if err != nil || notFound {
+			return
+		}
+		old := t.checklistItem(id)
+		if old == nil {
+			itemNotFound = true
+			return
+		}
+
+		item = *old
+		if patch.Name != nil {
+			item.Name = *patch.Name
+		}
+		if patch.Checked != nil {
+			item.Checked = *patch.Checked
+		}
+		item, itemNotFound, err = s.UpdateChecklistItem(userId, todoId, item)
+		if err != nil || itemNotFound {
+			return
+		}
+		return recordEventId(s, userId, EventUpdated, todoId)
+	})
+	return
+}
+
+func DeleteChecklistItem(s TodoStore, userId uint64, todoId uint64, id uint64) (notFound bool, itemNotFound bool, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		_, notFound, err = s.Get(userId, todoId)
+		if err != nil || notFound {
+			return
+		}
+		itemNotFound, err = s.DeleteChecklistItem(userId, todoId, id)
+		if err != nil || itemNotFound {
+			return
+		}
+		return recordEventId(s, userId, EventUpdated, todoId)
+	})
+	return
+}
+
+// ReorderChecklist sorts the checklist in the order of `ids`.
+// invalidOrder is true unless `ids` has every item of the checklist once.
+func ReorderChecklist(s TodoStore, userId uint64, todoId uint64, ids []uint64) (checklist []ChecklistItem, notFound bool, invalidOrder bool, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		var t Todo
+		t, notFound, err = s.Get(userId, todoId)
+		if err != nil || notFound {
+			return
+		}
+		if len(ids) != len(t.Checklist) {
+			invalidOrder = true
+			return
+		}
+		seen := map[uint64]bool{}
+		for _, id := range ids {
+			if seen[id] || t.checklistItem(id) == nil {
+				invalidOrder = true
+				return
+			}
+			seen[id] = true
+		}
+
+		err = s.ReorderChecklist(userId, todoId, ids)
+		if err != nil {
+			return
+		}
+		t, _, err = s.Get(userId, todoId)
+		if err != nil {
+			return
+		}
+		checklist = t.Checklist
+		t.fillRRule()
+		return recordEvent(s, userId, EventUpdated, t, nil)
+	})
+	return
+}
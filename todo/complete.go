@@ -1,135 +1,138 @@
 package todo
 
 import (
-	"database/sql"
-	"flow-todos/mysql"
 	"time"
 )
 
-func Complete(userId uint64, id uint64) (t Todo, new Todo, notFound bool, dateNotFound bool, invalidUnit bool, err error) {
-	// Get old
-	t, notFound, err = Get(userId, id)
-	if err != nil {
-		return
-	}
-	if notFound {
+// Insert the todo of the next occurrence sharing the repeat model of t.
+// The repeat model is left to t, callers detach or complete t.
+func insertNext(s TodoStore, userId uint64, t Todo, completed *time.Time) (new Todo, overUntil bool, invalidUnit bool, err error) {
+	// Get next occurrence
+	next, applied, overUntil, invalidUnit, err := t.nextOccurrence(completed)
+	if err != nil || invalidUnit || overUntil {
 		return
 	}
 
-	var db *sql.DB
-	db, err = mysql.Open()
-	if err != nil {
-		return
-	}
-	defer db.Close()
-
-	// No repeat
-	if t.Repeat == nil {
-		// Update row
-		var stmt *sql.Stmt
-		stmt, err = db.Prepare("UPDATE todos SET completed = ? WHERE user_id = ? AND id = ?")
-		if err != nil {
-			return
-		}
-		defer stmt.Close()
-		_, err = stmt.Exec(true, userId, id)
-		if err != nil {
-			return
-		}
+	new = next
+	new.Id = 0
+	new.Completed = false
+	new.Checklist = uncheckedChecklist(next.Checklist)
+	// Dependencies stay with the completed occurrence
+	new.BlockedBy = nil
+	new.fillRRule()
 
-		t.Completed = true
-		return
-	}
+	// TODO: if out from sprint due
 
-	// Repeat todo and no date
-	if t.Date == nil {
-		dateNotFound = true
+	// Insert DB
+	new, err = s.Insert(userId, new)
+	if err != nil {
 		return
 	}
 
-	/**
-	 * Create next repeat todo
-	**/
-
-	// Get next date
-	var date time.Time
-	date, err = time.Parse("2006-1-2", *t.Date)
+	// Update repeat model
+	new, err = s.Update(userId, new)
 	if err != nil {
 		return
 	}
-	nextDate, nextTime, overUntil, invalidUnit, err := t.Repeat.GetNext(date.Year(), date.Month(), date.Day())
+	err = saveExceptions(s, userId, new, applied)
 	if err != nil {
 		return
 	}
-	if invalidUnit {
-		return
-	}
-	if overUntil {
-		// Update old
-		var stmt *sql.Stmt
-		stmt, err = db.Prepare("UPDATE todos SET completed = ? WHERE user_id = ? AND id = ?")
+	new, _, err = s.Get(userId, new.Id)
+	new.fillRRule()
+	return
+}
+
+// Complete the todo.
+// Unless forced, the todo is not completed and `blockedBy` has the incomplete blockers.
+func Complete(s TodoStore, userId uint64, id uint64, force bool) (t Todo, new Todo, notFound bool, dateNotFound bool, invalidUnit bool, blockedBy []uint64, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		// Get old
+		t, notFound, err = s.Get(userId, id)
 		if err != nil {
 			return
 		}
-		defer stmt.Close()
-		_, err = stmt.Exec(true, userId, id)
-		if err != nil {
+		if notFound {
 			return
 		}
+		before := t
+		t.fillRRule()
+
+		// Blocked
+		if !force {
+			blockedBy, err = incompleteBlockers(s, userId, t)
+			if err != nil || len(blockedBy) != 0 {
+				return
+			}
+		}
 
-		t.Completed = true
-		return
-	}
-
-	new = t
-	new.Date = &nextDate
-	if nextTime != nil {
-		new.Time = nextTime
-	}
-
-	// TODO: if out from sprint due
-
-	// Insert DB
-	stmt2, err := db.Prepare(
-		`INSERT INTO todos
-			(user_id, name, description, date, time, execution_time, sprint_id, project_id, repeat_model_id)
-		SELECT
-			user_id, name, description, ?, ?, execution_time, sprint_id, project_id, repeat_model_id
-		FROM todos
-		WHERE user_id = ? AND id = ?`,
-	)
-	if err != nil {
-		return
-	}
-	defer stmt2.Close()
-	result, err := stmt2.Exec(new.Date, new.Time, userId, id)
-	if err != nil {
-		return
-	}
-	newId, err := result.LastInsertId()
-	if err != nil {
-		return
-	}
-	new.Id = uint64(newId)
+		// No repeat
+		if t.Repeat == nil {
+			// Update row
+			t.Completed = true
+			t, err = s.Update(userId, t)
+			if err != nil {
+				return
+			}
+			err = recordOperation(s, userId, OperationComplete, before, 0)
+			if err != nil {
+				return
+			}
+			return recordEvent(s, userId, EventCompleted, t, nil)
+		}
 
-	/**
-	 * Update old
-	**/
+		// Repeat todo and no date
+		if t.Date == nil {
+			dateNotFound = true
+			return
+		}
 
-	// Update row
-	var stmt *sql.Stmt
-	stmt, err = db.Prepare("UPDATE todos SET completed = ?, repeat_model_id = ? WHERE user_id = ? AND id = ?")
-	if err != nil {
-		return
-	}
-	defer stmt.Close()
-	_, err = stmt.Exec(true, nil, userId, id)
-	if err != nil {
-		return
-	}
+		/**
+		 * Create next repeat todo
+		**/
+		var overUntil bool
+		completed := time.Now()
+		new, overUntil, invalidUnit, err = insertNext(s, userId, t, &completed)
+		if err != nil {
+			return
+		}
+		if invalidUnit {
+			return
+		}
+		if overUntil {
+			// Update old
+			t.Completed = true
+			t, err = s.Update(userId, t)
+			if err != nil {
+				return
+			}
+			err = recordOperation(s, userId, OperationComplete, before, 0)
+			if err != nil {
+				return
+			}
+			return recordEvent(s, userId, EventCompleted, t, nil)
+		}
 
-	t.Completed = true
-	t.Repeat = nil
+		/**
+		 * Update old
+		**/
 
+		// Update row
+		t.Completed = true
+		t.Repeat = nil
+		t, err = s.Update(userId, t)
+		if err != nil {
+			return
+		}
+		err = recordOperation(s, userId, OperationComplete, before, new.Id)
+		if err != nil {
+			return
+		}
+		err = recordEvent(s, userId, EventCompleted, t, &new)
+		if err != nil {
+			return
+		}
+		return recordEvent(s, userId, EventCreated, new, nil)
+	})
 	return
 }
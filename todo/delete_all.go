@@ -1,26 +1,23 @@
 package todo
 
-import "flow-todos/mysql"
-
-func DeleteAll(userId uint64) (err error) {
-	db, err := mysql.Open()
-	if err != nil {
-		return
-	}
-	defer db.Close()
-	stmt, err := db.Prepare(
-		`DELETE todos, repeat_models
-			FROM todos LEFT JOIN repeat_models ON todos.repeat_model_id = repeat_models.id
-			WHERE todos.user_id = ?`,
-	)
-	if err != nil {
+func DeleteAll(s TodoStore, userId uint64) (err error) {
+	return s.WithTx(func(s TodoStore) (err error) {
+		// The deleted todos of the events
+		todos, err := s.List(userId, GetListQuery{WithCompleted: true})
+		if err != nil {
+			return
+		}
+		err = s.DeleteAll(userId)
+		if err != nil {
+			return
+		}
+		for _, t := range todos {
+			t.fillRRule()
+			err = recordEvent(s, userId, EventDeleted, t, nil)
+			if err != nil {
+				return
+			}
+		}
 		return
-	}
-	defer stmt.Close()
-	_, err = stmt.Exec(userId)
-	if err != nil {
-		return
-	}
-
-	return
+	})
 }
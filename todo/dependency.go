@@ -0,0 +1,72 @@
+package todo
+
+// Todo `TodoId` is blocked by todo `BlockerId` until the blocker is completed
+type Dependency struct {
+	TodoId    uint64
+	BlockerId uint64
+}
+
+// CheckDependencies checks the blockers of todo `id` (0 for a new todo).
+// notFound is the first blocker which is not a todo of the user,
+// cycle is true if a blocker is the todo itself or is blocked by it transitively.
+func CheckDependencies(s TodoStore, userId uint64, id uint64, blockedBy []uint64) (notFound *uint64, cycle bool, err error) {
+	for _, blockerId := range blockedBy {
+		var blockerNotFound bool
+		_, blockerNotFound, err = s.Get(userId, blockerId)
+		if err != nil {
+			return
+		}
+		if blockerNotFound {
+			blockerId := blockerId
+			notFound = &blockerId
+			return
+		}
+	}
+	if id == 0 {
+		// Nothing depends on a new todo
+		return
+	}
+
+	dependencies, err := s.ListDependencies(userId)
+	if err != nil {
+		return
+	}
+	blockers := map[uint64][]uint64{}
+	for _, d := range dependencies {
+		blockers[d.TodoId] = append(blockers[d.TodoId], d.BlockerId)
+	}
+
+	// Search `id` in the blockers of the new blockers
+	visited := map[uint64]bool{}
+	stack := append([]uint64{}, blockedBy...)
+	for len(stack) != 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == id {
+			cycle = true
+			return
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		stack = append(stack, blockers[current]...)
+	}
+	return
+}
+
+// Blockers of the todo not completed yet
+func incompleteBlockers(s TodoStore, userId uint64, t Todo) (incomplete []uint64, err error) {
+	for _, blockerId := range t.BlockedBy {
+		var blocker Todo
+		var notFound bool
+		blocker, notFound, err = s.Get(userId, blockerId)
+		if err != nil {
+			return
+		}
+		if !notFound && !blocker.Completed {
+			incomplete = append(incomplete, blockerId)
+		}
+	}
+	return
+}
@@ -0,0 +1,394 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version of the dump format
+const DumpVersion = 1
+
+// Strategies for todos of a dump which are already todos of the user
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictDuplicate = "duplicate"
+)
+
+// Status of a restored todo
+const (
+	RestoreCreated     = "created"
+	RestoreOverwritten = "overwritten"
+	RestoreSkipped     = "skipped"
+	RestoreFailed      = "failed"
+)
+
+// Dump of all todos of a user, with repeat models and labels, to be restored by `Restore`
+type Dump struct {
+	Version    int        `json:"version"`
+	ExportedAt *time.Time `json:"exported_at,omitempty"`
+	Labels     []Label    `json:"labels"`
+	Todos      []snapshot `json:"todos"`
+}
+
+type RestoreResult struct {
+	// Id in the dump
+	Id     uint64 `json:"id"`
+	Status string `json:"status"`
+	// Id of the restored todo, not set in dry run
+	NewId    uint64   `json:"new_id,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Columns of the CSV dump, nested values are JSON
+var dumpColumns = []string{
+	"id", "name", "description", "date", "time", "execution_time", "sprint_id", "project_id", "completed", "priority",
+	"labels", "checklist", "blocked_by",
+	"repeat_id", "repeat_until", "repeat_unit", "repeat_every_other", "repeat_date", "repeat_month", "repeat_leap_day",
+	"repeat_days", "repeat_rrule", "repeat_count", "repeat_from", "repeat_done", "repeat_exceptions",
+	"created_at", "updated_at",
+}
+
+// NewDump returns all todos of the user in the order of creation
+func NewDump(s TodoStore, userId uint64, now time.Time) (d Dump, err error) {
+	todos, err := s.List(userId, GetListQuery{WithCompleted: true, Sort: SortCreatedAt, Order: OrderAsc})
+	if err != nil {
+		return
+	}
+	d.Labels, err = s.ListLabels(userId)
+	if err != nil {
+		return
+	}
+	d.Version = DumpVersion
+	d.ExportedAt = &now
+	d.Labels = append([]Label{}, d.Labels...)
+	d.Todos = []snapshot{}
+	for _, t := range todos {
+		// Full todo with exceptions, checklist and blockers
+		t, _, err = s.Get(userId, t.Id)
+		if err != nil {
+			return
+		}
+		snapshot := newSnapshot(t)
+		if snapshot.Repeat != nil {
+			repeat := *snapshot.Repeat
+			repeat.Exceptions = nil
+			snapshot.Repeat = &repeat
+		}
+		d.Todos = append(d.Todos, snapshot)
+	}
+	return
+}
+
+// CSV encodes the dump with a row per todo
+func (d Dump) CSV() (b []byte, err error) {
+	labels := map[uint64]Label{}
+	for _, l := range d.Labels {
+		labels[l.Id] = l
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err = w.Write(dumpColumns); err != nil {
+		return
+	}
+	for _, t := range d.Todos {
+		var todoLabels []Label
+		for _, id := range t.Labels {
+			if l, ok := labels[id]; ok {
+				todoLabels = append(todoLabels, l)
+			}
+		}
+		row := map[string]string{
+			"id":             fmt.Sprint(t.Id),
+			"name":           t.Name,
+			"description":    csvString(t.Description),
+			"date":           csvString(t.Date),
+			"time":           csvString(t.Time),
+			"execution_time": fmt.Sprint(t.ExecutionTime),
+			"sprint_id":      csvUint64(t.SprintId),
+			"project_id":     csvUint64(t.ProjectId),
+			"completed":      strconv.FormatBool(t.Completed),
+			"priority":       csvUint(t.Priority),
+			"labels":         csvJSON(todoLabels),
+			"checklist":      csvJSON(t.Checklist),
+			"blocked_by":     csvJSON(t.BlockedBy),
+			"created_at":     csvTime(t.CreatedAt),
+			"updated_at":     csvTime(t.UpdatedAt),
+		}
+		if r := t.Repeat; r != nil {
+			row["repeat_id"] = fmt.Sprint(t.RepeatId)
+			row["repeat_until"] = csvString(r.Until)
+			row["repeat_unit"] = r.Unit
+			row["repeat_every_other"] = csvUint(r.EveryOther)
+			row["repeat_date"] = csvUint(r.Date)
+			row["repeat_month"] = csvUint(r.Month)
+			row["repeat_leap_day"] = csvString(r.LeapDay)
+			row["repeat_days"] = csvJSON(r.Days)
+			row["repeat_rrule"] = csvString(r.RRule)
+			row["repeat_count"] = csvUint(r.Count)
+			row["repeat_from"] = r.From
+			row["repeat_done"] = fmt.Sprint(t.Done)
+			row["repeat_exceptions"] = csvJSON(t.Exceptions)
+		}
+		record := make([]string, len(dumpColumns))
+		for i, column := range dumpColumns {
+			record[i] = row[column]
+		}
+		if err = w.Write(record); err != nil {
+			return
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func csvString(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+func csvUint(p *uint) string {
+	if p == nil {
+		return ""
+	}
+	return fmt.Sprint(*p)
+}
+
+func csvUint64(p *uint64) string {
+	if p == nil {
+		return ""
+	}
+	return fmt.Sprint(*p)
+}
+
+func csvTime(p *time.Time) string {
+	if p == nil {
+		return ""
+	}
+	return p.UTC().Format(time.RFC3339)
+}
+
+// Empty for null and empty values
+func csvJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil || string(b) == "null" || string(b) == "[]" {
+		return ""
+	}
+	return string(b)
+}
+
+// ParseCSVDump decodes a dump encoded by `Dump.CSV`.
+// Columns are matched by the header, `id` and `name` are required.
+func ParseCSVDump(r io.Reader) (d Dump, err error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return
+	}
+	if len(records) == 0 {
+		err = errors.New("header required")
+		return
+	}
+	columns := map[string]int{}
+	for i, column := range records[0] {
+		columns[strings.TrimSpace(column)] = i
+	}
+	for _, required := range []string{"id", "name"} {
+		if _, ok := columns[required]; !ok {
+			err = fmt.Errorf("column `%s` required", required)
+			return
+		}
+	}
+
+	d.Version = DumpVersion
+	d.Labels = []Label{}
+	d.Todos = []snapshot{}
+	for n, record := range records[1:] {
+		p := csvRecord{columns, record}
+		var t snapshot
+		if t, err = p.snapshot(&d); err != nil {
+			err = fmt.Errorf("row %d: %w", n+2, err)
+			return
+		}
+		d.Todos = append(d.Todos, t)
+	}
+	return
+}
+
+type csvRecord struct {
+	columns map[string]int
+	record  []string
+}
+
+func (r csvRecord) get(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.record) {
+		return ""
+	}
+	return r.record[i]
+}
+
+func (r csvRecord) string(column string) *string {
+	v := r.get(column)
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
+func (r csvRecord) uint(column string) (p *uint, err error) {
+	v := r.get(column)
+	if v == "" {
+		return
+	}
+	u, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s `%s`", column, v)
+	}
+	value := uint(u)
+	return &value, nil
+}
+
+func (r csvRecord) uint64(column string) (p *uint64, err error) {
+	v := r.get(column)
+	if v == "" {
+		return
+	}
+	u, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s `%s`", column, v)
+	}
+	return &u, nil
+}
+
+func (r csvRecord) time(column string) (p *time.Time, err error) {
+	v := r.get(column)
+	if v == "" {
+		return
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s `%s`", column, v)
+	}
+	return &t, nil
+}
+
+func (r csvRecord) json(column string, v interface{}) (err error) {
+	str := r.get(column)
+	if str == "" {
+		return
+	}
+	if err = json.Unmarshal([]byte(str), v); err != nil {
+		return fmt.Errorf("invalid %s: %w", column, err)
+	}
+	return
+}
+
+func (r csvRecord) snapshot(d *Dump) (t snapshot, err error) {
+	var id, sprintId, projectId, repeatId *uint64
+	var executionTime, done *uint
+	if id, err = r.uint64("id"); err != nil {
+		return
+	}
+	if id == nil {
+		return t, errors.New("id required")
+	}
+	t.Id = *id
+	t.Name = r.get("name")
+	t.Description = r.string("description")
+	t.Date = r.string("date")
+	t.Time = r.string("time")
+	if executionTime, err = r.uint("execution_time"); err != nil {
+		return
+	}
+	if executionTime != nil {
+		t.ExecutionTime = *executionTime
+	}
+	if sprintId, err = r.uint64("sprint_id"); err != nil {
+		return
+	}
+	t.SprintId = sprintId
+	if projectId, err = r.uint64("project_id"); err != nil {
+		return
+	}
+	t.ProjectId = projectId
+	t.Completed = r.get("completed") == "true"
+	if t.Priority, err = r.uint("priority"); err != nil {
+		return
+	}
+	if t.CreatedAt, err = r.time("created_at"); err != nil {
+		return
+	}
+	if t.UpdatedAt, err = r.time("updated_at"); err != nil {
+		return
+	}
+
+	var labels []Label
+	if err = r.json("labels", &labels); err != nil {
+		return
+	}
+	for _, l := range labels {
+		t.Labels = append(t.Labels, l.Id)
+		found := false
+		for _, l2 := range d.Labels {
+			found = found || l2.Id == l.Id
+		}
+		if !found {
+			d.Labels = append(d.Labels, l)
+		}
+	}
+	if err = r.json("checklist", &t.Checklist); err != nil {
+		return
+	}
+	if err = r.json("blocked_by", &t.BlockedBy); err != nil {
+		return
+	}
+
+	if repeatId, err = r.uint64("repeat_id"); err != nil || repeatId == nil {
+		return
+	}
+	repeat := Repeat{
+		Until:   r.string("repeat_until"),
+		Unit:    r.get("repeat_unit"),
+		LeapDay: r.string("repeat_leap_day"),
+		RRule:   r.string("repeat_rrule"),
+		From:    r.get("repeat_from"),
+	}
+	if repeat.EveryOther, err = r.uint("repeat_every_other"); err != nil {
+		return
+	}
+	if repeat.Date, err = r.uint("repeat_date"); err != nil {
+		return
+	}
+	if repeat.Month, err = r.uint("repeat_month"); err != nil {
+		return
+	}
+	if repeat.Count, err = r.uint("repeat_count"); err != nil {
+		return
+	}
+	if err = r.json("repeat_days", &repeat.Days); err != nil {
+		return
+	}
+	if done, err = r.uint("repeat_done"); err != nil {
+		return
+	}
+	if done != nil {
+		t.Done = *done
+	}
+	if err = r.json("repeat_exceptions", &t.Exceptions); err != nil {
+		return
+	}
+	t.RepeatId = *repeatId
+	t.Repeat = &repeat
+	return
+}
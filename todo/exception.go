@@ -0,0 +1,211 @@
+package todo
+
+import (
+	"flow-todos/rrule"
+	"time"
+)
+
+// Exception of a single occurrence of the repeat.
+// Like `EXDATE` (cancelled) and `RDATE` (moved) of RFC 5545.
+type RepeatException struct {
+	// Date of the occurrence generated by the repeat
+	Date          string  `json:"date"`
+	Cancelled     bool    `json:"cancelled"`
+	NewDate       *string `json:"new_date,omitempty"`
+	Time          *string `json:"time,omitempty"`
+	Name          *string `json:"name,omitempty"`
+	ExecutionTime *uint   `json:"execution_time,omitempty"`
+
+	// Set when the occurrence became the todo of the repeat.
+	// Values of the series replaced by the overrides are kept to restore the following occurrences.
+	Applied             bool    `json:"-"`
+	SeriesTime          *string `json:"-"`
+	SeriesName          *string `json:"-"`
+	SeriesExecutionTime *uint   `json:"-"`
+}
+
+func (r *Repeat) exception(date string) *RepeatException {
+	for i := range r.Exceptions {
+		if r.Exceptions[i].Date == date {
+			return &r.Exceptions[i]
+		}
+	}
+	return nil
+}
+
+// Date of the occurrence after overrides
+func (e *RepeatException) date() string {
+	if e.NewDate != nil {
+		return *e.NewDate
+	}
+	return e.Date
+}
+
+func (e *RepeatException) apply(t *Todo) {
+	if e.NewDate != nil {
+		date := *e.NewDate
+		t.Date = &date
+	}
+	if e.Time != nil {
+		t.Time = e.Time
+	}
+	if e.Name != nil {
+		t.Name = *e.Name
+	}
+	if e.ExecutionTime != nil {
+		t.ExecutionTime = *e.ExecutionTime
+	}
+}
+
+// Original date of the occurrence of the todo and the todo with values of the series.
+// Overrides applied to the todo are reverted.
+func (t *Todo) series() (anchor string, base Todo) {
+	base = *t
+	anchor = *t.Date
+	if t.Repeat == nil {
+		return
+	}
+	for _, e := range t.Repeat.Exceptions {
+		if !e.Applied || e.date() != *t.Date {
+			continue
+		}
+		anchor = e.Date
+		if e.Name != nil && e.SeriesName != nil {
+			base.Name = *e.SeriesName
+		}
+		if e.Time != nil {
+			base.Time = e.SeriesTime
+		}
+		if e.ExecutionTime != nil && e.SeriesExecutionTime != nil {
+			base.ExecutionTime = *e.SeriesExecutionTime
+		}
+		break
+	}
+	return
+}
+
+// Repeat ended the day before the occurrence on anchor, unless it already ends earlier
+func endRepeatBefore(r Repeat, anchor string) (ended Repeat, err error) {
+	date, err := time.Parse("2006-1-2", anchor)
+	if err != nil {
+		return
+	}
+	until := date.AddDate(0, 0, -1).Format("2006-01-02")
+	ended = r
+	if ended.Until == nil || *ended.Until > until {
+		ended.Until = &until
+	}
+	return
+}
+
+// Recurrence rule of the todo anchored to the original date of its occurrence
+func (t *Todo) rule() (rule rrule.RRule, dtstart time.Time, invalidUnit bool, err error) {
+	anchor, _ := t.series()
+	dtstart, err = time.Parse("2006-1-2", anchor)
+	if err != nil {
+		return
+	}
+	rule, invalidUnit, err = t.Repeat.Rule(dtstart)
+	if err != nil || invalidUnit {
+		return
+	}
+	if t.Repeat.Until != nil {
+		var until time.Time
+		until, err = time.Parse("2006-1-2", *t.Repeat.Until)
+		if err != nil {
+			return
+		}
+		if rule.Until == nil || until.Before(*rule.Until) {
+			rule.Until = &until
+		}
+	}
+	return
+}
+
+// Virtual todo of the occurrence on `date`, ok is false if the occurrence is cancelled
+func (t *Todo) occurrence(date time.Time) (o Todo, ok bool) {
+	d := date.Format("2006-01-02")
+	o = *t
+	o.OriginalId = t.Id
+	o.Id = 0
+	o.Date = &d
+	if dayTime := t.Repeat.timeOn(date); dayTime != nil {
+		o.Time = dayTime
+	}
+	o.Repeat = nil
+	o.Checklist = uncheckedChecklist(t.Checklist)
+	o.BlockedBy = nil
+	o.Blocks = nil
+	if e := t.Repeat.exception(d); e != nil {
+		if e.Cancelled {
+			return
+		}
+		e.apply(&o)
+	}
+	return o, true
+}
+
+// Next occurrence of the repeat of the todo.
+// Cancelled occurrences are passed and counted, overrides of the occurrence are applied.
+// `applied` is the exception of the next occurrence to be saved.
+// Repeats from completion are anchored to `completed` if given.
+func (t *Todo) nextOccurrence(completed *time.Time) (next Todo, applied *RepeatException, overUntil bool, invalidUnit bool, err error) {
+	anchor, base := t.series()
+	if completed != nil && t.Repeat.From == RepeatFromCompletion {
+		anchor = completed.Format("2006-01-02")
+	}
+	repeat := *t.Repeat
+	base.Repeat = &repeat
+
+	for {
+		var date time.Time
+		date, err = time.Parse("2006-1-2", anchor)
+		if err != nil {
+			return
+		}
+		var nextDate string
+		var nextTime *string
+		nextDate, nextTime, overUntil, invalidUnit, err = repeat.GetNext(date.Year(), date.Month(), date.Day())
+		if err != nil || invalidUnit || overUntil {
+			return
+		}
+		repeat.Done++
+
+		e := repeat.exception(nextDate)
+		if e != nil && e.Cancelled {
+			anchor = nextDate
+			continue
+		}
+
+		next = base
+		next.Date = &nextDate
+		if nextTime != nil {
+			next.Time = nextTime
+		}
+		if e != nil {
+			a := *e
+			a.Applied = true
+			name := next.Name
+			executionTime := next.ExecutionTime
+			a.SeriesName = &name
+			a.SeriesTime = next.Time
+			a.SeriesExecutionTime = &executionTime
+			e.apply(&next)
+			applied = &a
+		}
+		return
+	}
+}
+
+// Save the exception applied to the todo and delete exceptions of passed occurrences
+func saveExceptions(s TodoStore, userId uint64, t Todo, applied *RepeatException) (err error) {
+	anchor := *t.Date
+	if applied != nil {
+		anchor = applied.Date
+		err = s.PutException(userId, t.Repeat.Id, *applied)
+		if err != nil {
+			return
+		}
+	}
+	return s.DeleteExceptionsBefore(userId, t.Repeat.Id, anchor)
+}
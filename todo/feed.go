@@ -0,0 +1,36 @@
+package todo
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// The feed token is the secret of the calendar feed URL of a user, subscribable without JWT.
+// Only its SHA-256 is stored.
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewFeedToken creates the feed token of the user, replacing the previous one
+func NewFeedToken(s TodoStore, userId uint64) (token string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	err = s.PutFeedToken(userId, hashFeedToken(token))
+	return
+}
+
+// FeedUser returns the user of the feed token
+func FeedUser(s TodoStore, token string) (userId uint64, notFound bool, err error) {
+	return s.GetFeedTokenUser(hashFeedToken(token))
+}
+
+// DeleteFeedToken revokes the feed token of the user
+func DeleteFeedToken(s TodoStore, userId uint64) (notFound bool, err error) {
+	return s.DeleteFeedToken(userId)
+}
@@ -1,180 +1,186 @@
 package todo
 
 import (
-	"flow-todos/mysql"
-	"sort"
+	"strings"
 	"time"
 )
 
 type GetListQuery struct {
 	Start               *time.Time `query:"start" validate:"omitempty"`
 	End                 *time.Time `query:"end" validate:"omitempty"`
-	ProjectId           *uint64    `query:"project_id" validate:"omitempty,gte=1"`
+	ProjectIds          []uint64   `query:"project_id" validate:"omitempty"`
+	SprintId            *uint64    `query:"sprint_id" validate:"omitempty,gte=1"`
 	WithCompleted       bool       `query:"with_completed" validate:"omitempty"`
 	WithRepeatSchedules bool       `query:"with_repeat_schedules" validate:"omitempty"`
+	OnlyCompleted       bool
 	OnlyRepeatModel     bool
+	// Todos with or without date
+	HasDate *bool
+	// Todos with or without repeat
+	IsRepeating *bool
+	// Substring of name or description, case insensitive
+	Text             *string
+	MinExecutionTime *uint
+	MaxExecutionTime *uint
+	// Todos with any of the priorities, 0 is no priority
+	Priorities []uint
+	// Todos with or without incomplete blockers
+	Blocked *bool
+	// Todos with all of the labels
+	Labels []uint64
+	Sort   string
+	Order  string
+	// Max number of todos, 0 is unlimited
+	Limit  uint
+	Cursor *string
+	// Keys of `Cursor`, the store lists todos sorting after them
+	After []string
 }
 
-func GetList(userId uint64, q GetListQuery) (todos []Todo, err error) {
-	// Generate query
-	queryStr :=
-		`SELECT
-			todo.id, todo.name, todo.description, todo.date, TIME_FORMAT(todo.time, '%H:%i') AS time, todo.execution_time, todo.sprint_id, todo.project_id, todo.completed,
-			rpm.until, rpm.unit, rpm.every_other, rpm.date, rpd.day, TIME_FORMAT(rpd.time, '%H:%i') AS day_time
-		FROM todos as todo
-			LEFT JOIN repeat_models as rpm ON todo.repeat_model_id = rpm.id
-			LEFT JOIN repeat_days as rpd ON rpm.id = rpd.repeat_model_id
-		WHERE todo.user_id = ?`
-	queryParams := []interface{}{userId}
-	if q.Start != nil && q.End != nil {
-		queryStr += " AND ADDTIME(CONVERT(todo.date,DATETIME),COALESCE(todo.time,0)) BETWEEN ? AND ?"
-		queryParams = append(queryParams, q.Start, q.End)
-	} else if q.Start != nil {
-		queryStr += " AND ADDTIME(CONVERT(todo.date,DATETIME),COALESCE(todo.time,0)) >= ?"
-		queryParams = append(queryParams, q.Start)
-	} else if q.End != nil {
-		queryStr += " AND ADDTIME(CONVERT(todo.date,DATETIME),COALESCE(todo.time,0)) <= ?"
-		queryParams = append(queryParams, q.End)
+func GetList(s TodoStore, userId uint64, q GetListQuery) (todos []Todo, nextCursor *string, invalidCursor bool, err error) {
+	if q.Sort == "" {
+		q.Sort = SortDate
 	}
-	if q.ProjectId != nil {
-		queryStr += " AND todo.project_id = ?"
-		queryParams = append(queryParams, q.ProjectId)
+	if q.Order == "" {
+		q.Order = OrderAsc
 	}
-	if !q.WithCompleted {
-		queryStr += " AND todo.completed = false"
-	}
-	if q.OnlyRepeatModel {
-		queryStr += " AND todo.repeat_model_id IS NOT NULL"
-	}
-	queryStr += " ORDER BY todo.id, rpd.day, rpd.time"
-
-	db, err := mysql.Open()
-	if err != nil {
-		return
-	}
-	defer db.Close()
-
-	stmt, err := db.Prepare(queryStr)
-	if err != nil {
-		return
-	}
-	defer stmt.Close()
-
-	rows, err := stmt.Query(queryParams...)
-	if err != nil {
-		return
+	if q.Cursor != nil {
+		q.After, invalidCursor = decodeCursor(*q.Cursor, q.Sort, q.Order)
+		if invalidCursor {
+			return
+		}
 	}
-	defer rows.Close()
 
-	var tmpTodo Todo
-	for rows.Next() {
-		t := Todo{}
-		var repeatUnit *string
-		repeatModel := Repeat{}
-		var repeatDayNum *uint
-		var repeatDayTime *string
-		err = rows.Scan(
-			&t.Id, &t.Name, &t.Description, &t.Date, &t.Time, &t.ExecutionTime, &t.SprintId, &t.ProjectId, &t.Completed,
-			&repeatModel.Until, &repeatUnit, &repeatModel.EveryOther, &repeatModel.Date, &repeatDayNum, &repeatDayTime,
-		)
+	if !q.WithRepeatSchedules || q.End == nil {
+		// Sorted and paginated by the store
+		q1 := q
+		if q.Limit != 0 {
+			// One more to know if the next page exists
+			q1.Limit = q.Limit + 1
+		}
+		todos, err = s.List(userId, q1)
 		if err != nil {
 			return
 		}
-		if repeatUnit != nil {
-			repeatModel.Unit = *repeatUnit
-			if repeatModel.Unit == "week" && repeatDayNum != nil {
-				repeatModel.Days = []RepeatDay{{*repeatDayNum, repeatDayTime}}
-			}
-			t.Repeat = &repeatModel
+		for i := range todos {
+			todos[i].fillRRule()
 		}
-		if t.Id == tmpTodo.Id {
-			tmpTodo.Repeat.Days = append(tmpTodo.Repeat.Days, t.Repeat.Days...)
-		} else {
-			if tmpTodo.Id != 0 {
-				todos = append(todos, tmpTodo)
-			}
-			tmpTodo = t
-		}
-	}
-	if tmpTodo.Id != 0 {
-		todos = append(todos, tmpTodo)
-	}
-
-	if !q.WithRepeatSchedules || q.End == nil {
-		// Sort
-		sort.Slice(todos, func(i, j int) bool {
-			// Null end Todo.Date
-			if todos[i].Date == nil || todos[j].Date == nil {
-				return todos[i].Date != nil && todos[j].Date == nil
-			}
-
-			// Asc Todo.Date
-			if *todos[i].Date == *todos[j].Date {
-				// Null end Todo.Time
-				if todos[i].Time == nil || todos[j].Time == nil {
-					return todos[i].Time != nil && todos[j].Time == nil
-				}
-				// Asc Todo.Time
-				return *todos[i].Time < *todos[j].Time
-			}
-			return *todos[i].Date < *todos[j].Date
-		})
-
+		todos, nextCursor = page(todos, q)
 		return
 	}
 
+	// Stored todos are sorted and paginated by the store,
+	// occurrences of the repeats are merged for the page
 	var todos1 []Todo
-	for _, t := range todos {
-		if t.Repeat == nil || t.Date == nil {
-			todos1 = append(todos1, t)
-		} else {
+	if q.IsRepeating == nil || !*q.IsRepeating {
+		q1 := q
+		isRepeating := false
+		q1.IsRepeating = &isRepeating
+		if q.Limit != 0 {
+			q1.Limit = q.Limit + 1
+		}
+		todos1, err = s.List(userId, q1)
+		if err != nil {
+			return
+		}
+		for i := range todos1 {
+			todos1[i].fillRRule()
+		}
+	}
+	if q.IsRepeating == nil || *q.IsRepeating {
+		q1 := q
+		isRepeating := true
+		q1.IsRepeating = &isRepeating
+		q1.Limit = 0
+		q1.After = nil
+		var repeats []Todo
+		repeats, err = s.List(userId, q1)
+		if err != nil {
+			return
+		}
+		for i := range repeats {
+			repeats[i].fillRRule()
+		}
+		if q.Start != nil {
+			// Repeats started before the range
+			q2 := q
+			newEnd := q2.Start.Add(-time.Minute)
+			q2.End = &newEnd
+			q2.Start = nil
+			q2.WithCompleted = false
+			q2.WithRepeatSchedules = false
+			q2.OnlyRepeatModel = true
+			q2.Limit = 0
+			q2.Cursor = nil
+			q2.After = nil
 			var todos2 []Todo
-			todos2, _, _, _, err = t.GetScheduledRepeats(q.Start, *q.End)
+			todos2, _, _, err = GetList(s, userId, q2)
 			if err != nil {
 				return
 			}
-			todos1 = append(todos1, todos2...)
+			repeats = append(repeats, todos2...)
 		}
-	}
-	if q.Start != nil {
-		q2 := q
-		newEnd := q2.Start.Add(-time.Minute)
-		q2.End = &newEnd
-		q2.Start = nil
-		q2.WithCompleted = false
-		q2.WithRepeatSchedules = false
-		q2.OnlyRepeatModel = true
-		var todos3 []Todo
-		todos3, err = GetList(userId, q2)
-		for _, t := range todos3 {
-			var todos4 []Todo
-			todos4, _, _, _, err = t.GetScheduledRepeats(q.Start, *q.End)
+		for _, t := range repeats {
+			var occurrences []Todo
+			occurrences, err = t.scheduledPage(q)
 			if err != nil {
 				return
 			}
-			todos1 = append(todos1, todos4...)
+			todos1 = append(todos1, occurrences...)
 		}
 	}
 
-	// Sort
-	sort.Slice(todos1, func(i, j int) bool {
-		// Null end Todo.Date
-		if todos1[i].Date == nil || todos1[j].Date == nil {
-			return todos1[i].Date != nil && todos1[j].Date == nil
+	SortTodos(todos1, q.Sort, q.Order)
+	todos, nextCursor = page(todos1, q)
+	return
+}
+
+// Occurrences of the repeat in the range of q after the cursor, sorted and cut to a page with the next todo
+func (t *Todo) scheduledPage(q GetListQuery) (todos []Todo, err error) {
+	occurrences, _, _, _, err := t.GetScheduledRepeats(q.Start, *q.End)
+	if err != nil {
+		return
+	}
+	for _, o := range occurrences {
+		// Overrides of occurrences may not match
+		if q.MatchValues(o) && (q.After == nil || SortsAfter(o, q.Sort, q.Order, q.After)) {
+			todos = append(todos, o)
 		}
+	}
+	SortTodos(todos, q.Sort, q.Order)
+	if q.Limit != 0 && uint(len(todos)) > q.Limit+1 {
+		todos = todos[:q.Limit+1]
+	}
+	return
+}
 
-		// Asc Todo.Date
-		if *todos1[i].Date == *todos1[j].Date {
-			// Null end Todo.Time
-			if todos1[i].Time == nil || todos1[j].Time == nil {
-				return todos1[i].Time != nil && todos1[j].Time == nil
-			}
-			// Asc Todo.Time
-			return *todos1[i].Time < *todos1[j].Time
+// MatchValues returns true if the name, description and execution time of the todo match the query
+func (q *GetListQuery) MatchValues(t Todo) bool {
+	if q.Text != nil {
+		text := strings.ToLower(*q.Text)
+		description := ""
+		if t.Description != nil {
+			description = *t.Description
 		}
-		return *todos1[i].Date < *todos1[j].Date
-	})
+		if !strings.Contains(strings.ToLower(t.Name), text) && !strings.Contains(strings.ToLower(description), text) {
+			return false
+		}
+	}
+	if q.MinExecutionTime != nil && t.ExecutionTime < *q.MinExecutionTime {
+		return false
+	}
+	if q.MaxExecutionTime != nil && t.ExecutionTime > *q.MaxExecutionTime {
+		return false
+	}
+	return true
+}
 
-	todos = todos1
-	return
+// Cut todos to `q.Limit`, the cursor is returned if more todos follow
+func page(todos []Todo, q GetListQuery) ([]Todo, *string) {
+	if q.Limit == 0 || uint(len(todos)) <= q.Limit {
+		return todos, nil
+	}
+	todos = todos[:q.Limit]
+	cursor := encodeCursor(todos[len(todos)-1], q.Sort, q.Order)
+	return todos, &cursor
 }
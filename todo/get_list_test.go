@@ -0,0 +1,79 @@
+package todo_test
+
+import (
+	"flow-todos/memory"
+	"flow-todos/todo"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGetListWithRepeatSchedulesPages(t *testing.T) {
+	s := memory.NewTodoStore()
+	userId := newUserId()
+	insertTodo(t, s, userId, todo.Todo{Name: "plain", Date: stringPtr("2026-04-03")})
+	insertTodo(t, s, userId, todo.Todo{Name: "plain", Date: stringPtr("2026-04-05"), Time: stringPtr("12:00")})
+	insertTodo(t, s, userId, todo.Todo{Name: "earlier", Date: stringPtr("2026-03-30"), Repeat: &todo.Repeat{Unit: "day", EveryOther: uintPtr(2), From: todo.RepeatFromSchedule}})
+	insertTodo(t, s, userId, todo.Todo{Name: "daily", Date: stringPtr("2026-04-02"), Time: stringPtr("08:00"), Repeat: &todo.Repeat{Unit: "day", From: todo.RepeatFromSchedule}})
+
+	recorder := &listRecorder{TodoStore: s}
+	start := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 4, 7, 23, 59, 0, 0, time.UTC)
+	for _, sortKey := range []string{todo.SortDate, todo.SortName} {
+		for _, order := range []string{todo.OrderAsc, todo.OrderDesc} {
+			q := todo.GetListQuery{Start: &start, End: &end, WithRepeatSchedules: true, Sort: sortKey, Order: order}
+			all, _, _, err := todo.GetList(s, userId, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 2 plain, 2 of the repeat from 03-30 and 6 daily from 04-02
+			if len(all) != 10 {
+				t.Fatalf("%s %s: %d todos, want 10: %v", sortKey, order, len(all), describe(all))
+			}
+
+			var paged []todo.Todo
+			q.Limit = 5
+			for i := 0; i < 4; i++ {
+				recorder.queries = nil
+				page, next, invalidCursor, err := todo.GetList(recorder, userId, q)
+				if err != nil || invalidCursor {
+					t.Fatalf("GetList: invalidCursor %v, err %v", invalidCursor, err)
+				}
+				paged = append(paged, page...)
+				if stored := recorder.queries[0]; stored.Limit != q.Limit+1 || (q.Cursor != nil && stored.After == nil) {
+					t.Errorf("sort and cursor are not pushed to the store: limit %d, after %v", stored.Limit, stored.After)
+				}
+				if next == nil {
+					break
+				}
+				q.Cursor = next
+			}
+			if describe(paged) != describe(all) {
+				t.Errorf("%s %s: pages are %s, want %s", sortKey, order, describe(paged), describe(all))
+			}
+		}
+	}
+}
+
+// Records the queries of List
+type listRecorder struct {
+	todo.TodoStore
+	queries []todo.GetListQuery
+}
+
+func (r *listRecorder) List(userId uint64, q todo.GetListQuery) ([]todo.Todo, error) {
+	r.queries = append(r.queries, q)
+	return r.TodoStore.List(userId, q)
+}
+
+func describe(todos []todo.Todo) string {
+	var strs []string
+	for _, t := range todos {
+		strs = append(strs, fmt.Sprintf("%s@%s", t.Name, *t.Date))
+	}
+	return fmt.Sprint(strs)
+}
+
+func uintPtr(u uint) *uint {
+	return &u
+}
@@ -0,0 +1,260 @@
+package todo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	icalProdId = "-//flow//flow-todos//EN"
+	// Max octets of a content line before folding
+	icalLineLength = 75
+)
+
+// Export returns the todos of the user matching q as an iCalendar of RFC 5545
+func Export(s TodoStore, userId uint64, q GetListQuery, now time.Time) (ics string, err error) {
+	if q.Sort == "" {
+		q.Sort = SortDate
+	}
+	if q.Order == "" {
+		q.Order = OrderAsc
+	}
+	todos, err := s.List(userId, q)
+	if err != nil {
+		return
+	}
+	labels, err := s.ListLabels(userId)
+	if err != nil {
+		return
+	}
+	return ICalendar(todos, labels, now), nil
+}
+
+// ICalendar renders the todos as a calendar.
+// Incomplete todos with date and time are VEVENT lasting `execution_time`, others are VTODO.
+// Dates and times are floating, in the local time of the calendar.
+// The repeat of an incomplete todo is a RRULE from its date,
+// cancelled and moved occurrences are EXDATE and RDATE.
+func ICalendar(todos []Todo, labels []Label, now time.Time) string {
+	var b strings.Builder
+	w := func(name string, value string) {
+		writeContentLine(&b, name+":"+value)
+	}
+
+	w("BEGIN", "VCALENDAR")
+	w("VERSION", "2.0")
+	w("PRODID", icalProdId)
+	w("CALSCALE", "GREGORIAN")
+	w("X-WR-CALNAME", "flow todos")
+	for _, t := range todos {
+		component := "VTODO"
+		if t.Date != nil && t.Time != nil && !t.Completed {
+			component = "VEVENT"
+		}
+		writeTodo(w, t, labels, component, icalUid(t.Id), now)
+	}
+	w("END", "VCALENDAR")
+	return b.String()
+}
+
+// UID of the todo in calendars
+func icalUid(id uint64) string {
+	return fmt.Sprintf("todo-%d@flow-todos", id)
+}
+
+// Write the todo as a VTODO or VEVENT
+func writeTodo(w func(name string, value string), t Todo, labels []Label, component string, uid string, now time.Time) {
+	timed := t.Date != nil && t.Time != nil
+
+	w("BEGIN", component)
+	w("UID", uid)
+	w("DTSTAMP", now.UTC().Format("20060102T150405Z"))
+	if t.CreatedAt != nil {
+		w("CREATED", t.CreatedAt.UTC().Format("20060102T150405Z"))
+	}
+	if t.UpdatedAt != nil {
+		w("LAST-MODIFIED", t.UpdatedAt.UTC().Format("20060102T150405Z"))
+	}
+	w("SUMMARY", escapeText(t.Name))
+	if t.Description != nil {
+		w("DESCRIPTION", escapeText(*t.Description))
+	}
+	if categories := icalCategories(t, labels); len(categories) != 0 {
+		w("CATEGORIES", strings.Join(categories, ","))
+	}
+	if t.Priority != nil {
+		// 1 is the highest in both
+		w("PRIORITY", fmt.Sprint(*t.Priority*2-1))
+	}
+
+	if t.Date != nil {
+		repeating := t.Repeat != nil && !t.Completed
+		date, tm := *t.Date, t.Time
+		if repeating {
+			// The series starts on the original date of the occurrence
+			var base Todo
+			date, base = t.series()
+			if timed && base.Time != nil {
+				tm = base.Time
+			}
+		}
+		start := icalDateTime(date, tm, timed)
+		if timed {
+			w("DTSTART", start.Format("20060102T150405"))
+		} else {
+			w("DTSTART;VALUE=DATE", start.Format("20060102"))
+		}
+
+		switch {
+		case component == "VEVENT" && t.ExecutionTime != 0:
+			w("DURATION", fmt.Sprintf("PT%dM", t.ExecutionTime))
+		case component == "VTODO" && timed && t.ExecutionTime != 0:
+			w("DUE", start.Add(time.Duration(t.ExecutionTime)*time.Minute).Format("20060102T150405"))
+		case component == "VTODO" && !timed:
+			// Due by the end of the day
+			w("DUE;VALUE=DATE", start.AddDate(0, 0, 1).Format("20060102"))
+		}
+
+		if repeating {
+			writeRecurrence(w, t, timed)
+		}
+	}
+
+	switch {
+	case component == "VEVENT":
+		w("STATUS", "CONFIRMED")
+	case t.Completed:
+		w("STATUS", "COMPLETED")
+		w("PERCENT-COMPLETE", "100")
+		if t.UpdatedAt != nil {
+			w("COMPLETED", t.UpdatedAt.UTC().Format("20060102T150405Z"))
+		}
+	default:
+		w("STATUS", "NEEDS-ACTION")
+	}
+	w("END", component)
+}
+
+// RRULE of the repeat from the date of the todo, with EXDATE and RDATE of the exceptions
+func writeRecurrence(w func(name string, value string), t Todo, timed bool) {
+	rule, dtstart, invalidUnit, err := t.rule()
+	if err != nil || invalidUnit {
+		return
+	}
+	anchor, base := t.series()
+	format := func(date string, tm *string) string {
+		if tm == nil {
+			tm = base.Time
+		}
+		if timed {
+			return icalDateTime(date, tm, true).Format("20060102T150405")
+		}
+		return icalDateTime(date, nil, false).Format("20060102")
+	}
+	param := ";VALUE=DATE"
+	if timed {
+		param = ""
+	}
+
+	if rule.Count != 0 {
+		// `COUNT` and `UNTIL` are exclusive, so end at the last counted occurrence
+		it := rule.Iterator(dtstart)
+		for {
+			d, ok := it.Next()
+			if !ok {
+				break
+			}
+			last := d
+			rule.Until = &last
+		}
+		rule.Count = 0
+	}
+
+	// `UNTIL` has the value type of `DTSTART`, the end of the day for floating date-times
+	until := rule.Until
+	rule.Until = nil
+	value := rule.String()
+	if until != nil {
+		if timed {
+			value += ";UNTIL=" + until.Format("20060102") + "T235959"
+		} else {
+			value += ";UNTIL=" + until.Format("20060102")
+		}
+	}
+	w("RRULE", value)
+
+	var exdates, rdates []string
+	if anchor != *t.Date {
+		// The todo is a moved occurrence of the series
+		exdates = append(exdates, format(anchor, nil))
+		rdates = append(rdates, format(*t.Date, t.Time))
+	}
+	for _, e := range t.Repeat.Exceptions {
+		d, err := time.Parse("2006-1-2", e.Date)
+		if err != nil || e.Applied || d.Before(dtstart) {
+			continue
+		}
+		if e.Cancelled {
+			exdates = append(exdates, format(e.Date, nil))
+		} else if e.NewDate != nil {
+			exdates = append(exdates, format(e.Date, nil))
+			rdates = append(rdates, format(*e.NewDate, e.Time))
+		}
+	}
+	if len(exdates) != 0 {
+		w("EXDATE"+param, strings.Join(exdates, ","))
+	}
+	if len(rdates) != 0 {
+		w("RDATE"+param, strings.Join(rdates, ","))
+	}
+}
+
+// Floating date-time of the date and time
+func icalDateTime(date string, tm *string, timed bool) (d time.Time) {
+	d, _ = time.Parse("2006-1-2", date)
+	if timed && tm != nil {
+		if hm, err := time.Parse("15:4", *tm); err == nil {
+			d = d.Add(time.Duration(hm.Hour())*time.Hour + time.Duration(hm.Minute())*time.Minute)
+		}
+	}
+	return
+}
+
+// Project, sprint and label names of the todo
+func icalCategories(t Todo, labels []Label) (categories []string) {
+	if t.ProjectId != nil {
+		categories = append(categories, fmt.Sprintf("project-%d", *t.ProjectId))
+	}
+	if t.SprintId != nil {
+		categories = append(categories, fmt.Sprintf("sprint-%d", *t.SprintId))
+	}
+	for _, l := range labels {
+		if containsId(t.Labels, l.Id) {
+			categories = append(categories, escapeText(l.Name))
+		}
+	}
+	return
+}
+
+// Escape of TEXT values
+func escapeText(str string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(str)
+}
+
+// Write the line folded at 75 octets without splitting characters
+func writeContentLine(b *strings.Builder, line string) {
+	limit := icalLineLength
+	for len(line) > limit {
+		n := limit
+		for n > 0 && !utf8.RuneStart(line[n]) {
+			n--
+		}
+		b.WriteString(line[:n] + "\r\n ")
+		line = line[n:]
+		// The leading space of continuation lines counts
+		limit = icalLineLength - 1
+	}
+	b.WriteString(line + "\r\n")
+}
@@ -0,0 +1,493 @@
+package todo
+
+import (
+	"errors"
+	"flow-todos/rrule"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status of an imported component
+const (
+	ImportCreated = "created"
+	// Would be created, in dry run
+	ImportValid  = "valid"
+	ImportFailed = "failed"
+)
+
+// Component of an iCalendar, like VTODO
+type ICalComponent struct {
+	Name       string
+	Properties []ICalProperty
+	// Names of the sub components, like VALARM
+	Subcomponents []string
+}
+
+// Content line of an iCalendar, `NAME;PARAM=VALUE:value`
+type ICalProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+type ImportResult struct {
+	Index     int    `json:"index"`
+	Component string `json:"component"`
+	Uid       string `json:"uid,omitempty"`
+	Status    string `json:"status"`
+	// Created todo, without id in dry run
+	Todo *Todo `json:"todo,omitempty"`
+	// Lossy conversions
+	Warnings []string `json:"warnings,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Discards the todos of a dry run
+var errDryRun = errors.New("dry run")
+
+// ParseICalendar returns the VTODO and VEVENT components of the iCalendar.
+// invalid is true unless it is a VCALENDAR.
+func ParseICalendar(ics string) (components []ICalComponent, invalid bool) {
+	// Unfold lines
+	ics = strings.ReplaceAll(ics, "\r\n", "\n")
+	ics = strings.ReplaceAll(ics, "\n ", "")
+	ics = strings.ReplaceAll(ics, "\n\t", "")
+
+	var stack []string
+	var current *ICalComponent
+	calendar := false
+	for _, line := range strings.Split(ics, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		p, ok := parseContentLine(line)
+		if !ok {
+			continue
+		}
+		switch p.Name {
+		case "BEGIN":
+			name := strings.ToUpper(p.Value)
+			if len(stack) == 0 {
+				if name != "VCALENDAR" {
+					return nil, true
+				}
+				calendar = true
+			}
+			if current != nil {
+				current.Subcomponents = append(current.Subcomponents, name)
+			} else if len(stack) == 1 && (name == "VTODO" || name == "VEVENT") {
+				current = &ICalComponent{Name: name}
+			}
+			stack = append(stack, name)
+		case "END":
+			if len(stack) == 0 {
+				return nil, true
+			}
+			stack = stack[:len(stack)-1]
+			if current != nil && len(stack) == 1 {
+				components = append(components, *current)
+				current = nil
+			}
+		default:
+			// Properties of sub components are ignored
+			if current != nil && len(stack) == 2 {
+				current.Properties = append(current.Properties, p)
+			}
+		}
+	}
+	// Unterminated, or not a calendar
+	invalid = !calendar || len(stack) != 0
+	return
+}
+
+// `NAME;PARAM=VALUE;PARAM="VALUE":value`
+func parseContentLine(line string) (p ICalProperty, ok bool) {
+	p.Params = map[string]string{}
+	quoted := false
+	start := 0
+	var param string
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == ';' || r == ':':
+			part := line[start:i]
+			if p.Name == "" {
+				p.Name = strings.ToUpper(part)
+			} else if param != "" {
+				p.Params[param] = strings.Trim(part, `"`)
+				param = ""
+			}
+			start = i + 1
+			if r == ':' {
+				p.Value = line[i+1:]
+				return p, p.Name != ""
+			}
+		case r == '=' && param == "" && p.Name != "":
+			param = strings.ToUpper(line[start:i])
+			start = i + 1
+		}
+	}
+	return
+}
+
+func (c *ICalComponent) property(name string) *ICalProperty {
+	for i := range c.Properties {
+		if c.Properties[i].Name == name {
+			return &c.Properties[i]
+		}
+	}
+	return nil
+}
+
+// Unescaped value of a TEXT property
+func unescapeText(str string) string {
+	return strings.Join(splitText(str), ",")
+}
+
+// Unescaped values of a TEXT list, split on unescaped commas
+func splitText(str string) (values []string) {
+	var b strings.Builder
+	escaped := false
+	for _, r := range str {
+		switch {
+		case escaped:
+			if r == 'n' || r == 'N' {
+				b.WriteRune('\n')
+			} else {
+				b.WriteRune(r)
+			}
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',':
+			values = append(values, b.String())
+			b.Reset()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return append(values, b.String())
+}
+
+// Date-time of DTSTART, DUE, DTEND and EXDATE values as floating local time.
+// Time zones are not converted.
+func (p *ICalProperty) dateTime(value string) (t time.Time, dateOnly bool, warning string, err error) {
+	if p.Params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err = time.Parse("20060102", value)
+		return t, true, "", err
+	}
+	if strings.HasSuffix(value, "Z") {
+		warning = fmt.Sprintf("%s in UTC is imported as local time", p.Name)
+		value = strings.TrimSuffix(value, "Z")
+	} else if tzid := p.Params["TZID"]; tzid != "" {
+		warning = fmt.Sprintf("time zone `%s` of %s is ignored", tzid, p.Name)
+	}
+	t, err = time.Parse("20060102T150405", value)
+	return
+}
+
+// `P1DT2H30M` or `PT45M`, negative durations are invalid
+func parseDuration(str string) (d time.Duration, err error) {
+	s := strings.TrimPrefix(strings.ToUpper(str), "+")
+	if !strings.HasPrefix(s, "P") {
+		return 0, fmt.Errorf("invalid DURATION `%s`", str)
+	}
+	s = s[1:]
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour, 'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+	n := ""
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == 'T':
+		case c >= '0' && c <= '9':
+			n += string(c)
+		default:
+			unit, ok := units[c]
+			v, err := strconv.Atoi(n)
+			if !ok || err != nil {
+				return 0, fmt.Errorf("invalid DURATION `%s`", str)
+			}
+			d += time.Duration(v) * unit
+			n = ""
+		}
+	}
+	if n != "" {
+		return 0, fmt.Errorf("invalid DURATION `%s`", str)
+	}
+	return
+}
+
+// PostBody converts the component to a todo.
+// Categories are matched to the labels by name.
+// failure is the reason the component cannot be imported.
+func (c *ICalComponent) PostBody(labels []Label) (post PostBody, exdates []string, warnings []string, failure string) {
+	if c.property("RECURRENCE-ID") != nil {
+		failure = "overrides of occurrences (RECURRENCE-ID) are not supported"
+		return
+	}
+	if status := c.property("STATUS"); status != nil && strings.ToUpper(status.Value) == "CANCELLED" {
+		failure = "cancelled component is not imported"
+		return
+	}
+
+	summary := c.property("SUMMARY")
+	if summary == nil || strings.TrimSpace(unescapeText(summary.Value)) == "" {
+		failure = "SUMMARY required"
+		return
+	}
+	post.Name = unescapeText(summary.Value)
+	if description := c.property("DESCRIPTION"); description != nil && description.Value != "" {
+		str := unescapeText(description.Value)
+		post.Description = &str
+	}
+
+	// Start and duration
+	startProp := c.property("DTSTART")
+	var endProp *ICalProperty
+	if c.Name == "VTODO" {
+		endProp = c.property("DUE")
+		if startProp == nil {
+			startProp, endProp = endProp, nil
+		}
+	} else {
+		endProp = c.property("DTEND")
+	}
+	if startProp != nil {
+		start, dateOnly, warning, err := startProp.dateTime(startProp.Value)
+		if err != nil {
+			failure = fmt.Sprintf("invalid %s `%s`", startProp.Name, startProp.Value)
+			return
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		date := start.Format("2006-01-02")
+		post.Date = &date
+
+		if !dateOnly {
+			tm := start.Format("15:04")
+			post.Time = &tm
+
+			var duration time.Duration
+			if d := c.property("DURATION"); d != nil {
+				duration, err = parseDuration(d.Value)
+				if err != nil {
+					warnings = append(warnings, err.Error()+" is ignored")
+				}
+			} else if endProp != nil {
+				end, _, _, err := endProp.dateTime(endProp.Value)
+				if err == nil && end.After(start) {
+					duration = end.Sub(start)
+				}
+			}
+			if duration > 0 {
+				executionTime := RoundExecutionTime(duration)
+				if time.Duration(executionTime)*time.Minute != duration {
+					warnings = append(warnings, fmt.Sprintf("duration of %s is rounded to %d minutes", duration, executionTime))
+				}
+				post.ExecutionTime = &executionTime
+			}
+		}
+	}
+
+	if post.ExecutionTime == nil {
+		executionTime := uint(15)
+		post.ExecutionTime = &executionTime
+	}
+
+	// Completed
+	status := c.property("STATUS")
+	if status != nil && strings.ToUpper(status.Value) == "COMPLETED" || c.property("COMPLETED") != nil {
+		completed := true
+		post.Completed = &completed
+	}
+
+	// Priority 1 (highest) to 9
+	if p := c.property("PRIORITY"); p != nil {
+		if v, err := strconv.Atoi(p.Value); err == nil && v >= 1 && v <= 9 {
+			priority := uint((v + 1) / 2)
+			if priority > 4 {
+				priority = 4
+			}
+			post.Priority = &priority
+		}
+	}
+
+	// Labels
+	for _, p := range c.Properties {
+		if p.Name != "CATEGORIES" {
+			continue
+		}
+		for _, category := range splitText(p.Value) {
+			category = strings.TrimSpace(category)
+			if category == "" {
+				continue
+			}
+			found := false
+			for _, l := range labels {
+				if strings.EqualFold(l.Name, category) {
+					found = true
+					if !containsId(post.Labels, l.Id) {
+						post.Labels = append(post.Labels, l.Id)
+					}
+				}
+			}
+			if !found {
+				warnings = append(warnings, fmt.Sprintf("category `%s` has no label of the name and is ignored", category))
+			}
+		}
+	}
+
+	// Repeat
+	if p := c.property("RRULE"); p != nil {
+		repeat, warning := icalRepeat(p.Value, post.Date)
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		post.Repeat = repeat
+	}
+	if post.Repeat != nil {
+		for _, p := range c.Properties {
+			if p.Name != "EXDATE" {
+				continue
+			}
+			for _, v := range strings.Split(p.Value, ",") {
+				d, _, _, err := p.dateTime(v)
+				if err != nil {
+					warnings = append(warnings, fmt.Sprintf("invalid EXDATE `%s` is ignored", v))
+					continue
+				}
+				exdates = append(exdates, d.Format("2006-01-02"))
+			}
+		}
+		if c.property("RDATE") != nil {
+			warnings = append(warnings, "RDATE is not supported and is ignored")
+		}
+	}
+
+	for _, name := range []string{"LOCATION", "URL", "ATTENDEE", "ORGANIZER", "ATTACH"} {
+		if c.property(name) != nil {
+			warnings = append(warnings, name+" is not supported and is ignored")
+		}
+	}
+	for _, name := range c.Subcomponents {
+		warnings = append(warnings, name+" is not supported and is ignored")
+	}
+	return
+}
+
+// Minutes of the duration rounded to the nearest multiple of 15, at least 15
+func RoundExecutionTime(d time.Duration) uint {
+	minutes := uint((d + 7*time.Minute + 30*time.Second) / (15 * time.Minute) * 15)
+	if minutes < 15 {
+		minutes = 15
+	}
+	return minutes
+}
+
+// Repeat of the RRULE, nil with a warning if it is not representable
+func icalRepeat(value string, date *string) (repeat *Repeat, warning string) {
+	if date == nil {
+		return nil, "RRULE without DTSTART is ignored"
+	}
+	rule, err := rrule.Parse(value)
+	if err != nil {
+		return nil, fmt.Sprintf("RRULE `%s` is not supported (%s) and is ignored", value, err)
+	}
+	repeat = &Repeat{}
+	if rule.Until != nil {
+		until := rule.Until.Format("2006-01-02")
+		repeat.Until = &until
+		rule.Until = nil
+	}
+	str := rule.String()
+	repeat.RRule = &str
+	return
+}
+
+// Import creates the todos of the components in a transaction.
+// validate checks the converted body like the body of `POST /`.
+// In dry run nothing is created.
+func Import(s TodoStore, userId uint64, components []ICalComponent, dryRun bool, validate func(post PostBody) error) (results []ImportResult, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		results = nil
+		labels, err := s.ListLabels(userId)
+		if err != nil {
+			return
+		}
+		for i, c := range components {
+			r := ImportResult{Index: i, Component: c.Name, Status: ImportFailed}
+			if uid := c.property("UID"); uid != nil {
+				r.Uid = uid.Value
+			}
+			var post PostBody
+			var exdates []string
+			post, exdates, r.Warnings, r.Error = c.PostBody(labels)
+			if r.Error == "" {
+				if err := validate(post); err != nil {
+					r.Error = err.Error()
+				}
+			}
+			if r.Error == "" {
+				var t Todo
+				t, r.Error, err = importTodo(s, userId, post, exdates)
+				if err != nil {
+					return
+				}
+				if r.Error == "" {
+					r.Status = ImportCreated
+					if dryRun {
+						r.Status = ImportValid
+						t.Id = 0
+					}
+					r.Todo = &t
+				}
+			}
+			results = append(results, r)
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return
+	})
+	if err == errDryRun {
+		err = nil
+	}
+	return
+}
+
+func importTodo(s TodoStore, userId uint64, post PostBody, exdates []string) (t Todo, failure string, err error) {
+	t, dateNotFound, dateOverUntil, noDaysWithWeekly, err := Post(s, userId, post)
+	if err != nil {
+		return
+	}
+	switch {
+	case dateNotFound:
+		return t, "`date` required to set `repeat`", nil
+	case dateOverUntil:
+		return t, "`date` must until `repeat.until`", nil
+	case noDaysWithWeekly:
+		return t, "`repeat.days` required with `repeat.unit: \"week\"`", nil
+	}
+
+	// Cancelled occurrences
+	if t.Repeat != nil && len(exdates) != 0 {
+		for _, date := range exdates {
+			err = s.PutException(userId, t.Repeat.Id, RepeatException{Date: date, Cancelled: true})
+			if err != nil {
+				return
+			}
+		}
+		t, _, err = s.Get(userId, t.Id)
+		if err != nil {
+			return
+		}
+		t.fillRRule()
+		err = recordEvent(s, userId, EventUpdated, t, nil)
+	}
+	return
+}
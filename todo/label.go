@@ -0,0 +1,127 @@
+package todo
+
+import (
+	"sort"
+	"strings"
+)
+
+// Label of todos across projects and sprints, like `@errand`
+type Label struct {
+	Id    uint64 `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type LabelPostBody struct {
+	Name  string `json:"name" validate:"required,max=255"`
+	Color string `json:"color" validate:"required,hexcolor"`
+}
+
+type LabelPatchBody struct {
+	Name  *string `json:"name" validate:"omitempty,gte=1,max=255"`
+	Color *string `json:"color" validate:"omitempty,hexcolor"`
+}
+
+// Distinct ids in ascending order
+func normalizeIds(ids []uint64) (normalized []uint64) {
+	normalized = []uint64{}
+	for _, id := range ids {
+		if !containsId(normalized, id) {
+			normalized = append(normalized, id)
+		}
+	}
+	sort.Slice(normalized, func(i, j int) bool { return normalized[i] < normalized[j] })
+	return
+}
+
+func containsId(ids []uint64, id uint64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckLabels returns the first id of `ids` which is not a label of the user
+func CheckLabels(s TodoStore, userId uint64, ids []uint64) (notFound *uint64, err error) {
+	labels, err := s.ListLabels(userId)
+	if err != nil {
+		return
+	}
+	for _, id := range ids {
+		found := false
+		for _, l := range labels {
+			if l.Id == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			id := id
+			return &id, nil
+		}
+	}
+	return
+}
+
+// Another label of the user has the name, case insensitive
+func labelNameConflicts(s TodoStore, userId uint64, id uint64, name string) (conflict bool, err error) {
+	labels, err := s.ListLabels(userId)
+	if err != nil {
+		return
+	}
+	for _, l := range labels {
+		if l.Id != id && strings.EqualFold(l.Name, name) {
+			return true, nil
+		}
+	}
+	return
+}
+
+func GetLabels(s TodoStore, userId uint64) (labels []Label, err error) {
+	return s.ListLabels(userId)
+}
+
+func GetLabel(s TodoStore, userId uint64, id uint64) (l Label, notFound bool, err error) {
+	return s.GetLabel(userId, id)
+}
+
+func PostLabel(s TodoStore, userId uint64, post LabelPostBody) (l Label, nameConflict bool, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		nameConflict, err = labelNameConflicts(s, userId, 0, post.Name)
+		if err != nil || nameConflict {
+			return
+		}
+		l, err = s.InsertLabel(userId, Label{Name: post.Name, Color: strings.ToLower(post.Color)})
+		return
+	})
+	return
+}
+
+func PatchLabel(s TodoStore, userId uint64, id uint64, patch LabelPatchBody) (l Label, notFound bool, nameConflict bool, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		l, notFound, err = s.GetLabel(userId, id)
+		if err != nil || notFound {
+			return
+		}
+		if patch.Name != nil {
+			nameConflict, err = labelNameConflicts(s, userId, id, *patch.Name)
+			if err != nil || nameConflict {
+				return
+			}
+			l.Name = *patch.Name
+		}
+		if patch.Color != nil {
+			l.Color = strings.ToLower(*patch.Color)
+		}
+		l, notFound, err = s.UpdateLabel(userId, l)
+		return
+	})
+	return
+}
+
+// DeleteLabel deletes the label and removes it from todos
+func DeleteLabel(s TodoStore, userId uint64, id uint64) (notFound bool, err error) {
+	return s.DeleteLabel(userId, id)
+}
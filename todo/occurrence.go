@@ -0,0 +1,113 @@
+package todo
+
+import (
+	"time"
+)
+
+type OccurrencePatchBody struct {
+	Cancelled     *bool                   `json:"cancelled" validate:"omitempty"`
+	Date          PatchNullJSONDateString `json:"date" validate:"omitempty"`
+	Time          PatchNullJSONTimeString `json:"time" validate:"omitempty"`
+	Name          PatchNullJSONString     `json:"name" validate:"omitempty"`
+	ExecutionTime PatchNullExecutionTime  `json:"execution_time" validate:"omitempty"`
+}
+
+// Get the todo and check `date` is a future occurrence of its repeat
+func getOccurrence(s TodoStore, userId uint64, id uint64, date time.Time) (t Todo, notFound bool, repeatNotFound bool, notOccurrence bool, err error) {
+	t, notFound, err = s.Get(userId, id)
+	if err != nil {
+		return
+	}
+	if notFound {
+		return
+	}
+	if t.Repeat == nil || t.Date == nil {
+		repeatNotFound = true
+		return
+	}
+
+	rule, dtstart, invalidUnit, err := t.rule()
+	if err != nil {
+		return
+	}
+	if invalidUnit || !date.After(dtstart) || len(rule.Between(dtstart, date, date)) == 0 {
+		// The current occurrence is updated by patching the todo
+		notOccurrence = true
+	}
+	return
+}
+
+func PatchOccurrence(s TodoStore, userId uint64, id uint64, date time.Time, patch OccurrencePatchBody) (e RepeatException, notFound bool, repeatNotFound bool, notOccurrence bool, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		var t Todo
+		t, notFound, repeatNotFound, notOccurrence, err = getOccurrence(s, userId, id, date)
+		if err != nil || notFound || repeatNotFound || notOccurrence {
+			return
+		}
+
+		dateStr := date.Format("2006-01-02")
+		e = RepeatException{Date: dateStr}
+		if old := t.Repeat.exception(dateStr); old != nil {
+			e = *old
+		}
+
+		if patch.Cancelled != nil {
+			e.Cancelled = *patch.Cancelled
+		}
+		if patch.Date.String != nil {
+			e.NewDate = nil
+			if *patch.Date.String != nil {
+				var newDate time.Time
+				newDate, err = time.Parse("2006-1-2", **patch.Date.String)
+				if err != nil {
+					return
+				}
+				newDateStr := newDate.Format("2006-01-02")
+				e.NewDate = &newDateStr
+			}
+		}
+		if patch.Time.String != nil {
+			e.Time = *patch.Time.String
+		}
+		if patch.Name.String != nil {
+			e.Name = *patch.Name.String
+		}
+		if patch.ExecutionTime.UInt != nil {
+			e.ExecutionTime = *patch.ExecutionTime.UInt
+		}
+
+		err = s.PutException(userId, t.Repeat.Id, e)
+		if err != nil {
+			return
+		}
+		return recordEventId(s, userId, EventUpdated, id)
+	})
+	return
+}
+
+func DeleteOccurrence(s TodoStore, userId uint64, id uint64, date time.Time) (notFound bool, repeatNotFound bool, exceptionNotFound bool, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		var t Todo
+		t, notFound, err = s.Get(userId, id)
+		if err != nil || notFound {
+			return
+		}
+		if t.Repeat == nil {
+			repeatNotFound = true
+			return
+		}
+		dateStr := date.Format("2006-01-02")
+		if e := t.Repeat.exception(dateStr); e == nil || e.Applied {
+			// The exception applied to the todo is kept to restore the series
+			exceptionNotFound = true
+			return
+		}
+
+		exceptionNotFound, err = s.DeleteException(userId, t.Repeat.Id, dateStr)
+		if err != nil || exceptionNotFound {
+			return
+		}
+		return recordEventId(s, userId, EventUpdated, id)
+	})
+	return
+}
@@ -0,0 +1,263 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Kinds of operations recorded to be undone
+const (
+	OperationComplete = "complete"
+	OperationSkip     = "skip"
+)
+
+// Operation on a todo recorded to restore the previous state
+type Operation struct {
+	Id     uint64
+	TodoId uint64
+	Kind   string
+	// Todos before and after the operation, encoded by this package
+	Data []byte
+}
+
+type operationData struct {
+	Before snapshot `json:"before"`
+	After  snapshot `json:"after"`
+	// Todo of the next occurrence inserted by `Complete`
+	Successor *snapshot `json:"successor,omitempty"`
+}
+
+// Todo with the fields hidden from the API
+type snapshot struct {
+	Todo
+	RepeatId   uint64              `json:"repeat_id,omitempty"`
+	Done       uint                `json:"done,omitempty"`
+	Exceptions []snapshotException `json:"exceptions,omitempty"`
+}
+
+type snapshotException struct {
+	RepeatException
+	Applied             bool    `json:"applied,omitempty"`
+	SeriesTime          *string `json:"series_time,omitempty"`
+	SeriesName          *string `json:"series_name,omitempty"`
+	SeriesExecutionTime *uint   `json:"series_execution_time,omitempty"`
+}
+
+func newSnapshot(t Todo) (s snapshot) {
+	s.Todo = t
+	// Changed by other todos
+	s.Todo.Blocks = nil
+	if t.Repeat == nil {
+		return
+	}
+	s.RepeatId = t.Repeat.Id
+	s.Done = t.Repeat.Done
+	for _, e := range t.Repeat.Exceptions {
+		s.Exceptions = append(s.Exceptions, snapshotException{e, e.Applied, e.SeriesTime, e.SeriesName, e.SeriesExecutionTime})
+	}
+	return
+}
+
+func (s snapshot) todo() (t Todo) {
+	t = s.Todo
+	if t.Repeat == nil {
+		return
+	}
+	repeat := *t.Repeat
+	repeat.Id = s.RepeatId
+	repeat.Done = s.Done
+	repeat.Exceptions = nil
+	for _, e := range s.Exceptions {
+		exception := e.RepeatException
+		exception.Applied = e.Applied
+		exception.SeriesTime = e.SeriesTime
+		exception.SeriesName = e.SeriesName
+		exception.SeriesExecutionTime = e.SeriesExecutionTime
+		repeat.Exceptions = append(repeat.Exceptions, exception)
+	}
+	t.Repeat = &repeat
+	return
+}
+
+// Todo differs from the snapshot
+func (s snapshot) changed(t Todo) (changed bool, err error) {
+	a, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	b, err := json.Marshal(newSnapshot(t))
+	if err != nil {
+		return
+	}
+	return !bytes.Equal(a, b), nil
+}
+
+// Record the operation on `before.Id`, states after the operation are read from the store
+func recordOperation(s TodoStore, userId uint64, kind string, before Todo, successorId uint64) (err error) {
+	after, _, err := s.Get(userId, before.Id)
+	if err != nil {
+		return
+	}
+	data := operationData{Before: newSnapshot(before), After: newSnapshot(after)}
+	if successorId != 0 {
+		var successor Todo
+		successor, _, err = s.Get(userId, successorId)
+		if err != nil {
+			return
+		}
+		snapshot := newSnapshot(successor)
+		data.Successor = &snapshot
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	return s.InsertOperation(userId, Operation{TodoId: before.Id, Kind: kind, Data: encoded})
+}
+
+// Restore exceptions of the repeat of t
+func restoreExceptions(s TodoStore, userId uint64, t Todo, exceptions []RepeatException) (err error) {
+	keep := map[string]bool{}
+	for _, e := range exceptions {
+		keep[e.Date] = true
+		err = s.PutException(userId, t.Repeat.Id, e)
+		if err != nil {
+			return
+		}
+	}
+	for _, e := range t.Repeat.Exceptions {
+		if keep[e.Date] {
+			continue
+		}
+		_, err = s.DeleteException(userId, t.Repeat.Id, e.Date)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Undo the last operation of `kind` on the todo.
+// conflict is true if the todo was changed after the operation.
+// The successor created by the operation is deleted if untouched, otherwise it is kept detached from the repeat.
+func undo(s TodoStore, userId uint64, id uint64, kind string) (t Todo, notFound bool, operationNotFound bool, conflict bool, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		var current Todo
+		current, notFound, err = s.Get(userId, id)
+		if err != nil || notFound {
+			return
+		}
+		var op Operation
+		op, operationNotFound, err = s.LastOperation(userId, id, kind)
+		if err != nil || operationNotFound {
+			return
+		}
+		var data operationData
+		err = json.Unmarshal(op.Data, &data)
+		if err != nil {
+			return
+		}
+
+		conflict, err = data.After.changed(current)
+		if err != nil || conflict {
+			return
+		}
+		var successor Todo
+		successorNotFound := true
+		successorChanged := false
+		if data.Successor != nil {
+			successor, successorNotFound, err = s.Get(userId, data.Successor.Id)
+			if err != nil {
+				return
+			}
+			if !successorNotFound {
+				successorChanged, err = data.Successor.changed(successor)
+				if err != nil {
+					return
+				}
+			}
+		}
+
+		before := data.Before.todo()
+		if before.Repeat != nil && data.Successor != nil && successorNotFound {
+			// Repeat model was deleted with the successor
+			before.Repeat.Id = 0
+		}
+		if before.Repeat != nil && !successorNotFound && successor.Repeat != nil && successor.Repeat.Id != before.Repeat.Id {
+			// The successor was split to a new repeat, the old one keeps ending before it
+			original := data.Successor.todo()
+			anchor, _ := original.series()
+			*before.Repeat, err = endRepeatBefore(*before.Repeat, anchor)
+			if err != nil {
+				return
+			}
+		}
+		t, err = s.Update(userId, before)
+		if err != nil {
+			return
+		}
+
+		if !successorNotFound && successorChanged {
+			// Keep the changed successor, detached from the repeat model reattached to the todo
+			if successor.Repeat != nil && t.Repeat != nil && successor.Repeat.Id == t.Repeat.Id {
+				successor.Repeat = nil
+				successor, err = s.Update(userId, successor)
+				if err != nil {
+					return
+				}
+				err = recordEvent(s, userId, EventUpdated, successor, nil)
+				if err != nil {
+					return
+				}
+			}
+		} else if !successorNotFound {
+			// Detach the successor from the repeat model reattached to the todo, and delete it
+			successor.Repeat = nil
+			_, err = s.Update(userId, successor)
+			if err != nil {
+				return
+			}
+			_, err = s.Delete(userId, successor.Id)
+			if err != nil {
+				return
+			}
+			successor.fillRRule()
+			err = recordEvent(s, userId, EventDeleted, successor, nil)
+			if err != nil {
+				return
+			}
+		}
+
+		if before.Repeat != nil {
+			t, _, err = s.Get(userId, t.Id)
+			if err != nil {
+				return
+			}
+			err = restoreExceptions(s, userId, t, before.Repeat.Exceptions)
+			if err != nil {
+				return
+			}
+		}
+
+		err = s.DeleteOperation(userId, op.Id)
+		if err != nil {
+			return
+		}
+		t, _, err = s.Get(userId, t.Id)
+		if err != nil {
+			return
+		}
+		t.fillRRule()
+		return recordEvent(s, userId, EventUpdated, t, nil)
+	})
+	return
+}
+
+func Uncomplete(s TodoStore, userId uint64, id uint64) (t Todo, notFound bool, operationNotFound bool, conflict bool, err error) {
+	return undo(s, userId, id, OperationComplete)
+}
+
+func Unskip(s TodoStore, userId uint64, id uint64) (t Todo, notFound bool, operationNotFound bool, conflict bool, err error) {
+	return undo(s, userId, id, OperationSkip)
+}
@@ -0,0 +1,47 @@
+package todo_test
+
+import (
+	"flow-todos/memory"
+	"flow-todos/todo"
+	"testing"
+)
+
+func TestUncomplete(t *testing.T) {
+	s := memory.NewTodoStore()
+	userId := newUserId()
+
+	for _, touched := range []bool{false, true} {
+		first := insertTodo(t, s, userId, todo.Todo{Name: "a", Date: stringPtr("2026-01-01"), Repeat: &todo.Repeat{Unit: "day", From: todo.RepeatFromSchedule}})
+		_, successor, _, _, _, _, err := todo.Complete(s, userId, first.Id, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if touched {
+			name := "changed"
+			_, _, _, _, _, _, err = todo.Patch(s, userId, successor.Id, todo.PatchBody{Name: &name}, todo.PatchScopeAll)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got, notFound, operationNotFound, conflict, err := todo.Uncomplete(s, userId, first.Id)
+		if err != nil || notFound || operationNotFound || conflict {
+			t.Fatalf("Uncomplete: notFound %v, operationNotFound %v, conflict %v, err %v", notFound, operationNotFound, conflict, err)
+		}
+		if got.Completed || got.Repeat == nil || got.Repeat.Id != first.Repeat.Id {
+			t.Errorf("todo is not restored with its repeat: %+v", got)
+		}
+
+		kept, notFound := getTodo(t, s, userId, successor.Id)
+		if !touched {
+			if !notFound {
+				t.Error("untouched successor is not deleted")
+			}
+			continue
+		}
+		// The changed successor is kept out of the restored repeat
+		if notFound || kept.Name != "changed" || kept.Repeat != nil {
+			t.Errorf("changed successor is not kept detached: notFound %v, %+v", notFound, kept)
+		}
+	}
+}
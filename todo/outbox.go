@@ -0,0 +1,58 @@
+package todo
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Events of todos
+const (
+	EventCreated   = "todo.created"
+	EventUpdated   = "todo.updated"
+	EventCompleted = "todo.completed"
+	EventSkipped   = "todo.skipped"
+	EventDeleted   = "todo.deleted"
+)
+
+// OutboxEvent is written in the transaction of the change and published after the commit,
+// so an event is never lost or published for a discarded change.
+type OutboxEvent struct {
+	Id     uint64
+	UserId uint64
+	Type   string
+	// JSON of `EventData`
+	Data      []byte
+	CreatedAt time.Time
+	// Failed attempts to publish
+	Attempts      uint
+	LastError     *string
+	NextAttemptAt *time.Time
+	// Set when the attempts are exhausted, the event is not retried
+	DeadAt      *time.Time
+	PublishedAt *time.Time
+}
+
+// EventData is the data of the events of todos
+type EventData struct {
+	Todo Todo `json:"todo"`
+	// Successor of a completed repeating todo
+	Next *Todo `json:"next,omitempty"`
+}
+
+// Writes the event to the outbox, s must be the transaction of the change
+func recordEvent(s TodoStore, userId uint64, event string, t Todo, next *Todo) (err error) {
+	data, err := json.Marshal(EventData{Todo: t, Next: next})
+	if err != nil {
+		return
+	}
+	return s.InsertOutboxEvent(OutboxEvent{UserId: userId, Type: event, Data: data})
+}
+
+// Writes the event with the todo as stored
+func recordEventId(s TodoStore, userId uint64, event string, id uint64) (err error) {
+	t, notFound, err := Get(s, userId, id)
+	if err != nil || notFound {
+		return
+	}
+	return recordEvent(s, userId, event, t, nil)
+}
@@ -1,10 +1,8 @@
 package todo
 
 import (
-	"database/sql"
 	"encoding/json"
-	"flow-todos/mysql"
-	"strings"
+	"reflect"
 	"time"
 )
 
@@ -17,15 +15,23 @@ type PatchBody struct {
 	SprintId      PatchNullJSONUint64     `json:"sprint_id" validate:"omitempty"`
 	ProjectId     PatchNullJSONUint64     `json:"project_id" validate:"omitempty"`
 	Completed     *bool                   `json:"completed" validate:"omitempty"`
+	Priority      PatchNullPriority       `json:"priority" validate:"omitempty"`
 	Repeat        PatchNullJSONRepeat     `json:"repeat" validate:"omitempty"`
+	Labels        *[]uint64               `json:"labels" validate:"omitempty,dive,gte=1"`
+	BlockedBy     *[]uint64               `json:"blocked_by" validate:"omitempty,dive,gte=1"`
 }
 
 type PatchRepeatBody struct {
-	Until      PatchNullJSONDateString `json:"until" validate:"omitempty"`
-	Unit       *string                 `json:"unit" validate:"omitempty,oneof=day week month"`
-	EveryOther PatchNullUint           `json:"every_other" validate:"omitempty"`
-	Date       PatchNullDayOfMonth     `json:"date" validate:"omitempty"`
-	Days       PatchNullSliceRepeatDay `json:"days" validate:"omitempty"`
+	Until      PatchNullJSONDateString  `json:"until" validate:"omitempty"`
+	Unit       *string                  `json:"unit" validate:"omitempty,oneof=day week month year"`
+	EveryOther PatchNullUint            `json:"every_other" validate:"omitempty,gte=1"`
+	Date       PatchNullDayOfMonth      `json:"date" validate:"omitempty"`
+	Month      PatchNullMonth           `json:"month" validate:"omitempty"`
+	LeapDay    *string                  `json:"leap_day" validate:"omitempty,oneof=feb28 mar1"`
+	Days       PatchNullSliceRepeatDay  `json:"days" validate:"omitempty"`
+	RRule      PatchNullJSONRRuleString `json:"rrule" validate:"omitempty"`
+	Count      PatchNullUint            `json:"count" validate:"omitempty,gte=1,lte=1000"`
+	From       *string                  `json:"from" validate:"omitempty,oneof=schedule completion"`
 }
 
 type PatchNullJSONString struct {
@@ -40,14 +46,39 @@ type PatchNullJSONTimeString struct {
 	String **string `validate:"omitempty,H:M"`
 }
 
+type PatchNullJSONRRuleString struct {
+	String **string `validate:"omitempty,rrule"`
+}
+
+// PatchNullUint is validated by the tags of the field, with `PatchNullUintValue`
 type PatchNullUint struct {
-	UInt **uint `validate:"omitempty,gte=1"`
+	UInt **uint
+}
+
+// PatchNullUintValue returns the value of `PatchNullUint` to validate, for `RegisterCustomTypeFunc`
+func PatchNullUintValue(field reflect.Value) interface{} {
+	if p, ok := field.Interface().(PatchNullUint); ok && p.UInt != nil {
+		return *p.UInt
+	}
+	return nil
 }
 
 type PatchNullDayOfMonth struct {
 	UInt **uint `validate:"omitempty,gte=1,lte=31"`
 }
 
+type PatchNullMonth struct {
+	UInt **uint `validate:"omitempty,gte=1,lte=12"`
+}
+
+type PatchNullExecutionTime struct {
+	UInt **uint `validate:"omitempty,step15,gte=15"`
+}
+
+type PatchNullPriority struct {
+	UInt **uint `validate:"omitempty,gte=1,lte=4"`
+}
+
 type PatchNullJSONUint64 struct {
 	UInt64 **uint64 `validate:"omitempty,gte=1"`
 }
@@ -120,6 +151,26 @@ func (p *PatchNullJSONTimeString) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func (p *PatchNullJSONRRuleString) UnmarshalJSON(data []byte) error {
+	// If this method was called, the value was set.
+	var valueP *string = nil
+	if string(data) == "null" {
+		// key exists and value is null
+		p.String = &valueP
+		return nil
+	}
+
+	var tmp string
+	tmpP := &tmp
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		// invalid value type
+		return err
+	}
+	// valid value
+	p.String = &tmpP
+	return nil
+}
+
 func (p *PatchNullUint) UnmarshalJSON(data []byte) error {
 	// If this method was called, the value was set.
 	var valueP *uint = nil
@@ -160,6 +211,66 @@ func (p *PatchNullDayOfMonth) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func (p *PatchNullMonth) UnmarshalJSON(data []byte) error {
+	// If this method was called, the value was set.
+	var valueP *uint = nil
+	if string(data) == "null" {
+		// key exists and value is null
+		p.UInt = &valueP
+		return nil
+	}
+
+	var tmp uint
+	tmpP := &tmp
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		// invalid value type
+		return err
+	}
+	// valid value
+	p.UInt = &tmpP
+	return nil
+}
+
+func (p *PatchNullExecutionTime) UnmarshalJSON(data []byte) error {
+	// If this method was called, the value was set.
+	var valueP *uint = nil
+	if string(data) == "null" {
+		// key exists and value is null
+		p.UInt = &valueP
+		return nil
+	}
+
+	var tmp uint
+	tmpP := &tmp
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		// invalid value type
+		return err
+	}
+	// valid value
+	p.UInt = &tmpP
+	return nil
+}
+
+func (p *PatchNullPriority) UnmarshalJSON(data []byte) error {
+	// If this method was called, the value was set.
+	var valueP *uint = nil
+	if string(data) == "null" {
+		// key exists and value is null
+		p.UInt = &valueP
+		return nil
+	}
+
+	var tmp uint
+	tmpP := &tmp
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		// invalid value type
+		return err
+	}
+	// valid value
+	p.UInt = &tmpP
+	return nil
+}
+
 func (p *PatchNullJSONUint64) UnmarshalJSON(data []byte) error {
 	// If this method was called, the value was set.
 	var valueP *uint64 = nil
@@ -220,384 +331,290 @@ func (p *PatchNullJSONRepeat) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func Patch(userId uint64, id uint64, new PatchBody) (t Todo, notFound bool, dateNotFound bool, dateOverUntil bool, noDaysWithWeekly bool, err error) {
-	// Get old
-	t, notFound, err = Get(userId, id)
-	if err != nil {
-		return
-	}
-	if notFound {
-		return
-	}
+// Scope of `Patch` on repeating todos
+const (
+	// Update the repeat, including the occurrences to come
+	PatchScopeAll = "all"
+	// Detach the todo from the repeat, the repeat continues from the next occurrence
+	PatchScopeThis = "this"
+	// End the repeat before the todo, and start a new repeat from the todo
+	PatchScopeThisAndFollowing = "this_and_following"
+)
 
-	// Open connection
-	db, err := mysql.Open()
-	if err != nil {
-		return
-	}
-	defer db.Close()
+func Patch(s TodoStore, userId uint64, id uint64, new PatchBody, scope string) (t Todo, notFound bool, dateNotFound bool, dateOverUntil bool, noDaysWithWeekly bool, repeatWithScopeThis bool, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		// Get old
+		t, notFound, err = s.Get(userId, id)
+		if err != nil {
+			return
+		}
+		if notFound {
+			return
+		}
+		updated := t
 
-	tx, err := db.Begin()
-	if err != nil {
-		return
-	}
-	updated := t
-
-	// Update repeat
-	var idRepeatModel **uint64 = nil
-	if new.Repeat.Repeat != nil {
-		if t.Date == nil && (new.Date.String == nil || *new.Date.String == nil) ||
-			new.Date.String != nil && *new.Date.String == nil {
-			// Update repeat, but date not found
-			dateNotFound = true
+		if t.Repeat != nil && scope == PatchScopeThis && new.Repeat.Repeat != nil {
+			// The repeat cannot be updated for a single occurrence
+			repeatWithScopeThis = true
 			return
 		}
-		if (*new.Repeat.Repeat).Until.String != nil && *(*new.Repeat.Repeat).Until.String != nil {
-			var date, until time.Time
-			if new.Date.String != nil && *new.Date.String != nil {
-				date, err = time.Parse("2006-01-02", **new.Date.String)
+
+		// Update repeat
+		if new.Repeat.Repeat != nil && *new.Repeat.Repeat == nil {
+			// Remove repeat
+			updated.Repeat = nil
+		} else if new.Repeat.Repeat != nil {
+			newRepeat := *new.Repeat.Repeat
+			if t.Date == nil && (new.Date.String == nil || *new.Date.String == nil) ||
+				new.Date.String != nil && *new.Date.String == nil {
+				// Update repeat, but date not found
+				dateNotFound = true
+				return
+			}
+			var date time.Time
+			if new.Date.String != nil {
+				date, err = time.Parse("2006-1-2", **new.Date.String)
 			} else {
-				date, err = time.Parse("2006-01-02", *t.Date)
+				date, err = time.Parse("2006-1-2", *t.Date)
 			}
 			if err != nil {
 				return
 			}
-			until, err = time.Parse("2006-01-02", **(*new.Repeat.Repeat).Until.String)
-			if err != nil {
-				return
+
+			repeat := Repeat{Unit: "day"}
+			if t.Repeat != nil {
+				// Repeat alredy exists
+				repeat = *t.Repeat
 			}
-			if date.After(until) {
-				dateOverUntil = true
-				return
+			if newRepeat.Until.String != nil {
+				repeat.Until = *newRepeat.Until.String
+			}
+			if newRepeat.Count.UInt != nil {
+				repeat.Count = *newRepeat.Count.UInt
+			}
+			if newRepeat.From != nil {
+				repeat.From = *newRepeat.From
+			}
+			if repeat.From == "" {
+				repeat.From = RepeatFromSchedule
+			}
+			if newRepeat.RRule.String != nil && *newRepeat.RRule.String != nil {
+				// Repeat by `repeat.rrule` only
+				repeat.RRule = *newRepeat.RRule.String
+				repeat.Unit = ""
+				repeat.EveryOther = nil
+				repeat.Date = nil
+				repeat.Days = nil
+				repeat.Month = nil
+				repeat.LeapDay = nil
+			} else if newRepeat.RRule.String != nil || repeat.Unit == "" &&
+				(newRepeat.Unit != nil || newRepeat.EveryOther.UInt != nil || newRepeat.Date.UInt != nil || newRepeat.Days.Slice != nil ||
+					newRepeat.Month.UInt != nil || newRepeat.LeapDay != nil) {
+				// Repeat by unit
+				repeat.RRule = nil
+				if repeat.Unit == "" {
+					repeat.Unit = "day"
+				}
+			}
+			if repeat.Unit != "" {
+				if newRepeat.Unit != nil {
+					repeat.Unit = *newRepeat.Unit
+				}
+				if newRepeat.EveryOther.UInt != nil {
+					repeat.EveryOther = *newRepeat.EveryOther.UInt
+				}
+				if newRepeat.Date.UInt != nil {
+					repeat.Date = *newRepeat.Date.UInt
+				}
+				if newRepeat.Days.Slice != nil {
+					if *newRepeat.Days.Slice != nil {
+						repeat.Days = **newRepeat.Days.Slice
+					} else {
+						repeat.Days = nil
+					}
+				}
+				if newRepeat.Month.UInt != nil {
+					repeat.Month = *newRepeat.Month.UInt
+				}
+				if newRepeat.LeapDay != nil {
+					repeat.LeapDay = newRepeat.LeapDay
+				}
 			}
-		}
 
-		if t.Repeat != nil {
-			// Repeat alredy exists
+			// Yearly
+			if repeat.Unit == "year" {
+				if repeat.Month == nil {
+					tmpMonth := uint(date.Month())
+					repeat.Month = &tmpMonth
+				}
+				if repeat.Date == nil {
+					tmpDay := uint(date.Day())
+					repeat.Date = &tmpDay
+				}
+				if repeat.LeapDay == nil {
+					leapDay := LeapDayFeb28
+					repeat.LeapDay = &leapDay
+				}
+			} else {
+				repeat.Month = nil
+				repeat.LeapDay = nil
+			}
 
 			// Repeat days
-			if (*new.Repeat.Repeat).Unit == nil && t.Repeat.Unit == "week" ||
-				(*new.Repeat.Repeat).Unit != nil && *(*new.Repeat.Repeat).Unit == "week" {
-				// Weekly
-				if (*new.Repeat.Repeat).Days.Slice != nil && (*(*new.Repeat.Repeat).Days.Slice == nil || len(**(*new.Repeat.Repeat).Days.Slice) == 0) ||
-					(*new.Repeat.Repeat).Days.Slice == nil && len(t.Repeat.Days) == 0 {
+			if repeat.Unit == "week" {
+				if len(repeat.Days) == 0 {
 					// Update `repeat.unit` to week, but `repeat.days` is null or update to null
 					noDaysWithWeekly = true
 					return
 				}
+			} else {
+				repeat.Days = nil
+			}
 
-				if (*new.Repeat.Repeat).Days.Slice != nil && *(*new.Repeat.Repeat).Days.Slice != nil {
-					// Delete repeat days
-					var stmtDeleteRepeatDays *sql.Stmt
-					stmtDeleteRepeatDays, err = tx.Prepare("DELETE FROM repeat_days WHERE repeat_model_id = (SELECT repeat_model_id FROM todos WHERE user_id = ? AND id = ?)")
-					if err != nil {
-						return
-					}
-					defer stmtDeleteRepeatDays.Close()
-					_, err = stmtDeleteRepeatDays.Exec(userId, id)
-					if err != nil {
-						return
-					}
-
-					// Insert repeat days
-					queryStrRepeatDays := "INSERT INTO repeat_days (repeat_model_id, day, time)"
-					var queryParams []interface{}
-					for _, day := range **(*new.Repeat.Repeat).Days.Slice {
-						queryStrRepeatDays += " SELECT repeat_model_id, ?, ? FROM todos WHERE id = ? UNION"
-						queryParams = append(queryParams, day.Day, day.Time, id)
-					}
-					// Trim trailing ` UNION`
-					queryStrRepeatDays = queryStrRepeatDays[:len(queryStrRepeatDays)-6]
-					var stmtRepeatDays *sql.Stmt
-					stmtRepeatDays, err = tx.Prepare(queryStrRepeatDays)
-					if err != nil {
-						if err2 := tx.Rollback(); err2 != nil {
-							err = err2
-						}
-						return
-					}
-					defer stmtDeleteRepeatDays.Close()
-					_, err = stmtRepeatDays.Exec(queryParams...)
-					if err != nil {
-						if err2 := tx.Rollback(); err2 != nil {
-							err = err2
-						}
-						return
-					}
-
-					updated.Repeat.Days = **(*new.Repeat.Repeat).Days.Slice
-				}
-			} else if len(t.Repeat.Days) != 0 {
-				// Not weekly and `repeat.days` in DB not empty
-
-				// Delete repeat days
-				var stmtDeleteRepeatDays *sql.Stmt
-				stmtDeleteRepeatDays, err = tx.Prepare("DELETE FROM repeat_days WHERE repeat_model_id = (SELECT repeat_model_id FROM todos WHERE user_id = ? AND id = ?)")
+			if repeat.Until != nil {
+				var until time.Time
+				until, err = time.Parse("2006-1-2", *repeat.Until)
 				if err != nil {
 					return
 				}
-				defer stmtDeleteRepeatDays.Close()
-				_, err = stmtDeleteRepeatDays.Exec(userId, id)
-				if err != nil {
+				if date.After(until) {
+					dateOverUntil = true
 					return
 				}
-
-				updated.Repeat.Days = nil
 			}
 
-			// Rpeat model
-			queryStr := "UPDATE repeat_models SET"
-			var queryParams []interface{}
-
-			noUpdate := true
-			if (*new.Repeat.Repeat).Until.String != nil && *(*new.Repeat.Repeat).Until.String != t.Repeat.Until {
-				queryStr += " until = ?,"
-				queryParams = append(queryParams, *(*new.Repeat.Repeat).Until.String)
-				updated.Repeat.Until = *(*new.Repeat.Repeat).Until.String
-				noUpdate = false
+			updated.Repeat = &repeat
+		} else if t.Repeat != nil && t.Repeat.Until != nil && new.Date.String != nil && scope != PatchScopeThis {
+			// Repeat exists and no update
+			// with update date
+			if *new.Date.String == nil {
+				dateNotFound = true
+				return
 			}
-			if (*new.Repeat.Repeat).Unit != nil && *(*new.Repeat.Repeat).Unit != t.Repeat.Unit {
-				queryStr += " unit = ?,"
-				queryParams = append(queryParams, *(*new.Repeat.Repeat).Unit)
-				updated.Repeat.Unit = *(*new.Repeat.Repeat).Unit
-				noUpdate = false
+			var date, until time.Time
+			date, err = time.Parse("2006-1-2", **new.Date.String)
+			if err != nil {
+				return
 			}
-			if (*new.Repeat.Repeat).EveryOther.UInt != nil && *(*new.Repeat.Repeat).EveryOther.UInt != t.Repeat.EveryOther {
-				queryStr += " every_other = ?,"
-				queryParams = append(queryParams, *(*new.Repeat.Repeat).EveryOther.UInt)
-				updated.Repeat.EveryOther = *(*new.Repeat.Repeat).EveryOther.UInt
-				noUpdate = false
+			until, err = time.Parse("2006-1-2", *t.Repeat.Until)
+			if err != nil {
+				return
 			}
-			if (*new.Repeat.Repeat).Date.UInt != nil && *(*new.Repeat.Repeat).Date.UInt != t.Repeat.Date {
-				queryStr += " date = ?,"
-				queryParams = append(queryParams, *(*new.Repeat.Repeat).Date.UInt)
-				updated.Repeat.Date = *(*new.Repeat.Repeat).Date.UInt
-				noUpdate = false
+			if date.After(until) {
+				dateOverUntil = true
+				return
 			}
+		}
 
-			if !noUpdate {
-				queryStr = strings.TrimRight(queryStr, ",")
-				queryStr += " WHERE id = (SELECT repeat_model_id FROM todos WHERE user_id = ? AND id = ?)"
-				queryParams = append(queryParams, userId, id)
+		// Set update values
+		if new.Name != nil {
+			updated.Name = *new.Name
+		}
+		if new.Description.String != nil {
+			updated.Description = *new.Description.String
+		}
+		if new.Date.String != nil {
+			updated.Date = *new.Date.String
+		}
+		if new.Time.String != nil {
+			updated.Time = *new.Time.String
+		}
+		if new.ExecutionTime != nil {
+			updated.ExecutionTime = *new.ExecutionTime
+		}
+		if new.SprintId.UInt64 != nil {
+			updated.SprintId = *new.SprintId.UInt64
+		}
+		if new.ProjectId.UInt64 != nil {
+			updated.ProjectId = *new.ProjectId.UInt64
+		}
+		if new.Completed != nil {
+			updated.Completed = *new.Completed
+		}
+		if new.Priority.UInt != nil {
+			updated.Priority = *new.Priority.UInt
+		}
+		if new.Labels != nil {
+			updated.Labels = normalizeIds(*new.Labels)
+		}
+		if new.BlockedBy != nil {
+			updated.BlockedBy = normalizeIds(*new.BlockedBy)
+		}
 
-				var stmtRepeatModel *sql.Stmt
-				stmtRepeatModel, err = tx.Prepare(queryStr)
-				if err != nil {
-					if err2 := tx.Rollback(); err2 != nil {
-						err = err2
-					}
-					return
-				}
-				defer stmtRepeatModel.Close()
-				_, err = stmtRepeatModel.Exec(queryParams...)
-				if err != nil {
-					if err2 := tx.Rollback(); err2 != nil {
-						err = err2
-					}
-					return
-				}
-			}
-		} else {
-			// Repeat not exists
-			var stmtRepeatModel *sql.Stmt
-			stmtRepeatModel, err = tx.Prepare("INSERT INTO repeat_models (user_id, until, unit, every_other, date) VALUES (?, ?, ?, ?, ?)")
+		var exceptions []RepeatException
+		var next Todo
+		if t.Repeat != nil && t.Date != nil && scope == PatchScopeThis {
+			// Continue the repeat from the next occurrence
+			next, _, _, err = insertNext(s, userId, t, nil)
 			if err != nil {
 				return
 			}
-			defer stmtRepeatModel.Close()
-			var resultRepeatModel sql.Result
-			resultRepeatModel, err = stmtRepeatModel.Exec(userId, (*new.Repeat.Repeat).Until.String, (*new.Repeat.Repeat).Unit, (*new.Repeat.Repeat).EveryOther.UInt, (*new.Repeat.Repeat).Date.UInt)
+			updated.Repeat = nil
+		}
+		if t.Repeat != nil && t.Date != nil && scope == PatchScopeThisAndFollowing {
+			// End the old repeat the day before this occurrence
+			anchor, _ := t.series()
+			var old Repeat
+			old, err = endRepeatBefore(*t.Repeat, anchor)
 			if err != nil {
 				return
 			}
-			var idRepeatModelTmp int64
-			idRepeatModelTmp, err = resultRepeatModel.LastInsertId()
+			err = s.UpdateRepeatModel(userId, old)
 			if err != nil {
 				return
 			}
-			idRepeatModelTmpUint := uint64(idRepeatModelTmp)
-			idRepeatModelTmpUintP := &idRepeatModelTmpUint
-			idRepeatModel = &idRepeatModelTmpUintP
 
-			if (*new.Repeat.Repeat).Until.String != nil && *(*new.Repeat.Repeat).Until.String != t.Repeat.Until {
-				updated.Repeat.Until = *(*new.Repeat.Repeat).Until.String
-			}
-			if (*new.Repeat.Repeat).Unit != nil && *(*new.Repeat.Repeat).Unit != t.Repeat.Unit {
-				updated.Repeat.Unit = *(*new.Repeat.Repeat).Unit
-			}
-			if (*new.Repeat.Repeat).EveryOther.UInt != nil && *(*new.Repeat.Repeat).EveryOther.UInt != t.Repeat.EveryOther {
-				updated.Repeat.EveryOther = *(*new.Repeat.Repeat).EveryOther.UInt
-			}
-			if (*new.Repeat.Repeat).Date.UInt != nil && *(*new.Repeat.Repeat).Date.UInt != t.Repeat.Date {
-				updated.Repeat.Date = *(*new.Repeat.Repeat).Date.UInt
-			}
-
-			// Repeat days
-			if (*(*new.Repeat.Repeat).Unit == "week" || t.Repeat.Unit == "week") && len(**(*new.Repeat.Repeat).Days.Slice) != 0 {
-				queryStr := "INSERT INTO repeat_days (repeat_model_id, day, time) VALUES"
-				var queryParams []interface{}
-				for _, day := range **(*new.Repeat.Repeat).Days.Slice {
-					queryStr += " (?, ?, ?),"
-					queryParams = append(queryParams, idRepeatModel, day.Day, day.Time)
-				}
-				queryStr = strings.TrimRight(queryStr, ",")
-				var stmtRepeatDays *sql.Stmt
-				stmtRepeatDays, err = tx.Prepare(queryStr)
-				if err != nil {
-					if err2 := tx.Rollback(); err2 != nil {
-						err = err2
+			// New repeat from this occurrence
+			if updated.Repeat != nil {
+				repeat := *updated.Repeat
+				repeat.Id = 0
+				if repeat.Count != nil && (new.Repeat.Repeat == nil || (*new.Repeat.Repeat).Count.UInt == nil) {
+					// Remaining occurrences
+					count := uint(1)
+					if *repeat.Count > repeat.Done {
+						count = *repeat.Count - repeat.Done
 					}
-					return
+					repeat.Count = &count
 				}
-				_, err = stmtRepeatDays.Exec(queryParams...)
-				if err != nil {
-					if err2 := tx.Rollback(); err2 != nil {
-						err = err2
+				repeat.Done = 0
+				for _, e := range repeat.Exceptions {
+					if e.Date > anchor && !e.Applied {
+						exceptions = append(exceptions, e)
 					}
-					return
 				}
-
-				updated.Repeat.Days = **(*new.Repeat.Repeat).Days.Slice
+				repeat.Exceptions = nil
+				updated.Repeat = &repeat
 			}
 		}
-	} else if t.Repeat != nil && t.Repeat.Until != nil && new.Date.String != nil {
-		// Repeat exists and no update
-		// with update date
-		if *new.Date.String == nil {
-			dateNotFound = true
-			return
-		}
-		var date, until time.Time
-		date, err = time.Parse("2006-01-02", **new.Date.String)
-		if err != nil {
-			return
-		}
-		until, err = time.Parse("2006-01-02", *t.Repeat.Until)
+
+		updated.fillRRule()
+
+		// Update row
+		t, err = s.Update(userId, updated)
 		if err != nil {
 			return
 		}
-		if date.After(until) {
-			dateOverUntil = true
-			return
-		}
-	}
 
-	// Generate query
-	queryStr := "UPDATE todos SET"
-	var queryParams []interface{}
-	// Set update values
-	noUpdate := true
-	if new.Name != nil {
-		queryStr += " name = ?,"
-		queryParams = append(queryParams, new.Name)
-		updated.Name = *new.Name
-		noUpdate = false
-	}
-	if new.Description.String != nil {
-		if *new.Description.String != nil {
-			queryStr += " description = ?,"
-			queryParams = append(queryParams, **new.Description.String)
-			updated.Description = *new.Description.String
-		} else {
-			queryStr += " description = ?,"
-			queryParams = append(queryParams, nil)
-			updated.Description = nil
-		}
-		noUpdate = false
-	}
-	if new.Date.String != nil {
-		if *new.Date.String != nil {
-			queryStr += " date = ?,"
-			queryParams = append(queryParams, **new.Date.String)
-			updated.Date = *new.Date.String
-		} else {
-			queryStr += " date = ?,"
-			queryParams = append(queryParams, nil)
-			updated.Date = nil
-		}
-		noUpdate = false
-	}
-	if new.Time.String != nil {
-		if *new.Time.String != nil {
-			queryStr += " time = ?,"
-			queryParams = append(queryParams, **new.Time.String)
-			updated.Time = *new.Time.String
-		} else {
-			queryStr += " time = ?,"
-			queryParams = append(queryParams, nil)
-			updated.Time = nil
-		}
-		noUpdate = false
-	}
-	if new.ExecutionTime != nil {
-		queryStr += " execution_time = ?,"
-		queryParams = append(queryParams, new.ExecutionTime)
-		updated.ExecutionTime = *new.ExecutionTime
-		noUpdate = false
-	}
-	if new.SprintId.UInt64 != nil {
-		if *new.SprintId.UInt64 != nil {
-			queryStr += " sprint_id = ?,"
-			queryParams = append(queryParams, **new.SprintId.UInt64)
-			updated.SprintId = *new.SprintId.UInt64
-		} else {
-			queryStr += " sprint_id = ?,"
-			queryParams = append(queryParams, nil)
-			updated.SprintId = nil
+		// Move exceptions of the following occurrences to the new repeat
+		for _, e := range exceptions {
+			err = s.PutException(userId, t.Repeat.Id, e)
+			if err != nil {
+				return
+			}
 		}
-		noUpdate = false
-	}
-	if new.ProjectId.UInt64 != nil {
-		if *new.ProjectId.UInt64 != nil {
-			queryStr += " parent_id = ?,"
-			queryParams = append(queryParams, **new.ProjectId.UInt64)
-			updated.ProjectId = *new.ProjectId.UInt64
-		} else {
-			queryStr += " parent_id = ?,"
-			queryParams = append(queryParams, nil)
-			updated.ProjectId = nil
+		if len(exceptions) != 0 {
+			t, _, err = s.Get(userId, t.Id)
+			if err != nil {
+				return
+			}
+			t.fillRRule()
 		}
-		noUpdate = false
-	}
-	if new.Completed != nil {
-		queryStr += " completed = ?,"
-		queryParams = append(queryParams, new.Completed)
-		updated.Completed = *new.Completed
-		noUpdate = false
-	}
-	if idRepeatModel != nil {
-		queryStr += "repeat_model_id = ?"
-		queryParams = append(queryParams, *idRepeatModel)
-		noUpdate = false
-	}
-	queryStr = strings.TrimRight(queryStr, ",")
-	queryStr += " WHERE user_id = ? AND id = ?"
-	queryParams = append(queryParams, userId, id)
-
-	if noUpdate {
-		t = updated
-		err = tx.Commit()
-		return
-	}
 
-	// Update row
-	stmt, err := tx.Prepare(queryStr)
-	if err != nil {
-		if err2 := tx.Rollback(); err2 != nil {
-			err = err2
-		}
-		return
-	}
-	defer stmt.Close()
-	_, err = stmt.Exec(queryParams...)
-	if err != nil {
-		if err2 := tx.Rollback(); err2 != nil {
-			err = err2
+		err = recordEvent(s, userId, EventUpdated, t, nil)
+		if err != nil || next.Id == 0 {
+			return
 		}
-		return
-	}
-
-	t = updated
-	err = tx.Commit()
+		return recordEvent(s, userId, EventCreated, next, nil)
+	})
 	return
 }
@@ -0,0 +1,76 @@
+package todo_test
+
+import (
+	"flow-todos/memory"
+	"flow-todos/todo"
+	"testing"
+)
+
+func TestPatchThisAndFollowing(t *testing.T) {
+	s := memory.NewTodoStore()
+	userId := newUserId()
+	first := insertTodo(t, s, userId, todo.Todo{Name: "a", Date: stringPtr("2026-01-01"), Repeat: &todo.Repeat{Unit: "day", From: todo.RepeatFromSchedule}})
+	repeatId := first.Repeat.Id
+	for _, date := range []string{"2026-01-01", "2026-01-06"} {
+		if err := s.PutException(userId, repeatId, todo.RepeatException{Date: date, Cancelled: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first occurrence is completed with the daily rule
+	completed, second, notFound, _, _, _, err := todo.Complete(s, userId, first.Id, false)
+	if err != nil || notFound {
+		t.Fatalf("Complete: notFound %v, err %v", notFound, err)
+	}
+	if second.Date == nil || *second.Date != "2026-01-02" {
+		t.Fatalf("next occurrence is %v, want 2026-01-02", second.Date)
+	}
+
+	// Every other day from the second occurrence
+	everyOther := uint(1)
+	everyOtherP := &everyOther
+	repeat := &todo.PatchRepeatBody{EveryOther: todo.PatchNullUint{UInt: &everyOtherP}}
+	patched, notFound, _, _, _, _, err := todo.Patch(s, userId, second.Id, todo.PatchBody{Repeat: todo.PatchNullJSONRepeat{Repeat: &repeat}}, todo.PatchScopeThisAndFollowing)
+	if err != nil || notFound {
+		t.Fatalf("Patch: notFound %v, err %v", notFound, err)
+	}
+	if patched.Repeat == nil || patched.Repeat.Id == repeatId || patched.Repeat.Until != nil || patched.Repeat.EveryOther == nil || *patched.Repeat.EveryOther != 1 {
+		t.Fatalf("repeat is not split to a new repeat model: %+v", patched.Repeat)
+	}
+	if len(patched.Repeat.Exceptions) != 1 || patched.Repeat.Exceptions[0].Date != "2026-01-06" {
+		t.Errorf("exceptions of the following occurrences are not moved: %+v", patched.Repeat.Exceptions)
+	}
+
+	// The earlier occurrence still points to the old rule, which ends before the split
+	got, _ := getTodo(t, s, userId, completed.Id)
+	if !got.Completed || got.Date == nil || *got.Date != "2026-01-01" {
+		t.Errorf("earlier occurrence is changed: %+v", got)
+	}
+	restored, _, _, conflict, err := todo.Uncomplete(s, userId, completed.Id)
+	if err != nil || conflict {
+		t.Fatalf("Uncomplete: conflict %v, err %v", conflict, err)
+	}
+	if restored.Repeat == nil || restored.Repeat.Id != repeatId || restored.Repeat.EveryOther != nil || restored.Repeat.Until == nil || *restored.Repeat.Until != "2026-01-01" {
+		t.Errorf("earlier occurrence does not keep the old rule until 2026-01-01: %+v", restored.Repeat)
+	}
+	_, next, _, _, _, _, err := todo.Complete(s, userId, completed.Id, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Id != 0 {
+		t.Errorf("old rule continues after the split: %+v", next)
+	}
+	got, _ = getTodo(t, s, userId, second.Id)
+	if got.Repeat == nil || got.Repeat.Id != patched.Repeat.Id {
+		t.Errorf("following occurrence is moved from the new rule: %+v", got.Repeat)
+	}
+
+	// The following occurrences are every other day
+	_, third, _, _, _, _, err := todo.Complete(s, userId, second.Id, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.Date == nil || *third.Date != "2026-01-04" {
+		t.Errorf("next occurrence is %v, want 2026-01-04", third.Date)
+	}
+}
@@ -1,24 +1,25 @@
 package todo
 
 import (
-	"database/sql"
-	"flow-todos/mysql"
-	"strings"
 	"time"
 
 	"github.com/go-playground/validator"
 )
 
 type PostBody struct {
-	Name          string  `json:"name" validate:"required,gte=1"`
-	Description   *string `json:"description" validate:"omitempty"`
-	Date          *string `json:"date" validate:"omitempty,Y-M-D"`
-	Time          *string `json:"time" validate:"omitempty,H:M"`
-	ExecutionTime *uint   `json:"execution_time" validate:"step15,gte=15"`
-	SprintId      *uint64 `json:"sprint_id" validate:"omitempty,gte=1"`
-	ProjectId     *uint64 `json:"project_id" validate:"omitempty,gte=1"`
-	Completed     *bool   `json:"completed" validate:"omitempty"`
-	Repeat        *Repeat `json:"repeat" validate:"omitempty,dive"`
+	Name          string                  `json:"name" validate:"required,gte=1"`
+	Description   *string                 `json:"description" validate:"omitempty"`
+	Date          *string                 `json:"date" validate:"omitempty,Y-M-D"`
+	Time          *string                 `json:"time" validate:"omitempty,H:M"`
+	ExecutionTime *uint                   `json:"execution_time" validate:"step15,gte=15"`
+	SprintId      *uint64                 `json:"sprint_id" validate:"omitempty,gte=1"`
+	ProjectId     *uint64                 `json:"project_id" validate:"omitempty,gte=1"`
+	Completed     *bool                   `json:"completed" validate:"omitempty"`
+	Priority      *uint                   `json:"priority" validate:"omitempty,gte=1,lte=4"`
+	Repeat        *Repeat                 `json:"repeat" validate:"omitempty,dive"`
+	Labels        []uint64                `json:"labels" validate:"omitempty,dive,gte=1"`
+	Checklist     []ChecklistItemPostBody `json:"checklist" validate:"omitempty,dive"`
+	BlockedBy     []uint64                `json:"blocked_by" validate:"omitempty,dive,gte=1"`
 }
 
 func DateStrValidation(fl validator.FieldLevel) bool {
@@ -38,7 +39,7 @@ func Step15IntValidation(fl validator.FieldLevel) bool {
 	return fl.Field().Uint()%15 == 0
 }
 
-func Post(userId uint64, post PostBody) (p Todo, dateNotFound bool, dateOverUntil bool, noDaysWithWeekly bool, err error) {
+func Post(s TodoStore, userId uint64, post PostBody) (p Todo, dateNotFound bool, dateOverUntil bool, noDaysWithWeekly bool, err error) {
 	var date time.Time
 
 	// Validate `repeat`
@@ -72,71 +73,39 @@ func Post(userId uint64, post PostBody) (p Todo, dateNotFound bool, dateOverUnti
 		}
 	}
 
-	db, err := mysql.Open()
-	if err != nil {
-		return
-	}
-	defer db.Close()
-
-	tx, err := db.Begin()
-	if err != nil {
-		return
-	}
-
-	// Repeat model
-	var idRepeatModel *int64
 	if post.Repeat != nil {
+		if post.Repeat.Unit == "" {
+			// Repeat by `repeat.rrule` only
+			post.Repeat.EveryOther = nil
+			post.Repeat.Date = nil
+		}
 		// set `repeat.day` from `date`
-		if post.Repeat.Unit == "day" && post.Repeat.Date == nil {
+		if (post.Repeat.Unit == "day" || post.Repeat.Unit == "month" || post.Repeat.Unit == "year") && post.Repeat.Date == nil {
 			tmpDay := uint(date.Day())
 			post.Repeat.Date = &tmpDay
 		}
-
-		var stmtRepeatModel *sql.Stmt
-		stmtRepeatModel, err = tx.Prepare("INSERT INTO repeat_models (user_id, until, unit, every_other, date) VALUES (?, ?, ?, ?, ?)")
-		if err != nil {
-			return
-		}
-		defer stmtRepeatModel.Close()
-		var resultRepeatModel sql.Result
-		resultRepeatModel, err = stmtRepeatModel.Exec(userId, post.Repeat.Until, post.Repeat.Unit, post.Repeat.EveryOther, post.Repeat.Date)
-		if err != nil {
-			return
-		}
-		var idRepeatModelTmp int64
-		idRepeatModelTmp, err = resultRepeatModel.LastInsertId()
-		if err != nil {
-			return
-		}
-		idRepeatModel = &idRepeatModelTmp
-
-		// Repeat days
-		if post.Repeat.Unit == "week" {
-			queryStr := "INSERT INTO repeat_days (repeat_model_id, day, time) VALUES"
-			var queryParams []interface{}
-			for _, day := range post.Repeat.Days {
-				queryStr += " (?, ?, ?),"
-				queryParams = append(queryParams, idRepeatModel, day.Day, day.Time)
+		if post.Repeat.Unit == "year" {
+			if post.Repeat.Month == nil {
+				tmpMonth := uint(date.Month())
+				post.Repeat.Month = &tmpMonth
 			}
-			queryStr = strings.TrimRight(queryStr, ",")
-			var stmtRepeatDays *sql.Stmt
-			stmtRepeatDays, err = tx.Prepare(queryStr)
-			if err != nil {
-				if err2 := tx.Rollback(); err2 != nil {
-					err = err2
-				}
-				return
-			}
-			_, err = stmtRepeatDays.Exec(queryParams...)
-			if err != nil {
-				if err2 := tx.Rollback(); err2 != nil {
-					err = err2
-				}
-				return
+			if post.Repeat.LeapDay == nil {
+				leapDay := LeapDayFeb28
+				post.Repeat.LeapDay = &leapDay
 			}
 		} else {
+			post.Repeat.Month = nil
+			post.Repeat.LeapDay = nil
+		}
+		if post.Repeat.Unit != "week" {
 			post.Repeat.Days = nil
 		}
+		if post.Repeat.From == "" {
+			post.Repeat.From = RepeatFromSchedule
+		}
+		post.Repeat.Id = 0
+		post.Repeat.Done = 0
+		post.Repeat.Exceptions = nil
 	}
 
 	// Set defualt value
@@ -144,60 +113,37 @@ func Post(userId uint64, post PostBody) (p Todo, dateNotFound bool, dateOverUnti
 		completed := false
 		post.Completed = &completed
 	}
-
-	// Insert DB
-	stmt, err := tx.Prepare("INSERT INTO todos (user_id, name, description, date, time, execution_time, sprint_id, project_id, completed, repeat_model_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
-	if err != nil {
-		if err2 := tx.Rollback(); err2 != nil {
-			err = err2
-		}
-		return
-	}
-	defer stmt.Close()
-	result, err := stmt.Exec(userId, post.Name, post.Description, post.Date, post.Time, post.ExecutionTime, post.SprintId, post.ProjectId, post.Completed, idRepeatModel)
-	if err != nil {
-		if err2 := tx.Rollback(); err2 != nil {
-			err = err2
-		}
-		return
-	}
-	id, err := result.LastInsertId()
-	if err != nil {
-		if err2 := tx.Rollback(); err2 != nil {
-			err = err2
-		}
-		return
+	if post.ExecutionTime == nil {
+		executionTime := uint(15)
+		post.ExecutionTime = &executionTime
 	}
 
-	p.Id = uint64(id)
-	p.Name = post.Name
-	if post.Description != nil {
-		p.Description = post.Description
-	}
-	if post.Date != nil {
-		p.Date = post.Date
+	p = Todo{
+		Name:          post.Name,
+		Description:   post.Description,
+		Date:          post.Date,
+		Time:          post.Time,
+		ExecutionTime: *post.ExecutionTime,
+		SprintId:      post.SprintId,
+		ProjectId:     post.ProjectId,
+		Completed:     *post.Completed,
+		Priority:      post.Priority,
+		Repeat:        post.Repeat,
+		Labels:        normalizeIds(post.Labels),
+		BlockedBy:     normalizeIds(post.BlockedBy),
 	}
-	if post.Time != nil {
-		p.Time = post.Time
-	}
-	if post.ExecutionTime != nil {
-		p.ExecutionTime = *post.ExecutionTime
-	} else {
-		p.ExecutionTime = 15
-	}
-	if post.SprintId != nil {
-		p.SprintId = post.SprintId
-	}
-	if post.ProjectId != nil {
-		p.ProjectId = post.ProjectId
-	}
-	if post.Completed != nil {
-		p.Completed = *post.Completed
-	}
-	if post.Repeat != nil {
-		p.Repeat = post.Repeat
+	for _, item := range post.Checklist {
+		p.Checklist = append(p.Checklist, ChecklistItem{Name: item.Name, Checked: item.Checked})
 	}
+	p.fillRRule()
 
-	err = tx.Commit()
+	// Insert DB
+	err = s.WithTx(func(s TodoStore) (err error) {
+		p, err = s.Insert(userId, p)
+		if err != nil {
+			return
+		}
+		return recordEvent(s, userId, EventCreated, p, nil)
+	})
 	return
 }
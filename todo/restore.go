@@ -0,0 +1,255 @@
+package todo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Restore creates the todos of the dump with new ids in a transaction.
+// Labels are matched by name and created if missing,
+// todos sharing a repeat model in the dump share a new repeat model.
+// A todo of the dump conflicts with the todo of the user with the same id and creation time,
+// which is kept (`skip`), replaced (`overwrite`) or restored as another todo (`duplicate`).
+// Blockers are remapped to the new ids. In dry run nothing is changed.
+func Restore(s TodoStore, userId uint64, d Dump, conflict string, dryRun bool) (results []RestoreResult, err error) {
+	err = s.WithTx(func(s TodoStore) (err error) {
+		results = nil
+		labelIds, err := restoreLabels(s, userId, d.Labels)
+		if err != nil {
+			return
+		}
+
+		// Ids in the dump to the ids of the user
+		todoIds := map[uint64]uint64{}
+		repeatIds := map[uint64]uint64{}
+		for _, dumped := range d.Todos {
+			r := RestoreResult{Id: dumped.Id, Status: RestoreFailed}
+			if failure := dumped.invalid(); failure != "" {
+				r.Error = failure
+				results = append(results, r)
+				continue
+			}
+
+			var existing Todo
+			var notFound bool
+			existing, notFound, err = s.Get(userId, dumped.Id)
+			if err != nil {
+				return
+			}
+			conflicts := !notFound && (dumped.CreatedAt == nil || existing.CreatedAt != nil && existing.CreatedAt.Unix() == dumped.CreatedAt.Unix())
+			if conflicts && conflict == ConflictSkip {
+				r.Status = RestoreSkipped
+				r.NewId = existing.Id
+				todoIds[dumped.Id] = existing.Id
+				results = append(results, r)
+				continue
+			}
+
+			t := dumped.todo()
+			t.Blocks = nil
+			// Set by the second pass
+			t.BlockedBy = nil
+			t.Labels = nil
+			for _, id := range dumped.Labels {
+				if newId, ok := labelIds[id]; ok {
+					t.Labels = append(t.Labels, newId)
+				} else {
+					r.Warnings = append(r.Warnings, fmt.Sprintf("label id: %d is not in the dump and is removed", id))
+				}
+			}
+			newRepeat := false
+			if t.Repeat != nil {
+				// Put after the repeat model is saved
+				t.Repeat.Exceptions = nil
+				if id, ok := repeatIds[dumped.RepeatId]; ok {
+					t.Repeat.Id = id
+				} else if conflicts && conflict == ConflictOverwrite && existing.Repeat != nil {
+					t.Repeat.Id = existing.Repeat.Id
+					newRepeat = true
+				} else {
+					t.Repeat.Id = 0
+					newRepeat = true
+				}
+			}
+
+			if conflicts && conflict == ConflictOverwrite {
+				t.Id = existing.Id
+				t, err = s.Update(userId, t)
+				if err != nil {
+					return
+				}
+				// Replace the checklist, ignored by Update
+				for _, item := range existing.Checklist {
+					if _, err = s.DeleteChecklistItem(userId, t.Id, item.Id); err != nil {
+						return
+					}
+				}
+				for _, item := range dumped.Checklist {
+					if _, err = s.InsertChecklistItem(userId, t.Id, ChecklistItem{Name: item.Name, Checked: item.Checked}); err != nil {
+						return
+					}
+				}
+				r.Status = RestoreOverwritten
+			} else {
+				t.Id = 0
+				t.Checklist = uncheckedChecklist(nil)
+				for _, item := range dumped.Checklist {
+					t.Checklist = append(t.Checklist, ChecklistItem{Name: item.Name, Checked: item.Checked})
+				}
+				t, err = s.Insert(userId, t)
+				if err != nil {
+					return
+				}
+				r.Status = RestoreCreated
+			}
+
+			if t.Repeat != nil && newRepeat {
+				repeatIds[dumped.RepeatId] = t.Repeat.Id
+				// Exceptions belong to the repeat model
+				if err = s.DeleteExceptionsBefore(userId, t.Repeat.Id, "9999-12-31"); err != nil {
+					return
+				}
+				for _, e := range dumped.todo().Repeat.Exceptions {
+					if err = s.PutException(userId, t.Repeat.Id, e); err != nil {
+						return
+					}
+				}
+			}
+			r.NewId = t.Id
+			todoIds[dumped.Id] = t.Id
+			results = append(results, r)
+		}
+
+		// Blockers with the new ids
+		for i, dumped := range d.Todos {
+			r := &results[i]
+			if len(dumped.BlockedBy) == 0 || r.Status == RestoreFailed || r.Status == RestoreSkipped {
+				continue
+			}
+			var blockedBy []uint64
+			for _, id := range dumped.BlockedBy {
+				if newId, ok := todoIds[id]; ok {
+					blockedBy = append(blockedBy, newId)
+				} else {
+					r.Warnings = append(r.Warnings, fmt.Sprintf("blocker todo id: %d is not restored and is removed", id))
+				}
+			}
+			if len(blockedBy) == 0 {
+				continue
+			}
+			var cycle bool
+			_, cycle, err = CheckDependencies(s, userId, r.NewId, blockedBy)
+			if err != nil {
+				return
+			}
+			if cycle {
+				r.Warnings = append(r.Warnings, "`blocked_by` makes a dependency cycle and is removed")
+				continue
+			}
+			var t Todo
+			t, _, err = s.Get(userId, r.NewId)
+			if err != nil {
+				return
+			}
+			t.BlockedBy = normalizeIds(blockedBy)
+			if _, err = s.Update(userId, t); err != nil {
+				return
+			}
+		}
+
+		// Events of the todos as restored
+		events := map[string]string{RestoreCreated: EventCreated, RestoreOverwritten: EventUpdated}
+		for _, r := range results {
+			event, ok := events[r.Status]
+			if !ok {
+				continue
+			}
+			if err = recordEventId(s, userId, event, r.NewId); err != nil {
+				return
+			}
+		}
+
+		if dryRun {
+			for i := range results {
+				if results[i].Status != RestoreSkipped {
+					results[i].NewId = 0
+				}
+			}
+			return errDryRun
+		}
+		return
+	})
+	if err == errDryRun {
+		err = nil
+	}
+	return
+}
+
+// Ids of the labels of the dump to the labels of the user with the same name, created if missing
+func restoreLabels(s TodoStore, userId uint64, dumped []Label) (ids map[uint64]uint64, err error) {
+	ids = map[uint64]uint64{}
+	labels, err := s.ListLabels(userId)
+	if err != nil {
+		return
+	}
+	for _, d := range dumped {
+		found := false
+		for _, l := range labels {
+			if strings.EqualFold(l.Name, d.Name) {
+				ids[d.Id] = l.Id
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		var l Label
+		l, err = s.InsertLabel(userId, Label{Name: d.Name, Color: strings.ToLower(d.Color)})
+		if err != nil {
+			return
+		}
+		labels = append(labels, l)
+		ids[d.Id] = l.Id
+	}
+	return
+}
+
+// Reason the todo of a dump cannot be restored
+func (t snapshot) invalid() string {
+	if strings.TrimSpace(t.Name) == "" {
+		return "`name` required"
+	}
+	if t.Date != nil {
+		if _, err := time.Parse("2006-1-2", *t.Date); err != nil {
+			return fmt.Sprintf("invalid date `%s`", *t.Date)
+		}
+	}
+	if t.Time != nil {
+		if _, err := time.Parse("15:4", *t.Time); err != nil {
+			return fmt.Sprintf("invalid time `%s`", *t.Time)
+		}
+	}
+	if t.Priority != nil && (*t.Priority < 1 || *t.Priority > 4) {
+		return fmt.Sprintf("invalid priority `%d`", *t.Priority)
+	}
+	if t.Repeat != nil {
+		if t.Date == nil {
+			return "`date` required to set `repeat`"
+		}
+		switch t.Repeat.Unit {
+		case "day", "week", "month", "year":
+		case "":
+			if t.Repeat.RRule == nil {
+				return "`repeat.unit` or `repeat.rrule` required"
+			}
+			if _, _, err := t.Repeat.Rule(time.Now()); err != nil {
+				return fmt.Sprintf("invalid repeat.rrule `%s`", *t.Repeat.RRule)
+			}
+		default:
+			return fmt.Sprintf("invalid repeat.unit `%s`", t.Repeat.Unit)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package todo_test
+
+import (
+	"encoding/json"
+	"flow-todos/memory"
+	"flow-todos/todo"
+	"testing"
+)
+
+func TestRestoreSharedRepeatModel(t *testing.T) {
+	s := memory.NewTodoStore()
+	userId := newUserId()
+
+	// Two occurrences of a series share the repeat model 7 of the dump
+	var d todo.Dump
+	err := json.Unmarshal([]byte(`{
+		"version": 1,
+		"labels": [],
+		"todos": [
+			{"id": 1, "name": "a", "date": "2026-01-01", "execution_time": 15, "repeat": {"unit": "day", "from": "schedule"}, "repeat_id": 7},
+			{"id": 2, "name": "b", "date": "2026-01-02", "execution_time": 15, "repeat": {"unit": "day", "from": "schedule"}, "repeat_id": 7}
+		]
+	}`), &d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := todo.Restore(s, userId, d, todo.ConflictDuplicate, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Status != todo.RestoreCreated || results[1].Status != todo.RestoreCreated {
+		t.Fatalf("todos are not restored: %+v", results)
+	}
+	first, _ := getTodo(t, s, userId, results[0].NewId)
+	second, _ := getTodo(t, s, userId, results[1].NewId)
+	if first.Repeat == nil || second.Repeat == nil || first.Repeat.Id != second.Repeat.Id {
+		t.Fatalf("restored todos do not share the repeat model: %+v, %+v", first.Repeat, second.Repeat)
+	}
+
+	// Deleting an occurrence keeps the series of the other one
+	notFound, err := todo.Delete(s, userId, first.Id)
+	if err != nil || notFound {
+		t.Fatalf("Delete: notFound %v, err %v", notFound, err)
+	}
+	got, _ := getTodo(t, s, userId, second.Id)
+	if got.Repeat == nil || got.Repeat.Id != second.Repeat.Id || got.Repeat.Unit != "day" {
+		t.Errorf("repeat model of the remaining todo is deleted: %+v", got.Repeat)
+	}
+	if err = todo.DeleteAll(s, userId); err != nil {
+		t.Fatal(err)
+	}
+}
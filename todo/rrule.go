@@ -0,0 +1,178 @@
+package todo
+
+import (
+	"flow-todos/rrule"
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+// Anchor of the next occurrence
+const (
+	RepeatFromSchedule   = "schedule"
+	RepeatFromCompletion = "completion"
+)
+
+// Policy of yearly repeats on Feb 29 in common years
+const (
+	LeapDayFeb28 = "feb28"
+	LeapDayMar1  = "mar1"
+)
+
+func RRuleStrValidation(fl validator.FieldLevel) bool {
+	// `FREQ=WEEKLY;BYDAY=MO,TH`
+	_, err := rrule.Parse(fl.Field().String())
+	return err == nil
+}
+
+// Rule returns the recurrence rule of the repeat.
+// Unit based repeats are converted, `dtstart` is used as the anchor of monthly and yearly repeats without `date` or `month`.
+// `COUNT` is the number of occurrences remaining from `dtstart`.
+func (r *Repeat) Rule(dtstart time.Time) (rule rrule.RRule, invalidUnit bool, err error) {
+	defer func() {
+		if err != nil || invalidUnit {
+			return
+		}
+		if r.Count != nil {
+			rule.Count = int(*r.Count)
+		}
+		if rule.Count != 0 {
+			// Remaining occurrences from `dtstart`
+			rule.Count -= int(r.Done)
+			if rule.Count < 1 {
+				rule.Count = 1
+			}
+		}
+	}()
+
+	if r.Unit == "" && r.RRule != nil {
+		rule, err = rrule.Parse(*r.RRule)
+		return
+	}
+
+	rule.Interval = 1
+	rule.Wkst = time.Monday
+	if r.EveryOther != nil {
+		rule.Interval += int(*r.EveryOther)
+	}
+	if r.Until != nil {
+		var until time.Time
+		until, err = time.Parse("2006-1-2", *r.Until)
+		if err != nil {
+			return
+		}
+		rule.Until = &until
+	}
+
+	switch r.Unit {
+	case "day":
+		rule.Freq = rrule.Daily
+
+	case "week":
+		rule.Freq = rrule.Weekly
+		// `repeat.days[].day` starts from Sunday
+		rule.Wkst = time.Sunday
+		if r.EveryOther != nil {
+			// Weekly repeats skip one week for any `every_other`, as before `rrule`
+			rule.Interval = 2
+		}
+		for _, rd := range r.Days {
+			rule.ByDay = append(rule.ByDay, rrule.Weekday{Day: time.Weekday(rd.Day)})
+		}
+
+	case "month":
+		rule.Freq = rrule.Monthly
+		date := dtstart.Day()
+		if r.Date != nil && *r.Date != 0 {
+			date = int(*r.Date)
+		}
+		if date <= 28 {
+			rule.ByMonthDay = []int{date}
+		} else {
+			// Fall back to the last day of shorter months
+			for d := 28; d <= date; d++ {
+				rule.ByMonthDay = append(rule.ByMonthDay, d)
+			}
+			rule.BySetPos = []int{-1}
+		}
+
+	case "year":
+		rule.Freq = rrule.Yearly
+		month := dtstart.Month()
+		if r.Month != nil {
+			month = time.Month(*r.Month)
+		}
+		date := dtstart.Day()
+		if r.Date != nil && *r.Date != 0 {
+			date = int(*r.Date)
+		}
+		rule.ByMonth = []int{int(month)}
+		if month == time.February && date >= 29 {
+			if r.LeapDay != nil && *r.LeapDay == LeapDayMar1 {
+				// Day 60 is Feb 29 in leap years, Mar 1 otherwise
+				rule.ByMonth = nil
+				rule.ByYearDay = []int{60}
+			} else {
+				rule.ByMonthDay = []int{28, 29}
+				rule.BySetPos = []int{-1}
+			}
+		} else if date > 28 {
+			// Fall back to the last day of the month
+			for d := 28; d <= date; d++ {
+				rule.ByMonthDay = append(rule.ByMonthDay, d)
+			}
+			rule.BySetPos = []int{-1}
+		} else {
+			rule.ByMonthDay = []int{date}
+		}
+
+	default:
+		invalidUnit = true
+	}
+	return
+}
+
+// Set `repeat.rrule` converted from unit based repeat and `repeat.remaining`
+func (t *Todo) fillRRule() {
+	if t.Repeat == nil || t.Date == nil {
+		return
+	}
+	anchor, _ := t.series()
+	dtstart, err := time.Parse("2006-1-2", anchor)
+	if err != nil {
+		return
+	}
+	rule, invalidUnit, err := t.Repeat.Rule(dtstart)
+	if err != nil || invalidUnit {
+		return
+	}
+	repeat := *t.Repeat
+	repeat.Remaining = nil
+	if rule.Count != 0 {
+		remaining := uint(rule.Count)
+		repeat.Remaining = &remaining
+	}
+	if repeat.Unit != "" {
+		// `COUNT` is `repeat.count` of the chain, the rule can be sent back as is
+		rule.Count = 0
+		if repeat.Count != nil {
+			rule.Count = int(*repeat.Count)
+		}
+		str := rule.String()
+		repeat.RRule = &str
+	}
+	t.Repeat = &repeat
+}
+
+// Time of the occurrence on `date` (`repeat.days[].time` of weekly repeats)
+func (r *Repeat) timeOn(date time.Time) *string {
+	if r.Unit != "week" {
+		return nil
+	}
+	for _, rd := range r.Days {
+		if time.Weekday(rd.Day) == date.Weekday() {
+			return rd.Time
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package todo_test
+
+import (
+	"flow-todos/memory"
+	"flow-todos/rrule"
+	"flow-todos/todo"
+	"testing"
+	"time"
+)
+
+func TestRepeatCount(t *testing.T) {
+	s := memory.NewTodoStore()
+	userId := newUserId()
+	count := uint(3)
+	first := insertTodo(t, s, userId, todo.Todo{Name: "a", Date: stringPtr("2026-01-01"), Repeat: &todo.Repeat{Unit: "day", Count: &count, From: todo.RepeatFromSchedule}})
+
+	_, next, notFound, _, _, _, err := todo.Complete(s, userId, first.Id, false)
+	if err != nil || notFound {
+		t.Fatalf("Complete: notFound %v, err %v", notFound, err)
+	}
+	got, _, err := todo.Get(s, userId, next.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// `count` and the `COUNT` of `rrule` are of the chain, `remaining` is from the todo
+	if got.Repeat == nil || got.Repeat.Count == nil || *got.Repeat.Count != 3 {
+		t.Fatalf("count is changed: %+v", got.Repeat)
+	}
+	if got.Repeat.RRule == nil || *got.Repeat.RRule != "FREQ=DAILY;COUNT=3" {
+		t.Errorf("rrule is %v, want `FREQ=DAILY;COUNT=3`", got.Repeat.RRule)
+	}
+	if got.Repeat.Remaining == nil || *got.Repeat.Remaining != 2 {
+		t.Errorf("remaining is %v, want 2", got.Repeat.Remaining)
+	}
+}
+
+func TestRRuleMaxCount(t *testing.T) {
+	if _, err := rrule.Parse("FREQ=DAILY;COUNT=1000"); err != nil {
+		t.Errorf("COUNT=1000 is rejected: %v", err)
+	}
+	if _, err := rrule.Parse("FREQ=DAILY;COUNT=1001"); err == nil {
+		t.Error("COUNT over the limit is accepted")
+	}
+}
+
+func TestWeeklyEveryOther(t *testing.T) {
+	// Any `every_other` of weekly repeats is every other week
+	everyOther := uint(3)
+	r := todo.Repeat{Unit: "week", EveryOther: &everyOther, Days: []todo.RepeatDay{{Day: 1}, {Day: 4}}}
+	for _, c := range []struct{ date, next string }{
+		{"2026-01-05", "2026-01-08"},
+		{"2026-01-08", "2026-01-19"},
+	} {
+		d, err := time.Parse("2006-01-02", c.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, _, _, _, err := r.GetNext(d.Year(), d.Month(), d.Day())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next != c.next {
+			t.Errorf("next of %s is %s, want %s", c.date, next, c.next)
+		}
+	}
+}
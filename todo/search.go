@@ -0,0 +1,211 @@
+package todo
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Todo ranked by `Search`
+type SearchResult struct {
+	Todo
+	Score      float64          `json:"score"`
+	Highlights SearchHighlights `json:"highlights"`
+}
+
+// Fragments of the fields around the matched words.
+// Text is HTML escaped and the matched words are wrapped in `<em>`.
+type SearchHighlights struct {
+	Name        []string `json:"name,omitempty"`
+	Description []string `json:"description,omitempty"`
+}
+
+const (
+	// Bytes of text around a matched word in a fragment
+	highlightContext = 30
+	// Max number of fragments of a field
+	highlightFragments = 3
+)
+
+func Search(s TodoStore, userId uint64, text string, q GetListQuery) (results []SearchResult, err error) {
+	todos, scores, err := s.Search(userId, text, q)
+	if err != nil {
+		return
+	}
+
+	terms := SearchTerms(text)
+	for i, t := range todos {
+		t.fillRRule()
+		r := SearchResult{Todo: t, Score: scores[i]}
+		r.Highlights.Name = highlight(t.Name, terms)
+		if t.Description != nil {
+			r.Highlights.Description = highlight(*t.Description, terms)
+		}
+		results = append(results, r)
+	}
+	return
+}
+
+type token struct {
+	word  string
+	start int
+	end   int
+}
+
+// Lower case words of letters and digits with their byte offsets
+func tokenize(str string) (tokens []token) {
+	start := -1
+	for i, r := range str {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{strings.ToLower(str[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(str[start:]), start, len(str)})
+	}
+	return
+}
+
+// Words of less characters are not indexed, `innodb_ft_min_token_size` of MySQL
+const minTermLength = 3
+
+// Words not indexed, the default stopwords of InnoDB full-text search
+var stopwords = map[string]bool{
+	"a": true, "about": true, "an": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"com": true, "de": true, "en": true, "for": true, "from": true, "how": true, "i": true, "in": true,
+	"is": true, "it": true, "la": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "what": true, "when": true, "where": true, "who": true,
+	"will": true, "with": true, "und": true, "www": true,
+}
+
+// SearchTerms returns the distinct lower case words of the text,
+// without stopwords and short words like the MySQL full-text index
+func SearchTerms(text string) (terms []string) {
+	seen := map[string]bool{}
+	for _, t := range tokenize(text) {
+		if seen[t.word] || stopwords[t.word] || utf8.RuneCountInString(t.word) < minTermLength {
+			continue
+		}
+		seen[t.word] = true
+		terms = append(terms, t.word)
+	}
+	return
+}
+
+// RankTodos ranks todos containing the words of `text` by TF-IDF, in-process equivalent of a full-text index.
+// Words in the name weigh twice as much as in the description.
+func RankTodos(todos []Todo, text string) (ranked []Todo, scores []float64) {
+	terms := SearchTerms(text)
+	frequencies := make([]map[string]float64, len(todos))
+	documents := map[string]int{}
+	for i, t := range todos {
+		frequencies[i] = map[string]float64{}
+		for _, tk := range tokenize(t.Name) {
+			frequencies[i][tk.word] += 2
+		}
+		if t.Description != nil {
+			for _, tk := range tokenize(*t.Description) {
+				frequencies[i][tk.word]++
+			}
+		}
+		for _, term := range terms {
+			if frequencies[i][term] != 0 {
+				documents[term]++
+			}
+		}
+	}
+
+	type rank struct {
+		todo  Todo
+		score float64
+	}
+	var ranks []rank
+	for i, t := range todos {
+		score := 0.0
+		for _, term := range terms {
+			if frequencies[i][term] == 0 {
+				continue
+			}
+			score += frequencies[i][term] * math.Log(1+float64(len(todos))/float64(documents[term]))
+		}
+		if score > 0 {
+			ranks = append(ranks, rank{t, score})
+		}
+	}
+	sort.SliceStable(ranks, func(i, j int) bool {
+		if ranks[i].score != ranks[j].score {
+			return ranks[i].score > ranks[j].score
+		}
+		return ranks[i].todo.Id < ranks[j].todo.Id
+	})
+
+	for _, r := range ranks {
+		ranked = append(ranked, r.todo)
+		scores = append(scores, r.score)
+	}
+	return
+}
+
+// Fragments of str around the words matching terms
+func highlight(str string, terms []string) (fragments []string) {
+	match := map[string]bool{}
+	for _, term := range terms {
+		match[term] = true
+	}
+	var matches []token
+	for _, t := range tokenize(str) {
+		if match[t.word] {
+			matches = append(matches, t)
+		}
+	}
+
+	for i := 0; i < len(matches) && len(fragments) < highlightFragments; {
+		start := runeStart(str, matches[i].start-highlightContext)
+		end := runeStart(str, matches[i].end+highlightContext)
+
+		// Merge matches in the fragment
+		var b strings.Builder
+		if start > 0 {
+			b.WriteString("…")
+		}
+		pos := start
+		for ; i < len(matches) && matches[i].start < end; i++ {
+			if matches[i].end > end {
+				end = matches[i].end
+			}
+			b.WriteString(html.EscapeString(str[pos:matches[i].start]))
+			b.WriteString("<em>" + html.EscapeString(str[matches[i].start:matches[i].end]) + "</em>")
+			pos = matches[i].end
+		}
+		b.WriteString(html.EscapeString(str[pos:end]))
+		if end < len(str) {
+			b.WriteString("…")
+		}
+		fragments = append(fragments, b.String())
+	}
+	return
+}
+
+// Offset clamped to str and moved back to the start of a rune
+func runeStart(str string, offset int) int {
+	if offset <= 0 {
+		return 0
+	}
+	if offset >= len(str) {
+		return len(str)
+	}
+	for offset > 0 && !utf8.RuneStart(str[offset]) {
+		offset--
+	}
+	return offset
+}
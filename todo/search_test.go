@@ -0,0 +1,43 @@
+package todo_test
+
+import (
+	"flow-todos/memory"
+	"flow-todos/todo"
+	"reflect"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	// Stopwords and words shorter than 3 characters are not searched, like the MySQL full-text index
+	got := todo.SearchTerms("Fix the UI of an API, fix the")
+	if want := []string{"fix", "api"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SearchTerms is %v, want %v", got, want)
+	}
+}
+
+func TestMemorySearch(t *testing.T) {
+	s := memory.NewTodoStore()
+	userId := newUserId()
+	bank := insertTodo(t, s, userId, todo.Todo{Name: "Go to the bank"})
+	insertTodo(t, s, userId, todo.Todo{Name: "Call mom", Description: stringPtr("about the trip")})
+
+	for _, text := range []string{"to the", "go", "about"} {
+		results, err := todo.Search(s, userId, text, todo.GetListQuery{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 0 {
+			t.Errorf("search of `%s` found %d todos, stopwords and short words are not indexed", text, len(results))
+		}
+	}
+	results, err := todo.Search(s, userId, "the bank", todo.GetListQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Id != bank.Id {
+		t.Fatalf("search of `the bank` found %+v", results)
+	}
+	if want := []string{"Go to the <em>bank</em>"}; !reflect.DeepEqual(results[0].Highlights.Name, want) {
+		t.Errorf("highlights are %v, want %v", results[0].Highlights.Name, want)
+	}
+}
@@ -0,0 +1,134 @@
+package todo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Sort keys of `GetList`
+const (
+	SortDate          = "date"
+	SortTime          = "time"
+	SortName          = "name"
+	SortExecutionTime = "execution_time"
+	SortCreatedAt     = "created_at"
+	SortUpdatedAt     = "updated_at"
+	SortPriority      = "priority"
+)
+
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// SortKeys returns the keys of the todo compared as strings in order.
+// Null date, time and priority come last in both orders.
+// Priority follows the primary keys, then the id (`original_id` of repeat schedules) and the date make the order total.
+// The MySQL store builds the same keys in SQL.
+func SortKeys(t Todo, sortKey string, order string) (keys []string) {
+	nullDate, nullTime, nullPriority := "9999-12-31", "99:99", "9"
+	if order == OrderDesc {
+		nullDate, nullTime, nullPriority = "", "", ""
+	}
+	date, tm, priority := nullDate, nullTime, nullPriority
+	if t.Date != nil {
+		date = *t.Date
+	}
+	if t.Time != nil {
+		tm = *t.Time
+	}
+	if t.Priority != nil {
+		priority = fmt.Sprint(*t.Priority)
+	}
+
+	switch sortKey {
+	case SortTime:
+		keys = []string{tm}
+	case SortName:
+		keys = []string{t.Name}
+	case SortExecutionTime:
+		keys = []string{fmt.Sprintf("%010d", t.ExecutionTime)}
+	case SortCreatedAt:
+		keys = []string{formatTimestamp(t.CreatedAt)}
+	case SortUpdatedAt:
+		keys = []string{formatTimestamp(t.UpdatedAt)}
+	case SortPriority:
+		keys = []string{priority, date, tm}
+	default:
+		keys = []string{date, tm}
+	}
+	if sortKey != SortPriority {
+		// Urgent todos first in the same slot
+		keys = append(keys, priority)
+	}
+
+	id := t.Id
+	if id == 0 {
+		id = t.OriginalId
+	}
+	return append(keys, fmt.Sprintf("%020d", id), date)
+}
+
+func formatTimestamp(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// Keys a sort before keys b
+func keysLess(a []string, b []string, order string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		if order == OrderDesc {
+			return a[i] > b[i]
+		}
+		return a[i] < b[i]
+	}
+	return false
+}
+
+// SortTodos sorts todos by `SortKeys`
+func SortTodos(todos []Todo, sortKey string, order string) {
+	sort.SliceStable(todos, func(i, j int) bool {
+		return keysLess(SortKeys(todos[i], sortKey, order), SortKeys(todos[j], sortKey, order), order)
+	})
+}
+
+// SortsAfter returns true if the todo comes after the keys
+func SortsAfter(t Todo, sortKey string, order string, keys []string) bool {
+	return keysLess(keys, SortKeys(t, sortKey, order), order)
+}
+
+type cursor struct {
+	Sort  string   `json:"sort"`
+	Order string   `json:"order"`
+	Keys  []string `json:"keys"`
+}
+
+func encodeCursor(t Todo, sortKey string, order string) string {
+	b, _ := json.Marshal(cursor{sortKey, order, SortKeys(t, sortKey, order)})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Keys of the last todo of the previous page
+func decodeCursor(str string, sortKey string, order string) (keys []string, invalid bool) {
+	b, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return nil, true
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, true
+	}
+	if c.Sort != sortKey || c.Order != order || len(c.Keys) != len(SortKeys(Todo{}, sortKey, order)) {
+		// Cursor of another sort
+		return nil, true
+	}
+	return c.Keys, false
+}
@@ -0,0 +1,100 @@
+package todo
+
+import "time"
+
+// TodoStore persists todos and their repeat models.
+// Business rules (repeat successors, validation of dates, ...) live in this package,
+// implementations only read and write rows.
+type TodoStore interface {
+	// WithTx runs fn in a transaction.
+	// The changes made through the store passed to fn are discarded when fn returns an error.
+	// Nested calls run in a sub transaction of the outer one.
+	WithTx(fn func(s TodoStore) error) error
+
+	// Get returns the todo with `repeat.exceptions` ordered by date and `checklist` in order.
+	// `blocked_by` and `blocks` are ordered by id.
+	Get(userId uint64, id uint64) (t Todo, notFound bool, err error)
+	// List returns the todos matching q ordered by `SortKeys`, after `q.After` and at most `q.Limit`.
+	// Repeat schedules are not expanded.
+	List(userId uint64, q GetListQuery) (todos []Todo, err error)
+	// Search returns the todos matching q and the words of `text` in name or description,
+	// ranked by relevance with their scores. At most `q.Limit` todos are returned.
+	Search(userId uint64, text string, q GetListQuery) (todos []Todo, scores []float64, err error)
+
+	// Insert creates a todo.
+	// `t.Labels` replaces the labels of the todo in Insert and Update.
+	// Items of `t.Checklist` are created by Insert and ignored by Update.
+	// `t.BlockedBy` replaces the blockers of the todo, `t.Blocks` is ignored.
+	// Labels and blockers of other users are dropped from `t.Labels` and `t.BlockedBy`.
+	// `t.Repeat.Exceptions` is ignored by Insert and Update.
+	// If `t.Repeat.Id` is 0, a new repeat model is created,
+	// otherwise the todo shares the existing repeat model.
+	// Insert and Update return `sql.ErrNoRows` unless the repeat model `t.Repeat.Id` of the user exists.
+	Insert(userId uint64, t Todo) (inserted Todo, err error)
+	// Update overwrites all columns of the todo `t.Id`, `sql.ErrNoRows` unless the todo of the user exists.
+	// If `t.Repeat.Id` is 0, a new repeat model is created and attached,
+	// otherwise the existing repeat model is updated.
+	// A repeat model no longer used by any todo is deleted,
+	// unless a new repeat model is created, the old one keeps the rule of the earlier occurrences.
+	Update(userId uint64, t Todo) (updated Todo, err error)
+	// UpdateRepeatModel overwrites the repeat model `r.Id` without changing todos.
+	UpdateRepeatModel(userId uint64, r Repeat) (err error)
+	Delete(userId uint64, id uint64) (notFound bool, err error)
+	DeleteAll(userId uint64) (err error)
+
+	InsertOperation(userId uint64, op Operation) (err error)
+	// LastOperation returns the latest operation of `kind` on the todo.
+	LastOperation(userId uint64, todoId uint64, kind string) (op Operation, notFound bool, err error)
+	DeleteOperation(userId uint64, id uint64) (err error)
+
+	// InsertChecklistItem appends the item to the checklist of the todo.
+	InsertChecklistItem(userId uint64, todoId uint64, item ChecklistItem) (inserted ChecklistItem, err error)
+	// UpdateChecklistItem and UpdateLabel report notFound unless the item or the label of the user exists.
+	UpdateChecklistItem(userId uint64, todoId uint64, item ChecklistItem) (updated ChecklistItem, notFound bool, err error)
+	DeleteChecklistItem(userId uint64, todoId uint64, id uint64) (notFound bool, err error)
+	// ReorderChecklist sorts the checklist of the todo in the order of `ids`.
+	ReorderChecklist(userId uint64, todoId uint64, ids []uint64) (err error)
+
+	// ListDependencies returns all dependencies between todos of the user.
+	ListDependencies(userId uint64) (dependencies []Dependency, err error)
+
+	// ListLabels returns the labels of the user ordered by id.
+	ListLabels(userId uint64) (labels []Label, err error)
+	GetLabel(userId uint64, id uint64) (l Label, notFound bool, err error)
+	InsertLabel(userId uint64, l Label) (inserted Label, err error)
+	UpdateLabel(userId uint64, l Label) (updated Label, notFound bool, err error)
+	// DeleteLabel deletes the label and removes it from todos.
+	DeleteLabel(userId uint64, id uint64) (notFound bool, err error)
+
+	// PutFeedToken creates or replaces the hash of the feed token of the user.
+	PutFeedToken(userId uint64, hash string) (err error)
+	GetFeedTokenUser(hash string) (userId uint64, notFound bool, err error)
+	DeleteFeedToken(userId uint64) (notFound bool, err error)
+
+	// PutCalDAVResource creates or replaces the resource `r.Name` of the user.
+	// Resources are deleted with their todo.
+	PutCalDAVResource(userId uint64, r CalDAVResource) (err error)
+	// ListCalDAVResources returns the resources of the user ordered by name.
+	ListCalDAVResources(userId uint64) (resources []CalDAVResource, err error)
+	DeleteCalDAVResource(userId uint64, name string) (err error)
+
+	// PutException creates or replaces the exception of the occurrence on `e.Date`.
+	PutException(userId uint64, repeatModelId uint64, e RepeatException) (err error)
+	DeleteException(userId uint64, repeatModelId uint64, date string) (notFound bool, err error)
+	// DeleteExceptionsBefore deletes exceptions of the occurrences before `date`.
+	DeleteExceptionsBefore(userId uint64, repeatModelId uint64, date string) (err error)
+
+	// InsertOutboxEvent appends the event to the outbox, `e.Id` and `e.CreatedAt` are set by the store.
+	// The outbox is not scoped to the user and is kept by DeleteAll.
+	InsertOutboxEvent(e OutboxEvent) (err error)
+	// ListOutboxEvents returns the unpublished events after the id ordered by id, at most `limit`.
+	// Dead events are not listed.
+	ListOutboxEvents(after uint64, limit int) (events []OutboxEvent, err error)
+	// UpdateOutboxEvent overwrites the attempts, the error and the times of the event `e.Id`.
+	UpdateOutboxEvent(e OutboxEvent) (err error)
+	// OutboxStats returns the number of events to publish, the number of dead events
+	// and the creation time of the oldest event to publish.
+	OutboxStats() (pending int, dead int, oldest *time.Time, err error)
+	// DeletePublishedOutboxEvents deletes the events published before the time.
+	DeletePublishedOutboxEvents(before time.Time) (err error)
+}
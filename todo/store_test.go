@@ -0,0 +1,1033 @@
+package todo_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flow-todos/memory"
+	"flow-todos/migrate"
+	"flow-todos/mysql"
+	"flow-todos/todo"
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Conformance suite of the implementations of `todo.TodoStore`.
+// Each test runs on a new store as a new user, so a MySQL database can be shared by the tests.
+var storeTests = []struct {
+	name string
+	fn   func(t *testing.T, s todo.TodoStore, userId uint64)
+}{
+	{"NotFound", testNotFound},
+	{"ForeignIds", testForeignIds},
+	{"NestedWithTxRollback", testNestedWithTxRollback},
+	{"RepeatModelLifecycle", testRepeatModelLifecycle},
+	{"ChecklistNotFound", testChecklistNotFound},
+	{"LabelNotFound", testLabelNotFound},
+	{"InsertUpdate", testInsertUpdate},
+	{"ListFilters", testListFilters},
+	{"ListSortCursor", testListSortCursor},
+	{"Search", testSearch},
+	{"RepeatExceptions", testRepeatExceptions},
+	{"Operations", testOperations},
+	{"Checklist", testChecklist},
+	{"Dependencies", testDependencies},
+	{"Labels", testLabels},
+	{"FeedTokens", testFeedTokens},
+	{"CalDAVResources", testCalDAVResources},
+	{"Outbox", testOutbox},
+	{"DeleteAll", testDeleteAll},
+}
+
+var lastUserId = uint64(time.Now().UnixNano() / int64(time.Millisecond))
+
+func newUserId() uint64 {
+	return atomic.AddUint64(&lastUserId, 2)
+}
+
+func testTodoStore(t *testing.T, newStore func(t *testing.T) todo.TodoStore) {
+	for _, test := range storeTests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, newStore(t), newUserId())
+		})
+	}
+}
+
+func TestMemoryTodoStore(t *testing.T) {
+	testTodoStore(t, func(t *testing.T) todo.TodoStore {
+		return memory.NewTodoStore()
+	})
+}
+
+// Runs on the database of `TEST_MYSQL_DSN`, like `user:password@tcp(localhost:3306)/flow-todos-test`
+func TestMySQLTodoStore(t *testing.T) {
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("`TEST_MYSQL_DSN` is not set")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	m, err := migrate.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	testTodoStore(t, func(t *testing.T) todo.TodoStore {
+		return mysql.NewTodoStore(db)
+	})
+}
+
+func insertTodo(t *testing.T, s todo.TodoStore, userId uint64, td todo.Todo) todo.Todo {
+	t.Helper()
+	if td.ExecutionTime == 0 {
+		td.ExecutionTime = 15
+	}
+	inserted, err := s.Insert(userId, td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inserted
+}
+
+func getTodo(t *testing.T, s todo.TodoStore, userId uint64, id uint64) (td todo.Todo, notFound bool) {
+	t.Helper()
+	td, notFound, err := s.Get(userId, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func testNotFound(t *testing.T, s todo.TodoStore, userId uint64) {
+	td := insertTodo(t, s, userId, todo.Todo{Name: "a"})
+	otherUserId := userId + 1
+
+	if _, notFound := getTodo(t, s, otherUserId, td.Id); !notFound {
+		t.Error("Get of the todo of another user is found")
+	}
+	notFound, err := s.Delete(otherUserId, td.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !notFound {
+		t.Error("Delete of the todo of another user is found")
+	}
+	if _, notFound := getTodo(t, s, userId, td.Id); notFound {
+		t.Error("todo is deleted by another user")
+	}
+	changed := td
+	changed.Name = "changed"
+	if _, err = s.Update(otherUserId, changed); err != sql.ErrNoRows {
+		t.Errorf("Update of the todo of another user returned %v, want sql.ErrNoRows", err)
+	}
+	if got, _ := getTodo(t, s, userId, td.Id); got.Name != "a" {
+		t.Errorf("todo is updated by another user: %+v", got)
+	}
+
+	if notFound, err = s.Delete(userId, td.Id); err != nil || notFound {
+		t.Fatalf("Delete: notFound %v, err %v", notFound, err)
+	}
+	if notFound, err = s.Delete(userId, td.Id); err != nil || !notFound {
+		t.Errorf("Delete of a deleted todo: notFound %v, err %v", notFound, err)
+	}
+	if _, err = s.Update(userId, changed); err != sql.ErrNoRows {
+		t.Errorf("Update of a deleted todo returned %v, want sql.ErrNoRows", err)
+	}
+	if _, notFound := getTodo(t, s, userId, td.Id); !notFound {
+		t.Error("deleted todo is created by Update")
+	}
+}
+
+func testForeignIds(t *testing.T, s todo.TodoStore, userId uint64) {
+	otherUserId := userId + 1
+	other := insertTodo(t, s, otherUserId, todo.Todo{Name: "other", Date: stringPtr("2026-01-01"), Repeat: &todo.Repeat{Unit: "day", From: todo.RepeatFromSchedule}})
+	otherLabel, err := s.InsertLabel(otherUserId, todo.Label{Name: "other", Color: "#0000ff"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	label, err := s.InsertLabel(userId, todo.Label{Name: "own", Color: "#ff0000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocker := insertTodo(t, s, userId, todo.Todo{Name: "blocker"})
+
+	// Labels and blockers of other users are dropped
+	td := insertTodo(t, s, userId, todo.Todo{Name: "a", Labels: []uint64{otherLabel.Id, label.Id}, BlockedBy: []uint64{other.Id, blocker.Id}})
+	got, _ := getTodo(t, s, userId, td.Id)
+	if len(got.Labels) != 1 || got.Labels[0] != label.Id || len(got.BlockedBy) != 1 || got.BlockedBy[0] != blocker.Id {
+		t.Errorf("Insert kept ids of another user: labels %v, blocked_by %v", got.Labels, got.BlockedBy)
+	}
+	got.Labels = []uint64{label.Id, otherLabel.Id}
+	got.BlockedBy = []uint64{blocker.Id, other.Id}
+	if _, err = s.Update(userId, got); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = getTodo(t, s, userId, td.Id)
+	if len(got.Labels) != 1 || got.Labels[0] != label.Id || len(got.BlockedBy) != 1 || got.BlockedBy[0] != blocker.Id {
+		t.Errorf("Update kept ids of another user: labels %v, blocked_by %v", got.Labels, got.BlockedBy)
+	}
+	if gotOther, _ := getTodo(t, s, otherUserId, other.Id); len(gotOther.Blocks) != 0 {
+		t.Errorf("todo of another user blocks the todo: %v", gotOther.Blocks)
+	}
+
+	// Repeat models of other users are neither shared nor overwritten
+	if _, err = s.Insert(userId, todo.Todo{Name: "b", Date: stringPtr("2026-01-01"), Repeat: &todo.Repeat{Id: other.Repeat.Id}}); err != sql.ErrNoRows {
+		t.Errorf("Insert with the repeat model of another user returned %v, want sql.ErrNoRows", err)
+	}
+	got.Date = stringPtr("2026-01-01")
+	got.Repeat = &todo.Repeat{Id: other.Repeat.Id, Unit: "week", Days: []todo.RepeatDay{{Day: 1}}, From: todo.RepeatFromSchedule}
+	if _, err = s.Update(userId, got); err != sql.ErrNoRows {
+		t.Errorf("Update with the repeat model of another user returned %v, want sql.ErrNoRows", err)
+	}
+	if err = s.UpdateRepeatModel(userId, *got.Repeat); err != nil {
+		t.Fatal(err)
+	}
+	gotOther, _ := getTodo(t, s, otherUserId, other.Id)
+	if gotOther.Repeat == nil || gotOther.Repeat.Unit != "day" {
+		t.Errorf("repeat model of another user is overwritten: %+v", gotOther.Repeat)
+	}
+	if got, _ = getTodo(t, s, userId, td.Id); got.Repeat != nil {
+		t.Errorf("todo shares the repeat model of another user: %+v", got.Repeat)
+	}
+}
+
+func testNestedWithTxRollback(t *testing.T, s todo.TodoStore, userId uint64) {
+	errRollback := errors.New("rollback")
+
+	var outer, inner todo.Todo
+	err := s.WithTx(func(s todo.TodoStore) (err error) {
+		outer = insertTodo(t, s, userId, todo.Todo{Name: "outer"})
+		err = s.WithTx(func(s todo.TodoStore) error {
+			inner = insertTodo(t, s, userId, todo.Todo{Name: "inner"})
+			return errRollback
+		})
+		if err != errRollback {
+			t.Errorf("nested WithTx returned %v, want the error of fn", err)
+		}
+		// The outer transaction continues after the rollback of the nested one
+		if _, notFound := getTodo(t, s, userId, outer.Id); notFound {
+			t.Error("todo of the outer transaction is rolled back by the nested one")
+		}
+		if _, notFound := getTodo(t, s, userId, inner.Id); !notFound {
+			t.Error("todo of the rolled back nested transaction is found in the outer one")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, notFound := getTodo(t, s, userId, outer.Id); notFound {
+		t.Error("todo of the committed transaction is not found")
+	}
+	if _, notFound := getTodo(t, s, userId, inner.Id); !notFound {
+		t.Error("todo of the rolled back nested transaction is committed")
+	}
+
+	// Rollback of the outer transaction discards the committed nested one
+	var discarded todo.Todo
+	err = s.WithTx(func(s todo.TodoStore) error {
+		err := s.WithTx(func(s todo.TodoStore) error {
+			discarded = insertTodo(t, s, userId, todo.Todo{Name: "discarded"})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Errorf("WithTx returned %v, want the error of fn", err)
+	}
+	if _, notFound := getTodo(t, s, userId, discarded.Id); !notFound {
+		t.Error("todo of the rolled back transaction is committed")
+	}
+}
+
+func testRepeatModelLifecycle(t *testing.T, s todo.TodoStore, userId uint64) {
+	// New repeat model
+	first := insertTodo(t, s, userId, todo.Todo{Name: "first", Date: stringPtr("2026-01-01"), Repeat: &todo.Repeat{Unit: "day", From: todo.RepeatFromSchedule}})
+	if first.Repeat == nil || first.Repeat.Id == 0 {
+		t.Fatal("Insert did not create the repeat model")
+	}
+	repeatId := first.Repeat.Id
+	err := s.PutException(userId, repeatId, todo.RepeatException{Date: "2026-01-05", Cancelled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Shared repeat model
+	second := insertTodo(t, s, userId, todo.Todo{Name: "second", Date: stringPtr("2026-01-02"), Repeat: &todo.Repeat{Id: repeatId}})
+	got, _ := getTodo(t, s, userId, second.Id)
+	if got.Repeat == nil || got.Repeat.Id != repeatId || got.Repeat.Unit != "day" {
+		t.Fatalf("todo does not share the repeat model %d: %+v", repeatId, got.Repeat)
+	}
+
+	// The repeat model is kept while a todo uses it
+	notFound, err := s.Delete(userId, first.Id)
+	if err != nil || notFound {
+		t.Fatalf("Delete: notFound %v, err %v", notFound, err)
+	}
+	got, _ = getTodo(t, s, userId, second.Id)
+	if got.Repeat == nil || got.Repeat.Id != repeatId {
+		t.Fatalf("repeat model of the remaining todo is deleted: %+v", got.Repeat)
+	}
+	if len(got.Repeat.Exceptions) != 1 || got.Repeat.Exceptions[0].Date != "2026-01-05" || !got.Repeat.Exceptions[0].Cancelled {
+		t.Errorf("exceptions of the shared repeat model are not kept: %+v", got.Repeat.Exceptions)
+	}
+
+	// The old repeat model is kept when the todo gets a new one
+	got.Repeat = &todo.Repeat{Unit: "week", Days: []todo.RepeatDay{{Day: 1}}, From: todo.RepeatFromSchedule}
+	got, err = s.Update(userId, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Repeat == nil || got.Repeat.Id == 0 || got.Repeat.Id == repeatId {
+		t.Fatalf("Update did not create a new repeat model: %+v", got.Repeat)
+	}
+	earlier := insertTodo(t, s, userId, todo.Todo{Name: "earlier", Date: stringPtr("2026-01-01"), Repeat: &todo.Repeat{Id: repeatId}})
+	gotEarlier, _ := getTodo(t, s, userId, earlier.Id)
+	if gotEarlier.Repeat == nil || gotEarlier.Repeat.Unit != "day" || len(gotEarlier.Repeat.Exceptions) != 1 {
+		t.Errorf("old repeat model is deleted: %+v", gotEarlier.Repeat)
+	}
+
+	// Detached from the repeat
+	got.Repeat = nil
+	if _, err = s.Update(userId, got); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = getTodo(t, s, userId, second.Id)
+	if got.Repeat != nil {
+		t.Errorf("repeat is not removed: %+v", got.Repeat)
+	}
+
+	// Todos sharing a repeat model are deleted together
+	third := insertTodo(t, s, userId, todo.Todo{Name: "third", Date: stringPtr("2026-01-03"), Repeat: &todo.Repeat{Unit: "week", Days: []todo.RepeatDay{{Day: 6}}, From: todo.RepeatFromSchedule}})
+	fourth := insertTodo(t, s, userId, todo.Todo{Name: "fourth", Date: stringPtr("2026-01-10"), Repeat: &todo.Repeat{Id: third.Repeat.Id}})
+	if err = s.DeleteAll(userId); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []uint64{second.Id, third.Id, fourth.Id, earlier.Id} {
+		if _, notFound := getTodo(t, s, userId, id); !notFound {
+			t.Errorf("todo %d is not deleted by DeleteAll", id)
+		}
+	}
+}
+
+func testChecklistNotFound(t *testing.T, s todo.TodoStore, userId uint64) {
+	td := insertTodo(t, s, userId, todo.Todo{Name: "a", Checklist: []todo.ChecklistItem{{Name: "x"}}})
+	got, _ := getTodo(t, s, userId, td.Id)
+	if len(got.Checklist) != 1 {
+		t.Fatalf("checklist is not inserted: %+v", got.Checklist)
+	}
+	item := got.Checklist[0]
+	otherUserId := userId + 1
+
+	// Unchanged item is found
+	_, notFound, err := s.UpdateChecklistItem(userId, td.Id, item)
+	if err != nil || notFound {
+		t.Errorf("UpdateChecklistItem without changes: notFound %v, err %v", notFound, err)
+	}
+	missing := item
+	missing.Id += 1000
+	if _, notFound, err = s.UpdateChecklistItem(userId, td.Id, missing); err != nil || !notFound {
+		t.Errorf("UpdateChecklistItem of a missing item: notFound %v, err %v", notFound, err)
+	}
+	if _, notFound, err = s.UpdateChecklistItem(otherUserId, td.Id, item); err != nil || !notFound {
+		t.Errorf("UpdateChecklistItem of another user: notFound %v, err %v", notFound, err)
+	}
+
+	if notFound, err = s.DeleteChecklistItem(userId, td.Id, missing.Id); err != nil || !notFound {
+		t.Errorf("DeleteChecklistItem of a missing item: notFound %v, err %v", notFound, err)
+	}
+	if notFound, err = s.DeleteChecklistItem(otherUserId, td.Id, item.Id); err != nil || !notFound {
+		t.Errorf("DeleteChecklistItem of another user: notFound %v, err %v", notFound, err)
+	}
+	if notFound, err = s.DeleteChecklistItem(userId, td.Id, item.Id); err != nil || notFound {
+		t.Errorf("DeleteChecklistItem: notFound %v, err %v", notFound, err)
+	}
+}
+
+func testLabelNotFound(t *testing.T, s todo.TodoStore, userId uint64) {
+	l, err := s.InsertLabel(userId, todo.Label{Name: "work", Color: "#ff0000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherUserId := userId + 1
+
+	if _, notFound, err := s.GetLabel(otherUserId, l.Id); err != nil || !notFound {
+		t.Errorf("GetLabel of another user: notFound %v, err %v", notFound, err)
+	}
+
+	// Unchanged label is found
+	_, notFound, err := s.UpdateLabel(userId, l)
+	if err != nil || notFound {
+		t.Errorf("UpdateLabel without changes: notFound %v, err %v", notFound, err)
+	}
+	if _, notFound, err = s.UpdateLabel(otherUserId, l); err != nil || !notFound {
+		t.Errorf("UpdateLabel of another user: notFound %v, err %v", notFound, err)
+	}
+
+	if notFound, err = s.DeleteLabel(otherUserId, l.Id); err != nil || !notFound {
+		t.Errorf("DeleteLabel of another user: notFound %v, err %v", notFound, err)
+	}
+	if notFound, err = s.DeleteLabel(userId, l.Id); err != nil || notFound {
+		t.Errorf("DeleteLabel: notFound %v, err %v", notFound, err)
+	}
+	if _, notFound, err = s.UpdateLabel(userId, l); err != nil || !notFound {
+		t.Errorf("UpdateLabel of a deleted label: notFound %v, err %v", notFound, err)
+	}
+}
+
+func todoIds(todos []todo.Todo) (ids []uint64) {
+	for _, td := range todos {
+		ids = append(ids, td.Id)
+	}
+	return
+}
+
+func sameIds(a []uint64, b []uint64) bool {
+	a, b = append([]uint64{}, a...), append([]uint64{}, b...)
+	sort.Slice(a, func(i, j int) bool { return a[i] < a[j] })
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func testInsertUpdate(t *testing.T, s todo.TodoStore, userId uint64) {
+	project, sprint, priority := uint64(3), uint64(4), uint(2)
+	td := insertTodo(t, s, userId, todo.Todo{
+		Name: "a", Description: stringPtr("b"), Date: stringPtr("2026-1-2"), Time: stringPtr("9:05"),
+		ExecutionTime: 30, ProjectId: &project, SprintId: &sprint, Priority: &priority,
+	})
+	got, _ := getTodo(t, s, userId, td.Id)
+	if got.Name != "a" || got.Description == nil || *got.Description != "b" || got.ExecutionTime != 30 ||
+		got.ProjectId == nil || *got.ProjectId != project || got.SprintId == nil || *got.SprintId != sprint ||
+		got.Priority == nil || *got.Priority != priority || got.Completed {
+		t.Errorf("inserted todo is not stored: %+v", got)
+	}
+	if got.Date == nil || *got.Date != "2026-01-02" || got.Time == nil || *got.Time != "09:05" {
+		t.Errorf("date and time are not formatted like `DATE` and `TIME_FORMAT`: %v %v", got.Date, got.Time)
+	}
+	if got.CreatedAt == nil || got.UpdatedAt == nil {
+		t.Errorf("timestamps are not set: %v %v", got.CreatedAt, got.UpdatedAt)
+	}
+
+	got.Name = "c"
+	got.Description = nil
+	got.Time = nil
+	got.Priority = nil
+	got.Completed = true
+	if _, err := s.Update(userId, got); err != nil {
+		t.Fatal(err)
+	}
+	updated, _ := getTodo(t, s, userId, td.Id)
+	if updated.Name != "c" || updated.Description != nil || updated.Time != nil || updated.Priority != nil || !updated.Completed {
+		t.Errorf("todo is not updated: %+v", updated)
+	}
+	if updated.CreatedAt == nil || !updated.CreatedAt.Equal(*got.CreatedAt) {
+		t.Errorf("created_at is changed from %v to %v", got.CreatedAt, updated.CreatedAt)
+	}
+}
+
+func testListFilters(t *testing.T, s todo.TodoStore, userId uint64) {
+	label, err := s.InsertLabel(userId, todo.Label{Name: "l", Color: "#00ff00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, sprint, high := uint64(10), uint64(20), uint(3)
+	blocker := insertTodo(t, s, userId, todo.Todo{Name: "blocker", Date: stringPtr("2026-02-01"), Time: stringPtr("09:00")})
+	dated := insertTodo(t, s, userId, todo.Todo{
+		Name: "dated", Description: stringPtr("Quarterly report"), Date: stringPtr("2026-02-10"), Time: stringPtr("18:30"),
+		ExecutionTime: 60, ProjectId: &project, SprintId: &sprint, Priority: &high, Labels: []uint64{label.Id}, BlockedBy: []uint64{blocker.Id},
+	})
+	undated := insertTodo(t, s, userId, todo.Todo{Name: "undated", ExecutionTime: 5})
+	repeating := insertTodo(t, s, userId, todo.Todo{Name: "repeating", Date: stringPtr("2026-02-15"), Repeat: &todo.Repeat{Unit: "day", From: todo.RepeatFromSchedule}})
+	completed := insertTodo(t, s, userId, todo.Todo{Name: "completed", Date: stringPtr("2026-02-05"), Completed: true})
+	insertTodo(t, s, userId+1, todo.Todo{Name: "dated", Date: stringPtr("2026-02-10")})
+
+	at := func(str string) *time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &tm
+	}
+	yes, no := true, false
+	uintPtr := func(u uint) *uint { return &u }
+	tests := []struct {
+		name string
+		q    todo.GetListQuery
+		want []todo.Todo
+	}{
+		{"Incomplete", todo.GetListQuery{}, []todo.Todo{blocker, dated, undated, repeating}},
+		{"WithCompleted", todo.GetListQuery{WithCompleted: true}, []todo.Todo{blocker, dated, undated, repeating, completed}},
+		{"OnlyCompleted", todo.GetListQuery{OnlyCompleted: true}, []todo.Todo{completed}},
+		{"StartEnd", todo.GetListQuery{Start: at("2026-02-10 00:00"), End: at("2026-02-14 00:00")}, []todo.Todo{dated}},
+		{"Start", todo.GetListQuery{Start: at("2026-02-10 18:30")}, []todo.Todo{dated, repeating}},
+		{"End", todo.GetListQuery{End: at("2026-02-01 09:00")}, []todo.Todo{blocker}},
+		{"ProjectIds", todo.GetListQuery{ProjectIds: []uint64{project}}, []todo.Todo{dated}},
+		{"NoProjectIds", todo.GetListQuery{ProjectIds: []uint64{}}, nil},
+		{"SprintId", todo.GetListQuery{SprintId: &sprint}, []todo.Todo{dated}},
+		{"HasDate", todo.GetListQuery{HasDate: &yes}, []todo.Todo{blocker, dated, repeating}},
+		{"HasNoDate", todo.GetListQuery{HasDate: &no}, []todo.Todo{undated}},
+		{"IsRepeating", todo.GetListQuery{IsRepeating: &yes}, []todo.Todo{repeating}},
+		{"IsNotRepeating", todo.GetListQuery{IsRepeating: &no}, []todo.Todo{blocker, dated, undated}},
+		{"OnlyRepeatModel", todo.GetListQuery{OnlyRepeatModel: true}, []todo.Todo{repeating}},
+		{"TextInDescription", todo.GetListQuery{Text: stringPtr("quarterly")}, []todo.Todo{dated}},
+		{"TextInName", todo.GetListQuery{Text: stringPtr("DATED")}, []todo.Todo{dated, undated}},
+		{"MinExecutionTime", todo.GetListQuery{MinExecutionTime: uintPtr(60)}, []todo.Todo{dated}},
+		{"MaxExecutionTime", todo.GetListQuery{MaxExecutionTime: uintPtr(5)}, []todo.Todo{undated}},
+		{"Priorities", todo.GetListQuery{Priorities: []uint{high}}, []todo.Todo{dated}},
+		{"NoPriority", todo.GetListQuery{Priorities: []uint{0}}, []todo.Todo{blocker, undated, repeating}},
+		{"Blocked", todo.GetListQuery{Blocked: &yes}, []todo.Todo{dated}},
+		{"NotBlocked", todo.GetListQuery{Blocked: &no}, []todo.Todo{blocker, undated, repeating}},
+		{"Labels", todo.GetListQuery{Labels: []uint64{label.Id}}, []todo.Todo{dated}},
+	}
+	for _, test := range tests {
+		todos, err := s.List(userId, test.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sameIds(todoIds(todos), todoIds(test.want)) {
+			t.Errorf("%s: List returned %v, want %v", test.name, todoIds(todos), todoIds(test.want))
+		}
+	}
+
+	// Completed blockers do not block
+	blocker.Completed = true
+	if _, err = s.Update(userId, blocker); err != nil {
+		t.Fatal(err)
+	}
+	todos, err := s.List(userId, todo.GetListQuery{Blocked: &yes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 0 {
+		t.Errorf("todos of a completed blocker are blocked: %v", todoIds(todos))
+	}
+}
+
+func testListSortCursor(t *testing.T, s todo.TodoStore, userId uint64) {
+	low, high := uint(1), uint(3)
+	for _, td := range []todo.Todo{
+		{Name: "c", Date: stringPtr("2026-03-02"), Time: stringPtr("10:00"), ExecutionTime: 30},
+		{Name: "a", Date: stringPtr("2026-03-02"), Priority: &high, ExecutionTime: 30},
+		{Name: "e", Date: stringPtr("2026-03-01"), Time: stringPtr("08:00"), Priority: &low},
+		{Name: "b", ExecutionTime: 60},
+		{Name: "d", Date: stringPtr("2026-03-02"), Time: stringPtr("10:00"), Priority: &high},
+	} {
+		insertTodo(t, s, userId, td)
+	}
+
+	for _, sortKey := range []string{todo.SortDate, todo.SortTime, todo.SortName, todo.SortExecutionTime, todo.SortPriority} {
+		for _, order := range []string{todo.OrderAsc, todo.OrderDesc} {
+			all, err := s.List(userId, todo.GetListQuery{Sort: sortKey, Order: order})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(all) != 5 {
+				t.Fatalf("%s %s: List returned %d todos, want 5", sortKey, order, len(all))
+			}
+			for i := 1; i < len(all); i++ {
+				if !todo.SortsAfter(all[i], sortKey, order, todo.SortKeys(all[i-1], sortKey, order)) {
+					t.Errorf("%s %s: %q is listed after %q", sortKey, order, all[i].Name, all[i-1].Name)
+				}
+			}
+
+			// Pages after the keys of the last todo
+			var paged []todo.Todo
+			q := todo.GetListQuery{Sort: sortKey, Order: order, Limit: 2}
+			for i := 0; i < 5; i++ {
+				page, err := s.List(userId, q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(page) > 2 {
+					t.Fatalf("%s %s: page of %d todos, limit 2", sortKey, order, len(page))
+				}
+				if len(page) == 0 {
+					break
+				}
+				paged = append(paged, page...)
+				q.After = todo.SortKeys(page[len(page)-1], sortKey, order)
+			}
+			if fmt.Sprint(todoIds(paged)) != fmt.Sprint(todoIds(all)) {
+				t.Errorf("%s %s: pages are %v, want %v", sortKey, order, todoIds(paged), todoIds(all))
+			}
+		}
+	}
+}
+
+func testSearch(t *testing.T, s todo.TodoStore, userId uint64) {
+	name := insertTodo(t, s, userId, todo.Todo{Name: "zebra crossing"})
+	description := insertTodo(t, s, userId, todo.Todo{Name: "quiet evening", Description: stringPtr("watch a zebra documentary")})
+	insertTodo(t, s, userId, todo.Todo{Name: "zebra", Completed: true})
+	insertTodo(t, s, userId, todo.Todo{Name: "unrelated"})
+	insertTodo(t, s, userId+1, todo.Todo{Name: "zebra"})
+
+	todos, scores, err := s.Search(userId, "zebra", todo.GetListQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameIds(todoIds(todos), []uint64{name.Id, description.Id}) || len(scores) != len(todos) {
+		t.Fatalf("Search returned %v with scores %v, want %v", todoIds(todos), scores, []uint64{name.Id, description.Id})
+	}
+	for i := range scores {
+		if scores[i] <= 0 || (i > 0 && scores[i] > scores[i-1]) {
+			t.Errorf("scores are not positive in descending order: %v", scores)
+		}
+	}
+
+	todos, scores, err = s.Search(userId, "zebra", todo.GetListQuery{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 1 || len(scores) != 1 {
+		t.Errorf("Search with limit 1 returned %v", todoIds(todos))
+	}
+	if todos, _, err = s.Search(userId, "giraffe", todo.GetListQuery{}); err != nil || len(todos) != 0 {
+		t.Errorf("Search of a missing word returned %v, err %v", todoIds(todos), err)
+	}
+}
+
+func testRepeatExceptions(t *testing.T, s todo.TodoStore, userId uint64) {
+	td := insertTodo(t, s, userId, todo.Todo{Name: "a", Date: stringPtr("2026-01-01"), Repeat: &todo.Repeat{Unit: "day", From: todo.RepeatFromSchedule}})
+	repeatId := td.Repeat.Id
+	otherUserId := userId + 1
+	for _, e := range []todo.RepeatException{
+		{Date: "2026-01-08", Cancelled: true},
+		{Date: "2026-01-03", Cancelled: true},
+		{Date: "2026-01-05", NewDate: stringPtr("2026-01-06"), Time: stringPtr("10:00"), Name: stringPtr("moved")},
+		// Replaces the exception of the date
+		{Date: "2026-01-03", Name: stringPtr("renamed")},
+	} {
+		if err := s.PutException(userId, repeatId, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.PutException(otherUserId, repeatId, todo.RepeatException{Date: "2026-01-04", Cancelled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := getTodo(t, s, userId, td.Id)
+	exceptions := got.Repeat.Exceptions
+	if len(exceptions) != 3 || exceptions[0].Date != "2026-01-03" || exceptions[1].Date != "2026-01-05" || exceptions[2].Date != "2026-01-08" {
+		t.Fatalf("exceptions are not ordered by date or another user put one: %+v", exceptions)
+	}
+	if exceptions[0].Cancelled || exceptions[0].Name == nil || *exceptions[0].Name != "renamed" {
+		t.Errorf("exception is not replaced: %+v", exceptions[0])
+	}
+	moved := exceptions[1]
+	if moved.NewDate == nil || *moved.NewDate != "2026-01-06" || moved.Time == nil || *moved.Time != "10:00" || moved.Name == nil || *moved.Name != "moved" {
+		t.Errorf("exception is not stored: %+v", moved)
+	}
+
+	if notFound, err := s.DeleteException(otherUserId, repeatId, "2026-01-05"); err != nil || !notFound {
+		t.Errorf("DeleteException of another user: notFound %v, err %v", notFound, err)
+	}
+	if notFound, err := s.DeleteException(userId, repeatId, "2026-01-05"); err != nil || notFound {
+		t.Errorf("DeleteException: notFound %v, err %v", notFound, err)
+	}
+	if notFound, err := s.DeleteException(userId, repeatId, "2026-01-05"); err != nil || !notFound {
+		t.Errorf("DeleteException of a deleted exception: notFound %v, err %v", notFound, err)
+	}
+
+	if err := s.DeleteExceptionsBefore(otherUserId, repeatId, "2026-12-31"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteExceptionsBefore(userId, repeatId, "2026-01-08"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = getTodo(t, s, userId, td.Id)
+	if len(got.Repeat.Exceptions) != 1 || got.Repeat.Exceptions[0].Date != "2026-01-08" {
+		t.Errorf("exceptions before 2026-01-08 are not the ones deleted: %+v", got.Repeat.Exceptions)
+	}
+}
+
+func testOperations(t *testing.T, s todo.TodoStore, userId uint64) {
+	td := insertTodo(t, s, userId, todo.Todo{Name: "a"})
+	otherUserId := userId + 1
+	for i, kind := range []string{todo.OperationComplete, todo.OperationComplete, todo.OperationSkip} {
+		err := s.InsertOperation(userId, todo.Operation{TodoId: td.Id, Kind: kind, Data: []byte(fmt.Sprintf(`{"n": %d}`, i))})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	last := func(userId uint64, kind string) (op todo.Operation, n int, notFound bool) {
+		t.Helper()
+		op, notFound, err := s.LastOperation(userId, td.Id, kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if notFound {
+			return
+		}
+		var data struct{ N int }
+		if err = json.Unmarshal(op.Data, &data); err != nil {
+			t.Fatal(err)
+		}
+		if op.TodoId != td.Id || op.Kind != kind {
+			t.Errorf("operation is not stored: %+v", op)
+		}
+		return op, data.N, false
+	}
+
+	op, n, notFound := last(userId, todo.OperationComplete)
+	if notFound || n != 1 {
+		t.Fatalf("last complete is %d, notFound %v, want 1", n, notFound)
+	}
+	if _, n, notFound = last(userId, todo.OperationSkip); notFound || n != 2 {
+		t.Errorf("last skip is %d, notFound %v, want 2", n, notFound)
+	}
+	if _, _, notFound = last(otherUserId, todo.OperationComplete); !notFound {
+		t.Error("operation of another user is found")
+	}
+
+	if err := s.DeleteOperation(otherUserId, op.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, n, _ = last(userId, todo.OperationComplete); n != 1 {
+		t.Error("operation is deleted by another user")
+	}
+	if err := s.DeleteOperation(userId, op.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, n, notFound = last(userId, todo.OperationComplete); notFound || n != 0 {
+		t.Errorf("last complete after the delete is %d, notFound %v, want 0", n, notFound)
+	}
+
+	// Operations are deleted with the todo
+	if _, err := s.Delete(userId, td.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, notFound = last(userId, todo.OperationComplete); !notFound {
+		t.Error("operation of a deleted todo is found")
+	}
+}
+
+func testChecklist(t *testing.T, s todo.TodoStore, userId uint64) {
+	td := insertTodo(t, s, userId, todo.Todo{Name: "a", Checklist: []todo.ChecklistItem{{Name: "x"}, {Name: "y", Checked: true}}})
+	otherUserId := userId + 1
+	z, err := s.InsertChecklistItem(userId, td.Id, todo.ChecklistItem{Name: "z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.InsertChecklistItem(otherUserId, td.Id, todo.ChecklistItem{Name: "w"}); err != nil {
+		t.Fatal(err)
+	}
+	names := func() (names []string) {
+		t.Helper()
+		got, _ := getTodo(t, s, userId, td.Id)
+		for _, item := range got.Checklist {
+			names = append(names, item.Name)
+		}
+		return
+	}
+	if got := fmt.Sprint(names()); got != "[x y z]" {
+		t.Fatalf("checklist is %s, want [x y z]", got)
+	}
+	got, _ := getTodo(t, s, userId, td.Id)
+	if got.Checklist[2].Id != z.Id || !got.Checklist[1].Checked {
+		t.Errorf("checklist items are not stored: %+v", got.Checklist)
+	}
+	x, y := got.Checklist[0], got.Checklist[1]
+
+	if err = s.ReorderChecklist(userId, td.Id, []uint64{z.Id, x.Id, y.Id}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.ReorderChecklist(otherUserId, td.Id, []uint64{y.Id, x.Id, z.Id}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(names()); got != "[z x y]" {
+		t.Errorf("reordered checklist is %s, want [z x y]", got)
+	}
+
+	// Ignored by Update
+	got, _ = getTodo(t, s, userId, td.Id)
+	got.Checklist = nil
+	if _, err = s.Update(userId, got); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(names()); got != "[z x y]" {
+		t.Errorf("checklist after Update is %s, want [z x y]", got)
+	}
+}
+
+func testDependencies(t *testing.T, s todo.TodoStore, userId uint64) {
+	a := insertTodo(t, s, userId, todo.Todo{Name: "a"})
+	b := insertTodo(t, s, userId, todo.Todo{Name: "b"})
+	c := insertTodo(t, s, userId, todo.Todo{Name: "c", BlockedBy: []uint64{b.Id, a.Id}})
+	d := insertTodo(t, s, userId, todo.Todo{Name: "d", BlockedBy: []uint64{a.Id}})
+	dependencies := func(userId uint64) string {
+		t.Helper()
+		list, err := s.ListDependencies(userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var strs []string
+		for _, d := range list {
+			strs = append(strs, fmt.Sprintf("%d-%d", d.TodoId, d.BlockerId))
+		}
+		sort.Strings(strs)
+		return fmt.Sprint(strs)
+	}
+	pair := func(todo todo.Todo, blocker todo.Todo) string {
+		return fmt.Sprintf("%d-%d", todo.Id, blocker.Id)
+	}
+
+	want := []string{pair(c, a), pair(c, b), pair(d, a)}
+	sort.Strings(want)
+	if got := dependencies(userId); got != fmt.Sprint(want) {
+		t.Errorf("ListDependencies returned %s, want %v", got, want)
+	}
+	if got := dependencies(userId + 1); got != "[]" {
+		t.Errorf("ListDependencies of another user returned %s", got)
+	}
+	gotA, _ := getTodo(t, s, userId, a.Id)
+	gotC, _ := getTodo(t, s, userId, c.Id)
+	if fmt.Sprint(gotA.Blocks) != fmt.Sprint([]uint64{c.Id, d.Id}) || fmt.Sprint(gotC.BlockedBy) != fmt.Sprint([]uint64{a.Id, b.Id}) {
+		t.Errorf("blocks %v and blocked_by %v are not ordered by id", gotA.Blocks, gotC.BlockedBy)
+	}
+
+	// Dependencies are deleted with the blocker
+	if _, err := s.Delete(userId, a.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got := dependencies(userId); got != fmt.Sprint([]string{pair(c, b)}) {
+		t.Errorf("ListDependencies after the delete of the blocker returned %s", got)
+	}
+	gotC, _ = getTodo(t, s, userId, c.Id)
+	if fmt.Sprint(gotC.BlockedBy) != fmt.Sprint([]uint64{b.Id}) {
+		t.Errorf("blocked_by after the delete of the blocker is %v", gotC.BlockedBy)
+	}
+}
+
+func testLabels(t *testing.T, s todo.TodoStore, userId uint64) {
+	work, err := s.InsertLabel(userId, todo.Label{Name: "work", Color: "#ff0000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	home, err := s.InsertLabel(userId, todo.Label{Name: "home", Color: "#00ff00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.InsertLabel(userId+1, todo.Label{Name: "other", Color: "#0000ff"}); err != nil {
+		t.Fatal(err)
+	}
+	labels, err := s.ListLabels(userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(labels) != fmt.Sprint([]todo.Label{work, home}) {
+		t.Errorf("ListLabels returned %v, want %v", labels, []todo.Label{work, home})
+	}
+	work.Color = "#ff8800"
+	if _, _, err = s.UpdateLabel(userId, work); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := s.GetLabel(userId, work.Id); err != nil || got != work {
+		t.Errorf("GetLabel returned %v, err %v, want %v", got, err, work)
+	}
+
+	// Deleted labels are removed from todos
+	td := insertTodo(t, s, userId, todo.Todo{Name: "a", Labels: []uint64{home.Id, work.Id}})
+	if _, err = s.DeleteLabel(userId, work.Id); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := getTodo(t, s, userId, td.Id)
+	if fmt.Sprint(got.Labels) != fmt.Sprint([]uint64{home.Id}) {
+		t.Errorf("labels of the todo are %v, want %v", got.Labels, []uint64{home.Id})
+	}
+}
+
+func testFeedTokens(t *testing.T, s todo.TodoStore, userId uint64) {
+	hash, replaced := fmt.Sprintf("a%063x", userId), fmt.Sprintf("b%063x", userId)
+	if _, notFound, err := s.GetFeedTokenUser(hash); err != nil || !notFound {
+		t.Errorf("GetFeedTokenUser of a missing token: notFound %v, err %v", notFound, err)
+	}
+	if notFound, err := s.DeleteFeedToken(userId); err != nil || !notFound {
+		t.Errorf("DeleteFeedToken of a missing token: notFound %v, err %v", notFound, err)
+	}
+
+	for _, h := range []string{hash, replaced} {
+		if err := s.PutFeedToken(userId, h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, notFound, err := s.GetFeedTokenUser(hash); err != nil || !notFound {
+		t.Errorf("GetFeedTokenUser of a replaced token: notFound %v, err %v", notFound, err)
+	}
+	if got, notFound, err := s.GetFeedTokenUser(replaced); err != nil || notFound || got != userId {
+		t.Errorf("GetFeedTokenUser returned %d, notFound %v, err %v, want %d", got, notFound, err, userId)
+	}
+
+	if notFound, err := s.DeleteFeedToken(userId); err != nil || notFound {
+		t.Errorf("DeleteFeedToken: notFound %v, err %v", notFound, err)
+	}
+	if _, notFound, err := s.GetFeedTokenUser(replaced); err != nil || !notFound {
+		t.Errorf("GetFeedTokenUser of a deleted token: notFound %v, err %v", notFound, err)
+	}
+}
+
+func testCalDAVResources(t *testing.T, s todo.TodoStore, userId uint64) {
+	a := insertTodo(t, s, userId, todo.Todo{Name: "a"})
+	b := insertTodo(t, s, userId, todo.Todo{Name: "b"})
+	otherUserId := userId + 1
+	for _, r := range []todo.CalDAVResource{
+		{Name: "b.ics", TodoId: a.Id, Uid: "uid-a"},
+		{Name: "a.ics", TodoId: b.Id, Uid: "uid-b"},
+		// Replaces the resource of the name
+		{Name: "b.ics", TodoId: b.Id, Uid: "uid-b2"},
+	} {
+		if err := s.PutCalDAVResource(userId, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.PutCalDAVResource(otherUserId, todo.CalDAVResource{Name: "a.ics", TodoId: a.Id, Uid: "uid-other"}); err != nil {
+		t.Fatal(err)
+	}
+	resources := func(userId uint64) string {
+		t.Helper()
+		list, err := s.ListCalDAVResources(userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprint(list)
+	}
+
+	want := []todo.CalDAVResource{{Name: "a.ics", TodoId: b.Id, Uid: "uid-b"}, {Name: "b.ics", TodoId: b.Id, Uid: "uid-b2"}}
+	if got := resources(userId); got != fmt.Sprint(want) {
+		t.Errorf("ListCalDAVResources returned %s, want %v", got, want)
+	}
+	if got := resources(otherUserId); got != "[]" {
+		t.Errorf("resource of a todo of another user is put: %s", got)
+	}
+
+	if err := s.DeleteCalDAVResource(userId, "a.ics"); err != nil {
+		t.Fatal(err)
+	}
+	if got := resources(userId); got != fmt.Sprint(want[1:]) {
+		t.Errorf("ListCalDAVResources after the delete returned %s, want %v", got, want[1:])
+	}
+
+	// Resources are deleted with their todo
+	if _, err := s.Delete(userId, b.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got := resources(userId); got != "[]" {
+		t.Errorf("resource of a deleted todo is kept: %s", got)
+	}
+}
+
+func testOutbox(t *testing.T, s todo.TodoStore, userId uint64) {
+	// The outbox is shared by all users, events of other tests may be pending
+	pending, dead, _, err := s.OutboxStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, typ := range []string{"todo.created", "todo.updated"} {
+		if err = s.InsertOutboxEvent(todo.OutboxEvent{UserId: userId, Type: typ, Data: []byte(`{"todo":{}}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list := func(after uint64, limit int) (events []todo.OutboxEvent) {
+		t.Helper()
+		all, err := s.ListOutboxEvents(after, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range all {
+			if e.UserId == userId {
+				events = append(events, e)
+			}
+		}
+		return
+	}
+
+	events := list(0, pending+2)
+	if len(events) != 2 || events[0].Type != "todo.created" || events[1].Type != "todo.updated" || events[0].Id >= events[1].Id {
+		t.Fatalf("ListOutboxEvents returned %+v", events)
+	}
+	if string(events[0].Data) != `{"todo":{}}` || events[0].CreatedAt.IsZero() || events[0].Attempts != 0 || events[0].PublishedAt != nil {
+		t.Errorf("event is not stored: %+v", events[0])
+	}
+	if got, _, oldest, err := s.OutboxStats(); err != nil || got != pending+2 || oldest == nil {
+		t.Errorf("OutboxStats returned %d pending, oldest %v, err %v, want %d", got, oldest, err, pending+2)
+	}
+	if after := list(events[0].Id, 1); len(after) != 1 || after[0].Id != events[1].Id {
+		t.Errorf("ListOutboxEvents after the first event returned %+v", after)
+	}
+
+	// Failed attempt
+	now := time.Now().UTC().Truncate(time.Second)
+	next := now.Add(time.Minute)
+	failed := events[0]
+	failed.Attempts = 1
+	failed.LastError = stringPtr("timeout")
+	failed.NextAttemptAt = &next
+	if err = s.UpdateOutboxEvent(failed); err != nil {
+		t.Fatal(err)
+	}
+	got := list(0, pending+2)[0]
+	if got.Attempts != 1 || got.LastError == nil || *got.LastError != "timeout" || got.NextAttemptAt == nil || !got.NextAttemptAt.Equal(next) {
+		t.Errorf("failed attempt is not stored: %+v", got)
+	}
+
+	// Dead events are not listed and not pending
+	deadAt := now
+	got.DeadAt = &deadAt
+	got.NextAttemptAt = nil
+	if err = s.UpdateOutboxEvent(got); err != nil {
+		t.Fatal(err)
+	}
+	if listed := list(0, pending+2); len(listed) != 1 || listed[0].Id != events[1].Id {
+		t.Errorf("ListOutboxEvents with a dead event returned %+v", listed)
+	}
+	if gotPending, gotDead, _, err := s.OutboxStats(); err != nil || gotPending != pending+1 || gotDead != dead+1 {
+		t.Errorf("OutboxStats returned %d pending, %d dead, err %v, want %d, %d", gotPending, gotDead, err, pending+1, dead+1)
+	}
+
+	// Published events are not listed
+	for _, e := range events {
+		e.PublishedAt = &now
+		if err = s.UpdateOutboxEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if events = list(0, pending+2); len(events) != 0 {
+		t.Errorf("published events are listed: %+v", events)
+	}
+	if gotPending, gotDead, _, err := s.OutboxStats(); err != nil || gotPending != pending || gotDead != dead {
+		t.Errorf("OutboxStats returned %d pending, %d dead, err %v, want %d, %d", gotPending, gotDead, err, pending, dead)
+	}
+	if err = s.DeletePublishedOutboxEvents(now.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testDeleteAll(t *testing.T, s todo.TodoStore, userId uint64) {
+	td := insertTodo(t, s, userId, todo.Todo{Name: "a"})
+	other := insertTodo(t, s, userId+1, todo.Todo{Name: "b"})
+	if err := s.DeleteAll(userId); err != nil {
+		t.Fatal(err)
+	}
+	if _, notFound := getTodo(t, s, userId, td.Id); !notFound {
+		t.Error("todo is not deleted by DeleteAll")
+	}
+	if _, notFound := getTodo(t, s, userId+1, other.Id); notFound {
+		t.Error("todo of another user is deleted by DeleteAll")
+	}
+}
@@ -1,30 +1,47 @@
 package todo
 
 import (
-	"sort"
 	"time"
 )
 
 type Todo struct {
-	Id            uint64  `json:"id,omitempty"`
-	OriginalId    uint64  `json:"original_id,omitempty"`
-	Name          string  `json:"name"`
-	Description   *string `json:"description,omitempty"`
-	Date          *string `json:"date,omitempty"`
-	Time          *string `json:"time,omitempty"`
-	ExecutionTime uint    `json:"execution_time"`
-	SprintId      *uint64 `json:"sprint_id,omitempty"`
-	ProjectId     *uint64 `json:"project_id,omitempty"`
-	Completed     bool    `json:"completed"`
-	Repeat        *Repeat `json:"repeat,omitempty"`
+	Id            uint64          `json:"id,omitempty"`
+	OriginalId    uint64          `json:"original_id,omitempty"`
+	Name          string          `json:"name"`
+	Description   *string         `json:"description,omitempty"`
+	Date          *string         `json:"date,omitempty"`
+	Time          *string         `json:"time,omitempty"`
+	ExecutionTime uint            `json:"execution_time"`
+	SprintId      *uint64         `json:"sprint_id,omitempty"`
+	ProjectId     *uint64         `json:"project_id,omitempty"`
+	Completed     bool            `json:"completed"`
+	Priority      *uint           `json:"priority,omitempty"`
+	Repeat        *Repeat         `json:"repeat,omitempty"`
+	Labels        []uint64        `json:"labels,omitempty"`
+	Checklist     []ChecklistItem `json:"checklist,omitempty"`
+	BlockedBy     []uint64        `json:"blocked_by,omitempty"`
+	Blocks        []uint64        `json:"blocks,omitempty"`
+	CreatedAt     *time.Time      `json:"created_at,omitempty"`
+	UpdatedAt     *time.Time      `json:"updated_at,omitempty"`
 }
 
 type Repeat struct {
+	Id         uint64      `json:"-"`
 	Until      *string     `json:"until,omitempty" validate:"omitempty,Y-M-D"`
-	Unit       string      `json:"unit" validate:"required,oneof=day week month"`
+	Unit       string      `json:"unit,omitempty" validate:"required_without=RRule,omitempty,oneof=day week month year"`
 	EveryOther *uint       `json:"every_other,omitempty" validate:"omitempty,gte=1"`
 	Date       *uint       `json:"date,omitempty" validate:"omitempty,min=0,max=31"`
+	Month      *uint       `json:"month,omitempty" validate:"omitempty,min=1,max=12"`
+	LeapDay    *string     `json:"leap_day,omitempty" validate:"omitempty,oneof=feb28 mar1"`
 	Days       []RepeatDay `json:"days,omitempty" validate:"omitempty,dive"`
+	RRule      *string     `json:"rrule,omitempty" validate:"omitempty,rrule"`
+	Count      *uint       `json:"count,omitempty" validate:"omitempty,gte=1,lte=1000"`
+	From       string      `json:"from,omitempty" validate:"omitempty,oneof=schedule completion"`
+	// Occurrences left from this todo with `count`, which counts from the first todo of the chain. Read only.
+	Remaining *uint `json:"remaining,omitempty"`
+	// Number of occurrences completed or skipped in the chain of todos
+	Done       uint              `json:"-"`
+	Exceptions []RepeatException `json:"exceptions,omitempty"`
 }
 
 type RepeatDay struct {
@@ -35,86 +52,59 @@ type RepeatDay struct {
 func (r *Repeat) GetNext(year int, month time.Month, day int) (nextDate string, nextTime *string, overUntil bool, invalidUnit bool, err error) {
 	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
 
-	switch r.Unit {
-	case "day":
-		// next day
-		date = date.AddDate(0, 0, 1)
-		if r.EveryOther != nil {
-			// every others
-			date = date.AddDate(0, 0, int(*r.EveryOther))
-		}
-
-		nextDate = date.Format("2006-01-02")
-
-	case "week":
-		currentDay := date.Weekday()
-		// Sort asc by day of week
-		sort.Slice(r.Days, func(i, j int) bool {
-			return r.Days[i].Day < r.Days[j].Day
-		})
-		// Find next
-		nextDay := time.Weekday(r.Days[0].Day)
-		for _, rd := range r.Days {
-			if currentDay < time.Weekday(rd.Day) {
-				nextDay = time.Weekday(rd.Day)
-				nextTime = rd.Time
-				break
-			}
-		}
-		// every other ?
-		if r.EveryOther != nil && nextDay < currentDay {
-			date = date.AddDate(0, 0, 7)
-		}
-		// Create time.Time
-		date = date.AddDate(0, 0, 1)
-		for date.Weekday() != nextDay {
-			date = date.AddDate(0, 0, 1)
-		}
-
-		nextDate = date.Format("2006-01-02")
-
-	case "month":
-		// next month
-		currentMonth := date.Month()
-		targetMonth := currentMonth + 1
-		if r.EveryOther == nil {
-			date = date.AddDate(0, 1, 0)
-		} else {
-			// every other
-			targetMonth += time.Month(*r.EveryOther)
-			date = date.AddDate(0, 1+int(*r.EveryOther), 0)
-		}
-		for targetMonth != date.Month() {
-			date = date.AddDate(0, 0, -1)
-		}
-		if r.Date != nil && date.Day() < int(*r.Date) {
-			for date.Day() != int(*r.Date) && date.Month() == targetMonth {
-				date = date.AddDate(0, 0, 1)
-			}
-			if date.Month() != targetMonth {
-				date = date.AddDate(0, 0, -1)
-			}
-		}
-		nextDate = date.Format("2006-01-02")
-
-	default:
-		invalidUnit = true
+	rule, invalidUnit, err := r.Rule(date)
+	if err != nil || invalidUnit {
+		return
+	}
+	if rule.Count == 1 {
+		// This is the last occurrence
+		overUntil = true
+		return
+	}
+	until := rule.Until
+	rule.Until = nil
+	rule.Count = 0
+
+	next, ok := rule.After(date, date)
+	if !ok {
+		// No more occurrence
+		overUntil = true
+		return
 	}
+	nextDate = next.Format("2006-01-02")
+	nextTime = r.timeOn(next)
 
+	// Over until ?
+	if until != nil && next.After(*until) {
+		overUntil = true
+	}
 	if r.Until != nil {
 		var until time.Time
 		until, err = time.Parse("2006-1-2", *r.Until)
 		if err != nil {
 			return
 		}
-		// Over until ?
-		if date.After(until) {
+		if next.After(until) {
 			overUntil = true
 		}
 	}
 	return
 }
 
+// Datetime of the todo
+func (t *Todo) datetime() (datetime time.Time, err error) {
+	datetime, err = time.Parse("2006-1-2", *t.Date)
+	if err != nil || t.Time == nil {
+		return
+	}
+	hm, err := time.Parse("15:4", *t.Time)
+	if err != nil {
+		return
+	}
+	datetime = datetime.Add(time.Duration(hm.Hour())*time.Hour + time.Duration(hm.Minute())*time.Minute)
+	return
+}
+
 func (t *Todo) GetScheduledRepeats(start *time.Time, end time.Time) (todos []Todo, noRepeat bool, noDate bool, invalidUnit bool, err error) {
 	if t.Repeat == nil {
 		noRepeat = true
@@ -126,62 +116,72 @@ func (t *Todo) GetScheduledRepeats(start *time.Time, end time.Time) (todos []Tod
 		return
 	}
 
-	// Create time.Time by todo.Date and todo.Time
-	var datetime time.Time
-	if t.Time == nil {
-		datetime, err = time.Parse("2006-1-2", *t.Date)
-	} else {
-		datetime, err = time.Parse("2006-1-2T15:4", *t.Date+"T"+*t.Time)
-	}
-	if err != nil {
+	rule, dtstart, invalidUnit, err := t.rule()
+	if err != nil || invalidUnit {
 		return
 	}
+	_, base := t.series()
 
-	var current time.Time
-	if start == nil {
-		current = datetime.AddDate(0, 0, -1)
-	} else if !datetime.Before(*start) {
-		current = datetime
-		// Add this Todo
-		todos = append(todos, *t)
-	} else {
-		current = (*start).AddDate(0, 0, -1)
-	}
-
-	for !current.After(end) {
-		var nextDate string
-		var nextTime *string
-		var overUntil bool
-		nextDate, nextTime, overUntil, invalidUnit, err = t.Repeat.GetNext(current.Year(), current.Month(), current.Day())
+	// Add to `todos` if in range
+	add := func(o Todo) (err error) {
+		datetime, err := o.datetime()
 		if err != nil {
 			return
 		}
-		if invalidUnit {
+		if start != nil && datetime.Before(*start) || datetime.After(end) {
 			return
 		}
-		if overUntil {
-			break
-		}
+		todos = append(todos, o)
+		return
+	}
 
-		if t.Time == nil || nextTime == nil || *nextTime == "" {
-			current, err = time.Parse("2006-1-2", nextDate)
-		} else {
-			current, err = time.Parse("2006-1-2T15:4", nextDate+"T"+*nextTime)
+	// Add this Todo
+	err = add(*t)
+	if err != nil {
+		return
+	}
+	if t.Repeat.From == RepeatFromCompletion {
+		// Following occurrences depend on the completion
+		return
+	}
+
+	from := dtstart
+	if start != nil && start.After(from) {
+		from = *start
+	}
+	listed := map[string]bool{}
+	for _, date := range rule.Between(dtstart, from, end) {
+		if date.Equal(dtstart) {
+			continue
+		}
+		listed[date.Format("2006-01-02")] = true
+		o, ok := base.occurrence(date)
+		if !ok {
+			continue
 		}
+		err = add(o)
 		if err != nil {
 			return
 		}
+	}
 
-		if !current.After(end) {
-			nextTodo := *t
-			nextTodo.OriginalId = nextTodo.Id
-			nextTodo.Id = 0
-			nextTodo.Date = &nextDate
-			nextTodo.Repeat = nil
-			if nextTime != nil {
-				nextTodo.Time = nextTime
-			}
-			todos = append(todos, nextTodo)
+	// Occurrences moved into the range
+	for _, e := range t.Repeat.Exceptions {
+		if e.Cancelled || e.NewDate == nil || listed[e.Date] {
+			continue
+		}
+		var date time.Time
+		date, err = time.Parse("2006-1-2", e.Date)
+		if err != nil {
+			return
+		}
+		if !date.After(dtstart) || len(rule.Between(dtstart, date, date)) == 0 {
+			continue
+		}
+		o, _ := base.occurrence(date)
+		err = add(o)
+		if err != nil {
+			return
 		}
 	}
 
@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+)
+
+var ErrForbiddenAddress = errors.New("webhook address is loopback, private or link-local")
+
+// Addresses of the server and of its network are not delivered to by users
+func publicIp(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified())
+}
+
+// All addresses of the host are public
+func publicHost(host string) bool {
+	ips, err := net.DefaultResolver.LookupIPAddr(context.Background(), host)
+	if err != nil || len(ips) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if !publicIp(ip.IP) {
+			return false
+		}
+	}
+	return true
+}
+
+// Rejects the connection to the resolved address, so the host cannot resolve to another address after the validation
+func dialControl(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIp(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
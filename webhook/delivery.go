@@ -0,0 +1,52 @@
+package webhook
+
+// Deliveries per page of the log
+const (
+	DefaultDeliveriesLimit = 50
+	MaxDeliveriesLimit     = 100
+)
+
+// GetDeliveries returns the log of the subscription newest first
+func GetDeliveries(s Store, userId uint64, subscriptionId uint64, before uint64, limit int) (deliveries []Delivery, notFound bool, err error) {
+	_, notFound, err = s.GetSubscription(userId, subscriptionId)
+	if err != nil || notFound {
+		return
+	}
+	deliveries, err = s.ListDeliveries(userId, subscriptionId, before, limit)
+	return
+}
+
+func GetDelivery(s Store, userId uint64, subscriptionId uint64, id uint64) (d Delivery, notFound bool, err error) {
+	return s.GetDelivery(userId, subscriptionId, id)
+}
+
+// Redeliver queues a new delivery of the event of the delivery, signed again when sent
+func (d *Dispatcher) Redeliver(userId uint64, subscriptionId uint64, id uint64) (redelivery Delivery, notFound bool, inactive bool, err error) {
+	sub, notFound, err := d.store.GetSubscription(userId, subscriptionId)
+	if err != nil || notFound {
+		return
+	}
+	if !sub.Active {
+		return redelivery, false, true, nil
+	}
+	original, notFound, err := d.store.GetDelivery(userId, subscriptionId, id)
+	if err != nil || notFound {
+		return
+	}
+	now := d.now()
+	redelivery, err = d.store.InsertDelivery(Delivery{
+		SubscriptionId: original.SubscriptionId,
+		UserId:         original.UserId,
+		EventId:        original.EventId,
+		Event:          original.Event,
+		Payload:        original.Payload,
+		Status:         DeliveryPending,
+		NextAttemptAt:  &now,
+		RedeliveryOf:   &original.Id,
+	})
+	if err != nil {
+		return
+	}
+	d.wakeUp()
+	return
+}
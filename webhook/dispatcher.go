@@ -0,0 +1,247 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Deliveries claimed at once
+const claimLimit = 20
+
+// Upper bound of the backoff between attempts
+const maxBackoff = 6 * time.Hour
+
+// Logger is satisfied by `echo.Logger`
+type Logger interface {
+	Error(i ...interface{})
+	Debugf(format string, args ...interface{})
+}
+
+type DispatcherConfig struct {
+	// Attempts of a delivery before it fails
+	MaxAttempts uint
+	// Delay before the first retry, doubled on each retry
+	Backoff time.Duration
+	// Timeout of a request
+	Timeout time.Duration
+	// Interval to look for due retries
+	PollInterval time.Duration
+}
+
+// Dispatcher queues deliveries of events to the subscriptions and sends them in the background.
+// Deliveries are persisted before they are sent, so pending deliveries are resumed after a restart.
+type Dispatcher struct {
+	store  Store
+	config DispatcherConfig
+	// Client of the subscriptions of users, only connects to public addresses
+	client *http.Client
+	// Client of the system subscriptions configured by the operator
+	systemClient *http.Client
+	wake         chan struct{}
+	now          func() time.Time
+}
+
+func NewDispatcher(s Store, config DispatcherConfig) *Dispatcher {
+	dialer := &net.Dialer{Timeout: config.Timeout, Control: dialControl}
+	transport := &http.Transport{
+		// Proxies would connect to any address
+		DialContext:         dialer.DialContext,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	return &Dispatcher{
+		store:        s,
+		config:       config,
+		client:       &http.Client{Transport: transport, Timeout: config.Timeout, CheckRedirect: noRedirect},
+		systemClient: &http.Client{Timeout: config.Timeout, CheckRedirect: noRedirect},
+		wake:         make(chan struct{}, 1),
+		now:          func() time.Time { return time.Now().UTC().Truncate(time.Second) },
+	}
+}
+
+// Redirects are not followed, the 3xx response fails the delivery
+func noRedirect(req *http.Request, via []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+// Emit queues the deliveries of the event to the subscriptions of the user and of the system
+func (d *Dispatcher) Emit(e Event) (err error) {
+	var subscriptions []Subscription
+	for _, id := range []uint64{e.UserId, 0} {
+		var s []Subscription
+		s, err = d.store.ListSubscriptions(id)
+		if err != nil {
+			return
+		}
+		for _, sub := range s {
+			if sub.Subscribes(e.Type) {
+				subscriptions = append(subscriptions, sub)
+			}
+		}
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	now := d.now()
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	for _, sub := range subscriptions {
+		_, err = d.store.InsertDelivery(Delivery{
+			SubscriptionId: sub.Id,
+			UserId:         sub.UserId,
+			EventId:        e.Id,
+			Event:          e.Type,
+			Payload:        payload,
+			Status:         DeliveryPending,
+			NextAttemptAt:  &now,
+		})
+		if err != nil {
+			return
+		}
+	}
+	d.wakeUp()
+	return
+}
+
+func (d *Dispatcher) wakeUp() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due deliveries until stop is closed
+func (d *Dispatcher) Run(stop <-chan struct{}, logger Logger) {
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		// Claim again while the batches are full
+		for {
+			n, err := d.dispatch(logger)
+			if err != nil {
+				logger.Error(err)
+			}
+			if err != nil || n < claimLimit {
+				break
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sends a batch of due deliveries
+func (d *Dispatcher) dispatch(logger Logger) (n int, err error) {
+	// Other dispatchers skip the claimed deliveries until all requests of the batch time out
+	deliveries, err := d.store.ClaimDeliveries(d.now(), claimLimit*d.config.Timeout+time.Minute, claimLimit)
+	if err != nil {
+		return
+	}
+	for _, delivery := range deliveries {
+		if err = d.deliver(delivery, logger); err != nil {
+			return
+		}
+	}
+	return len(deliveries), nil
+}
+
+// Attempts the delivery and records the result
+func (d *Dispatcher) deliver(delivery Delivery, logger Logger) (err error) {
+	sub, notFound, err := d.store.GetSubscription(delivery.UserId, delivery.SubscriptionId)
+	if err != nil {
+		return
+	}
+	if notFound || !sub.Active {
+		// Not retried until the subscription is active and the delivery redelivered
+		failure := "subscription is inactive"
+		delivery.Status = DeliveryFailed
+		delivery.Error = &failure
+		delivery.NextAttemptAt = nil
+		return d.store.UpdateDelivery(delivery)
+	}
+
+	status, failure := d.send(sub, delivery)
+	now := d.now()
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+	delivery.Error = nil
+	if failure == "" {
+		delivery.Status = DeliverySucceeded
+		delivery.NextAttemptAt = nil
+		delivery.DeliveredAt = &now
+		logger.Debugf("webhook delivery %d of `%s` to subscription %d succeeded", delivery.Id, delivery.Event, sub.Id)
+		return d.store.UpdateDelivery(delivery)
+	}
+
+	delivery.Error = &failure
+	if delivery.Attempts >= d.config.MaxAttempts {
+		delivery.Status = DeliveryFailed
+		delivery.NextAttemptAt = nil
+	} else {
+		next := now.Add(d.backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+	logger.Debugf("webhook delivery %d of `%s` to subscription %d failed at attempt %d: %s", delivery.Id, delivery.Event, sub.Id, delivery.Attempts, failure)
+	return d.store.UpdateDelivery(delivery)
+}
+
+// Delay before the retry after the attempt
+func (d *Dispatcher) backoff(attempts uint) time.Duration {
+	backoff := d.config.Backoff
+	for i := uint(1); i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+// POSTs the signed payload, failure is empty on a 2xx response
+func (d *Dispatcher) send(sub Subscription, delivery Delivery) (status *int, failure string) {
+	req, err := http.NewRequest(http.MethodPost, sub.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return nil, err.Error()
+	}
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "flow-todos-webhook")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderEventId, delivery.EventId)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(delivery.Id, 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, timestamp, delivery.Payload))
+
+	client := d.client
+	if sub.UserId == 0 {
+		client = d.systemClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err.Error()
+	}
+	defer res.Body.Close()
+	// Drain the body to reuse the connection
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64<<10))
+
+	status = &res.StatusCode
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return status, fmt.Sprintf("unexpected status %d", res.StatusCode)
+	}
+	return status, ""
+}
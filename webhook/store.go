@@ -0,0 +1,25 @@
+package webhook
+
+import "time"
+
+// Store persists subscriptions and the log of deliveries.
+type Store interface {
+	// ListSubscriptions returns the subscriptions of the user ordered by id, user id 0 for the system.
+	ListSubscriptions(userId uint64) (subscriptions []Subscription, err error)
+	GetSubscription(userId uint64, id uint64) (s Subscription, notFound bool, err error)
+	InsertSubscription(s Subscription) (inserted Subscription, err error)
+	// UpdateSubscription overwrites url, events, secret and active of the subscription `s.Id`.
+	UpdateSubscription(s Subscription) (updated Subscription, err error)
+	// DeleteSubscription deletes the subscription and its deliveries.
+	DeleteSubscription(userId uint64, id uint64) (notFound bool, err error)
+
+	InsertDelivery(d Delivery) (inserted Delivery, err error)
+	// UpdateDelivery overwrites the status and the attempts of the delivery `d.Id`.
+	UpdateDelivery(d Delivery) (err error)
+	GetDelivery(userId uint64, subscriptionId uint64, id uint64) (d Delivery, notFound bool, err error)
+	// ListDeliveries returns the deliveries of the subscription newest first, before the id `before` if not 0 and at most `limit`.
+	ListDeliveries(userId uint64, subscriptionId uint64, before uint64, limit int) (deliveries []Delivery, err error)
+	// ClaimDeliveries returns the pending deliveries due at `now` in order, at most `limit`.
+	// Their next attempt is postponed by `lease` so that other dispatchers do not send them meanwhile.
+	ClaimDeliveries(now time.Time, lease time.Duration, limit int) (deliveries []Delivery, err error)
+}
@@ -0,0 +1,141 @@
+package webhook
+
+import (
+	"net/url"
+)
+
+type SubscriptionPostBody struct {
+	Url    string   `json:"url" validate:"required,url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=todo.created todo.updated todo.completed todo.skipped todo.deleted"`
+}
+
+type SubscriptionPatchBody struct {
+	Url    *string   `json:"url" validate:"omitempty,url,max=2048"`
+	Events *[]string `json:"events" validate:"omitempty,min=1,dive,oneof=todo.created todo.updated todo.completed todo.skipped todo.deleted"`
+	Active *bool     `json:"active" validate:"omitempty"`
+	// Replaces the secret, returned in the response
+	RotateSecret bool `json:"rotate_secret" validate:"omitempty"`
+}
+
+// Only http and https to public addresses are delivered
+func validUrl(str string) bool {
+	u, err := url.Parse(str)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Hostname() != "" && publicHost(u.Hostname())
+}
+
+// Events without duplicates in the order of `Events`
+func normalizeEvents(events []string) (normalized []string) {
+	for _, e := range Events {
+		for _, e2 := range events {
+			if e == e2 {
+				normalized = append(normalized, e)
+				break
+			}
+		}
+	}
+	return
+}
+
+func GetSubscriptions(s Store, userId uint64) (subscriptions []Subscription, err error) {
+	subscriptions, err = s.ListSubscriptions(userId)
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+	return
+}
+
+func GetSubscription(s Store, userId uint64, id uint64) (sub Subscription, notFound bool, err error) {
+	sub, notFound, err = s.GetSubscription(userId, id)
+	sub.Secret = ""
+	return
+}
+
+// PostSubscription creates an active subscription with a new secret
+func PostSubscription(s Store, userId uint64, post SubscriptionPostBody) (sub Subscription, invalidUrl bool, err error) {
+	if !validUrl(post.Url) {
+		return sub, true, nil
+	}
+	secret, err := NewSecret()
+	if err != nil {
+		return
+	}
+	sub, err = s.InsertSubscription(Subscription{
+		UserId: userId,
+		Url:    post.Url,
+		Events: normalizeEvents(post.Events),
+		Secret: secret,
+		Active: true,
+	})
+	return
+}
+
+func PatchSubscription(s Store, userId uint64, id uint64, patch SubscriptionPatchBody) (sub Subscription, notFound bool, invalidUrl bool, err error) {
+	sub, notFound, err = s.GetSubscription(userId, id)
+	if err != nil || notFound {
+		return
+	}
+	if patch.Url != nil {
+		if !validUrl(*patch.Url) {
+			return sub, false, true, nil
+		}
+		sub.Url = *patch.Url
+	}
+	if patch.Events != nil {
+		sub.Events = normalizeEvents(*patch.Events)
+	}
+	if patch.Active != nil {
+		sub.Active = *patch.Active
+	}
+	if patch.RotateSecret {
+		sub.Secret, err = NewSecret()
+		if err != nil {
+			return
+		}
+	}
+	sub, err = s.UpdateSubscription(sub)
+	if !patch.RotateSecret {
+		sub.Secret = ""
+	}
+	return
+}
+
+func DeleteSubscription(s Store, userId uint64, id uint64) (notFound bool, err error) {
+	return s.DeleteSubscription(userId, id)
+}
+
+// SyncSystemSubscriptions subscribes the urls to all events with the secret.
+// System subscriptions of other urls are deactivated, keeping their deliveries.
+func SyncSystemSubscriptions(s Store, urls []string, secret string) (err error) {
+	subscriptions, err := s.ListSubscriptions(0)
+	if err != nil {
+		return
+	}
+	synced := map[string]bool{}
+	for _, sub := range subscriptions {
+		configured := false
+		for _, u := range urls {
+			configured = configured || sub.Url == u
+		}
+		if configured && synced[sub.Url] {
+			configured = false
+		}
+		synced[sub.Url] = synced[sub.Url] || configured
+		sub.Events = Events
+		sub.Secret = secret
+		sub.Active = configured
+		if _, err = s.UpdateSubscription(sub); err != nil {
+			return
+		}
+	}
+	for _, u := range urls {
+		if synced[u] {
+			continue
+		}
+		synced[u] = true
+		_, err = s.InsertSubscription(Subscription{Url: u, Events: Events, Secret: secret, Active: true})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
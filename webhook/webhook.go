@@ -0,0 +1,126 @@
+// Package webhook delivers events of todos to the URLs subscribed by users and by the system.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flow-todos/todo"
+	"time"
+)
+
+// Events
+const (
+	TodoCreated   = todo.EventCreated
+	TodoUpdated   = todo.EventUpdated
+	TodoCompleted = todo.EventCompleted
+	TodoSkipped   = todo.EventSkipped
+	TodoDeleted   = todo.EventDeleted
+)
+
+// Events in the order of the docs
+var Events = []string{TodoCreated, TodoUpdated, TodoCompleted, TodoSkipped, TodoDeleted}
+
+// Statuses of deliveries
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Headers of deliveries
+const (
+	HeaderEvent     = "X-Flow-Event"
+	HeaderEventId   = "X-Flow-Event-Id"
+	HeaderDelivery  = "X-Flow-Delivery"
+	HeaderTimestamp = "X-Flow-Timestamp"
+	HeaderSignature = "X-Flow-Signature"
+)
+
+type Subscription struct {
+	Id uint64 `json:"id"`
+	// 0 for subscriptions of the system
+	UserId uint64   `json:"-"`
+	Url    string   `json:"url"`
+	Events []string `json:"events"`
+	// Key of the signatures, only returned on creation
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Subscribes is true if the active subscription subscribes the event
+func (s Subscription) Subscribes(event string) bool {
+	if !s.Active {
+		return false
+	}
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is an attempted or pending POST of an event to a subscription
+type Delivery struct {
+	Id             uint64 `json:"id"`
+	SubscriptionId uint64 `json:"subscription_id"`
+	// Owner of the subscription
+	UserId  uint64          `json:"-"`
+	EventId string          `json:"event_id"`
+	Event   string          `json:"event"`
+	Payload json.RawMessage `json:"payload"`
+	Status  string          `json:"status"`
+	// Attempts made, retried with exponential backoff until the max attempts
+	Attempts uint `json:"attempts"`
+	// Status of the last response
+	ResponseStatus *int `json:"response_status"`
+	// Reason of the last failed attempt
+	Error *string `json:"error"`
+	// Time of the next attempt of a pending delivery
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	// Delivery repeated by this delivery
+	RedeliveryOf *uint64   `json:"redelivery_of"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Event is the JSON body of deliveries
+type Event struct {
+	// Same for the redeliveries and the deliveries to other subscriptions
+	Id        string    `json:"id"`
+	Type      string    `json:"type"`
+	UserId    uint64    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	// `todo.EventData`
+	Data json.RawMessage `json:"data"`
+}
+
+// Sign returns the signature of the body sent at the timestamp, `sha256=` and the hex of HMAC-SHA256 of `<timestamp>.<body>`
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomHex(n int) (str string, err error) {
+	b := make([]byte, n)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewSecret generates the key of the signatures of a subscription
+func NewSecret() (secret string, err error) {
+	secret, err = randomHex(32)
+	if err != nil {
+		return
+	}
+	return "whsec_" + secret, nil
+}